@@ -9,7 +9,9 @@ import (
 	"errors"
 	"fmt"
 	"net/http"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
 	"strconv"
 	"strings"
@@ -35,6 +37,8 @@ type Configuration struct {
 	Database   DatabaseConfig
 	EmailConf  EmailConfig
 	Logger     LoggerConfig
+	Media      MediaConfig
+	Post       PostConfig
 	Server     ServerConfig
 	Security   SecurityConfig
 	ViewConfig ViewConfig
@@ -74,7 +78,20 @@ func Config() (err error) {
 	if err != nil {
 		return
 	}
-	configuration.Server = server()
+	configuration.Server, err = server()
+	if err != nil {
+		return
+	}
+
+	configuration.Media, err = media()
+	if err != nil {
+		return
+	}
+
+	configuration.Post, err = post()
+	if err != nil {
+		return
+	}
 
 	configuration.ViewConfig, err = view()
 	if err != nil {
@@ -365,6 +382,19 @@ func security() (securityConfig SecurityConfig, err error) {
 		securityConfig.Blake2bSec = []byte(blake2bSec)
 	}
 
+	// keys for signing the URLs of private media
+	for _, key := range strings.Split(os.Getenv("MEDIA_URL_SIGNING_KEYS"), ",") {
+		key = strings.TrimSpace(key)
+		if key == "" {
+			continue
+		}
+		if len(key) < 32 {
+			err = errors.New("MEDIA_URL_SIGNING_KEYS: every key must have at least 32 characters")
+			return
+		}
+		securityConfig.MediaURLKeys = append(securityConfig.MediaURLKeys, []byte(key))
+	}
+
 	// Email verification and password recovery
 	securityConfig.VerifyEmail = false
 	securityConfig.RecoverPass = false
@@ -569,12 +599,434 @@ func security() (securityConfig SecurityConfig, err error) {
 	return
 }
 
-// server - port and env
-func server() (serverConfig ServerConfig) {
+// server - port, env and robots.txt
+func server() (serverConfig ServerConfig, err error) {
 	serverConfig.ServerHost = strings.TrimSpace(os.Getenv("APP_HOST"))
 	serverConfig.ServerPort = strings.TrimSpace(os.Getenv("APP_PORT"))
 	serverConfig.ServerEnv = strings.ToLower(strings.TrimSpace(os.Getenv("APP_ENV")))
 
+	// robots.txt
+	serverConfig.RobotsFile = strings.TrimSpace(os.Getenv("ROBOTS_TXT_FILE"))
+	if serverConfig.RobotsFile != "" {
+		if _, err = os.Stat(serverConfig.RobotsFile); err != nil {
+			return
+		}
+	}
+	for _, p := range strings.Split(os.Getenv("ROBOTS_DISALLOW"), ",") {
+		if p = strings.TrimSpace(p); p != "" {
+			serverConfig.RobotsDisallow = append(serverConfig.RobotsDisallow, p)
+		}
+	}
+
+	return
+}
+
+// media - upload limits, accepted file types and storage backend
+func media() (mediaConfig MediaConfig, err error) {
+	// default: 10 MiB per file
+	mediaConfig.MaxUploadSize = 10 << 20
+	maxUploadSize := strings.TrimSpace(os.Getenv("MEDIA_MAX_UPLOAD_SIZE"))
+	if maxUploadSize != "" {
+		mediaConfig.MaxUploadSize, err = strconv.ParseInt(maxUploadSize, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+
+	// default: 1 MiB on top of the file size for the multipart envelope
+	mediaConfig.MaxRequestSize = mediaConfig.MaxUploadSize + 1<<20
+	maxRequestSize := strings.TrimSpace(os.Getenv("MEDIA_MAX_REQUEST_SIZE"))
+	if maxRequestSize != "" {
+		mediaConfig.MaxRequestSize, err = strconv.ParseInt(maxRequestSize, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+
+	if mediaConfig.MaxUploadSize <= 0 || mediaConfig.MaxRequestSize < mediaConfig.MaxUploadSize {
+		err = errors.New("invalid MEDIA_MAX_UPLOAD_SIZE or MEDIA_MAX_REQUEST_SIZE")
+		return
+	}
+
+	// comma-separated list of accepted MIME types
+	allowedTypes := strings.TrimSpace(os.Getenv("MEDIA_ALLOWED_TYPES"))
+	if allowedTypes == "" {
+		allowedTypes = "image/jpeg,image/png,image/gif,image/webp,application/pdf"
+	}
+	for _, t := range strings.Split(allowedTypes, ",") {
+		t = strings.ToLower(strings.TrimSpace(t))
+		if t != "" {
+			mediaConfig.AllowedTypes = append(mediaConfig.AllowedTypes, t)
+		}
+	}
+	// EXIF and XMP of uploaded images, removed unless MEDIA_STRIP_METADATA=no
+	mediaConfig.StripMetadata = strings.ToLower(strings.TrimSpace(os.Getenv("MEDIA_STRIP_METADATA"))) != "no"
+	// a decoded image takes 4 bytes per pixel, whatever the size
+	// of its file
+	mediaConfig.MaxPixels = 50_000_000
+	maxPixels := strings.TrimSpace(os.Getenv("MEDIA_MAX_PIXELS"))
+	if maxPixels != "" {
+		mediaConfig.MaxPixels, err = strconv.ParseInt(maxPixels, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.MaxPixels <= 0 {
+		err = errors.New("MEDIA_MAX_PIXELS must be positive")
+		return
+	}
+
+	// media list
+	mediaConfig.ListLimit = 100
+	mediaConfig.ListMaxLimit = 1000
+	mediaConfig.ListMaxDepth = 5
+	listLimit := strings.TrimSpace(os.Getenv("MEDIA_LIST_LIMIT"))
+	if listLimit != "" {
+		mediaConfig.ListLimit, err = strconv.Atoi(listLimit)
+		if err != nil {
+			return
+		}
+	}
+	listMaxLimit := strings.TrimSpace(os.Getenv("MEDIA_LIST_MAX_LIMIT"))
+	if listMaxLimit != "" {
+		mediaConfig.ListMaxLimit, err = strconv.Atoi(listMaxLimit)
+		if err != nil {
+			return
+		}
+	}
+	listMaxDepth := strings.TrimSpace(os.Getenv("MEDIA_LIST_MAX_DEPTH"))
+	if listMaxDepth != "" {
+		mediaConfig.ListMaxDepth, err = strconv.Atoi(listMaxDepth)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.ListLimit <= 0 || mediaConfig.ListMaxLimit < mediaConfig.ListLimit || mediaConfig.ListMaxDepth <= 0 {
+		err = errors.New("invalid MEDIA_LIST_LIMIT, MEDIA_LIST_MAX_LIMIT or MEDIA_LIST_MAX_DEPTH")
+		return
+	}
+
+	// image variants, i.e. thumbnail:150,medium:600,large:1200
+	variants := strings.TrimSpace(os.Getenv("MEDIA_VARIANTS"))
+	if variants == "" {
+		variants = "thumbnail:150,medium:600,large:1200"
+	}
+	for _, v := range strings.Split(variants, ",") {
+		v = strings.TrimSpace(v)
+		if v == "" {
+			continue
+		}
+		nameSize := strings.SplitN(v, ":", 2)
+		if len(nameSize) != 2 {
+			err = errors.New("invalid MEDIA_VARIANTS")
+			return
+		}
+		variant := MediaVariant{Name: strings.ToLower(strings.TrimSpace(nameSize[0]))}
+		variant.MaxSize, err = strconv.Atoi(strings.TrimSpace(nameSize[1]))
+		if err != nil {
+			return
+		}
+		if variant.Name == "" || strings.ContainsAny(variant.Name, "./") || variant.MaxSize <= 0 {
+			err = errors.New("invalid MEDIA_VARIANTS")
+			return
+		}
+		mediaConfig.Variants = append(mediaConfig.Variants, variant)
+	}
+	if strings.ToLower(strings.TrimSpace(os.Getenv("MEDIA_VARIANT_WEBP"))) == Activated {
+		mediaConfig.VariantWebP = true
+	}
+	mediaConfig.VariantWorkers = 2
+	variantWorkers := strings.TrimSpace(os.Getenv("MEDIA_VARIANT_WORKERS"))
+	if variantWorkers != "" {
+		mediaConfig.VariantWorkers, err = strconv.Atoi(variantWorkers)
+		if err != nil {
+			return
+		}
+	}
+
+	// signed URLs of private media
+	mediaConfig.SignedURLTTL = 15 * time.Minute
+	mediaConfig.SignedURLMaxTTL = 7 * 24 * time.Hour
+	signedURLTTL := strings.TrimSpace(os.Getenv("MEDIA_SIGNED_URL_TTL"))
+	if signedURLTTL != "" {
+		mediaConfig.SignedURLTTL, err = time.ParseDuration(signedURLTTL)
+		if err != nil {
+			return
+		}
+	}
+	signedURLMaxTTL := strings.TrimSpace(os.Getenv("MEDIA_SIGNED_URL_MAX_TTL"))
+	if signedURLMaxTTL != "" {
+		mediaConfig.SignedURLMaxTTL, err = time.ParseDuration(signedURLMaxTTL)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.SignedURLTTL <= 0 || mediaConfig.SignedURLMaxTTL < mediaConfig.SignedURLTTL {
+		err = errors.New("invalid MEDIA_SIGNED_URL_TTL or MEDIA_SIGNED_URL_MAX_TTL")
+		return
+	}
+
+	// resumable uploads (tus)
+	mediaConfig.UploadDir = strings.TrimSpace(os.Getenv("MEDIA_UPLOAD_DIR"))
+	if mediaConfig.UploadDir == "" {
+		mediaConfig.UploadDir = "./uploads"
+	}
+	mediaConfig.UploadExpiry = 24 * time.Hour
+	uploadExpiry := strings.TrimSpace(os.Getenv("MEDIA_UPLOAD_EXPIRY"))
+	if uploadExpiry != "" {
+		mediaConfig.UploadExpiry, err = time.ParseDuration(uploadExpiry)
+		if err != nil {
+			return
+		}
+	}
+	mediaConfig.UploadCleanupInterval = time.Hour
+	uploadCleanupInterval := strings.TrimSpace(os.Getenv("MEDIA_UPLOAD_CLEANUP_INTERVAL"))
+	if uploadCleanupInterval != "" {
+		mediaConfig.UploadCleanupInterval, err = time.ParseDuration(uploadCleanupInterval)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.UploadExpiry <= 0 || mediaConfig.UploadCleanupInterval <= 0 {
+		err = errors.New("invalid MEDIA_UPLOAD_EXPIRY or MEDIA_UPLOAD_CLEANUP_INTERVAL")
+		return
+	}
+
+	// ZIP import, guarding against zip bombs
+	mediaConfig.ZipMaxSize = 200 << 20
+	mediaConfig.ZipMaxFiles = 1000
+	mediaConfig.ZipMaxExtractSize = 1 << 30
+	mediaConfig.ZipMaxRatio = 100
+	zipMaxSize := strings.TrimSpace(os.Getenv("MEDIA_ZIP_MAX_SIZE"))
+	if zipMaxSize != "" {
+		mediaConfig.ZipMaxSize, err = strconv.ParseInt(zipMaxSize, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	zipMaxFiles := strings.TrimSpace(os.Getenv("MEDIA_ZIP_MAX_FILES"))
+	if zipMaxFiles != "" {
+		mediaConfig.ZipMaxFiles, err = strconv.Atoi(zipMaxFiles)
+		if err != nil {
+			return
+		}
+	}
+	zipMaxExtractSize := strings.TrimSpace(os.Getenv("MEDIA_ZIP_MAX_EXTRACT_SIZE"))
+	if zipMaxExtractSize != "" {
+		mediaConfig.ZipMaxExtractSize, err = strconv.ParseInt(zipMaxExtractSize, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	zipMaxRatio := strings.TrimSpace(os.Getenv("MEDIA_ZIP_MAX_RATIO"))
+	if zipMaxRatio != "" {
+		mediaConfig.ZipMaxRatio, err = strconv.ParseInt(zipMaxRatio, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.ZipMaxSize <= 0 || mediaConfig.ZipMaxFiles <= 0 || mediaConfig.ZipMaxExtractSize <= 0 || mediaConfig.ZipMaxRatio <= 0 {
+		err = errors.New("invalid MEDIA_ZIP_MAX_SIZE, MEDIA_ZIP_MAX_FILES, MEDIA_ZIP_MAX_EXTRACT_SIZE or MEDIA_ZIP_MAX_RATIO")
+		return
+	}
+
+	// storage quotas, overridden per user in the media_quota table
+	userQuota := strings.TrimSpace(os.Getenv("MEDIA_USER_QUOTA"))
+	if userQuota != "" {
+		mediaConfig.UserQuota, err = strconv.ParseInt(userQuota, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	shopQuota := strings.TrimSpace(os.Getenv("MEDIA_SHOP_QUOTA"))
+	if shopQuota != "" {
+		mediaConfig.ShopQuota, err = strconv.ParseInt(shopQuota, 10, 64)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.UserQuota < 0 || mediaConfig.ShopQuota < 0 {
+		err = errors.New("invalid MEDIA_USER_QUOTA or MEDIA_SHOP_QUOTA")
+		return
+	}
+
+	// trash, i.e. 720h
+	mediaConfig.TrashRetention = 30 * 24 * time.Hour
+	trashRetention := strings.TrimSpace(os.Getenv("MEDIA_TRASH_RETENTION"))
+	if trashRetention != "" {
+		mediaConfig.TrashRetention, err = time.ParseDuration(trashRetention)
+		if err != nil {
+			return
+		}
+	}
+	mediaConfig.TrashPurgeInterval = time.Hour
+	trashPurgeInterval := strings.TrimSpace(os.Getenv("MEDIA_TRASH_PURGE_INTERVAL"))
+	if trashPurgeInterval != "" {
+		mediaConfig.TrashPurgeInterval, err = time.ParseDuration(trashPurgeInterval)
+		if err != nil {
+			return
+		}
+	}
+	if mediaConfig.TrashRetention < 0 || mediaConfig.TrashPurgeInterval <= 0 {
+		err = errors.New("invalid MEDIA_TRASH_RETENTION or MEDIA_TRASH_PURGE_INTERVAL")
+		return
+	}
+
+	// storage backend
+	mediaConfig.Storage = strings.ToLower(strings.TrimSpace(os.Getenv("MEDIA_STORAGE")))
+	if mediaConfig.Storage == "" {
+		mediaConfig.Storage = "local"
+	}
+	if mediaConfig.Storage != "local" && mediaConfig.Storage != "s3" {
+		err = errors.New("unsupported MEDIA_STORAGE")
+		return
+	}
+	// folder of the media library on the local disk
+	mediaConfig.Root = strings.TrimRight(strings.TrimSpace(os.Getenv("MEDIA_ROOT")), "/")
+	if mediaConfig.Root == "" {
+		mediaConfig.Root = "./public/european_honey"
+	}
+	// every shop keeps its media in <MEDIA_SHOP_DIR>/<user id>
+	mediaConfig.ShopDir = strings.Trim(strings.TrimSpace(os.Getenv("MEDIA_SHOP_DIR")), "/")
+	if mediaConfig.ShopDir == "" {
+		mediaConfig.ShopDir = "shops"
+	}
+	if mediaConfig.ShopDir != path.Clean(mediaConfig.ShopDir) || strings.HasPrefix(mediaConfig.ShopDir, ".") || strings.Contains(mediaConfig.ShopDir, "/.") {
+		err = errors.New("invalid MEDIA_SHOP_DIR")
+		return
+	}
+	// content-addressed storage, on unless MEDIA_DEDUP=no
+	mediaConfig.Dedup = strings.ToLower(strings.TrimSpace(os.Getenv("MEDIA_DEDUP"))) != "no"
+	if mediaConfig.Storage == "s3" {
+		mediaConfig.S3.Endpoint = strings.TrimSpace(os.Getenv("MEDIA_S3_ENDPOINT"))
+		mediaConfig.S3.Region = strings.TrimSpace(os.Getenv("MEDIA_S3_REGION"))
+		mediaConfig.S3.Bucket = strings.TrimSpace(os.Getenv("MEDIA_S3_BUCKET"))
+		mediaConfig.S3.AccessKey = strings.TrimSpace(os.Getenv("MEDIA_S3_ACCESS_KEY"))
+		mediaConfig.S3.SecretKey = strings.TrimSpace(os.Getenv("MEDIA_S3_SECRET_KEY"))
+		mediaConfig.S3.Prefix = strings.TrimSpace(os.Getenv("MEDIA_S3_PREFIX"))
+		if strings.ToLower(strings.TrimSpace(os.Getenv("MEDIA_S3_PATH_STYLE"))) == Activated {
+			mediaConfig.S3.PathStyle = true
+		}
+		mediaConfig.S3.Timeout = 30 * time.Second
+		s3Timeout := strings.TrimSpace(os.Getenv("MEDIA_S3_TIMEOUT"))
+		if s3Timeout != "" {
+			mediaConfig.S3.Timeout, err = time.ParseDuration(s3Timeout)
+			if err != nil {
+				return
+			}
+		}
+		if mediaConfig.S3.Timeout <= 0 {
+			err = errors.New("MEDIA_S3_TIMEOUT must be positive")
+			return
+		}
+	}
+
+	return
+}
+
+// defaultPostHTMLAllow - HTML which Markdown produces, without
+// anything running scripts or changing the page layout
+const defaultPostHTMLAllow string = "p br hr h1 h2 h3 h4 h5 h6 blockquote pre code[class] em strong del " +
+	"ul ol[start] li input[type checked disabled] a[href title] img[src alt title] " +
+	"table thead tbody tr th[align] td[align]"
+
+// post - HTML allow-list of the posts, comment, feed and view counter settings
+//
+// POST_HTML_ALLOW lists the elements separated by spaces, each
+// with its attributes in brackets, i.e. "p a[href title] img[src alt]"
+func post() (postConfig PostConfig, err error) {
+	allow := strings.TrimSpace(os.Getenv("POST_HTML_ALLOW"))
+	if allow == "" {
+		allow = defaultPostHTMLAllow
+	}
+
+	postConfig.HTMLAllowList = map[string][]string{}
+	for allow != "" {
+		end := strings.IndexAny(allow, " [")
+		if end < 0 {
+			end = len(allow)
+		}
+		element := strings.ToLower(allow[:end])
+		allow = allow[end:]
+
+		attrs := []string{}
+		if strings.HasPrefix(allow, "[") {
+			stop := strings.Index(allow, "]")
+			if stop < 0 {
+				err = errors.New("invalid POST_HTML_ALLOW")
+				return
+			}
+			for _, attr := range strings.Fields(allow[1:stop]) {
+				attrs = append(attrs, strings.ToLower(attr))
+			}
+			allow = allow[stop+1:]
+		}
+		if element == "" || strings.ContainsAny(element, "]<>") {
+			err = errors.New("invalid POST_HTML_ALLOW")
+			return
+		}
+
+		postConfig.HTMLAllowList[element] = append(postConfig.HTMLAllowList[element], attrs...)
+		allow = strings.TrimSpace(allow)
+	}
+
+	postConfig.CommentEditWindow = 15 * time.Minute
+	editWindow := strings.TrimSpace(os.Getenv("COMMENT_EDIT_WINDOW"))
+	if editWindow != "" {
+		postConfig.CommentEditWindow, err = time.ParseDuration(editWindow)
+		if err != nil {
+			return
+		}
+	}
+	if postConfig.CommentEditWindow < 0 {
+		err = errors.New("COMMENT_EDIT_WINDOW must not be negative")
+		return
+	}
+
+	// feeds
+	postConfig.SiteURL = strings.TrimRight(strings.TrimSpace(os.Getenv("SITE_URL")), "/")
+	if postConfig.SiteURL != "" {
+		// links in feeds and sitemaps are never built from the
+		// Host header of a request
+		siteURL, errURL := url.Parse(postConfig.SiteURL)
+		if errURL != nil || (siteURL.Scheme != "http" && siteURL.Scheme != "https") ||
+			siteURL.Host == "" || siteURL.User != nil || siteURL.RawQuery != "" || siteURL.Fragment != "" {
+			err = errors.New("SITE_URL must be an absolute http or https URL")
+			return
+		}
+	}
+	postConfig.FeedTitle = strings.TrimSpace(os.Getenv("FEED_TITLE"))
+	if postConfig.FeedTitle == "" {
+		postConfig.FeedTitle = "gintemp"
+	}
+	postConfig.FeedLimit = 20
+	feedLimit := strings.TrimSpace(os.Getenv("FEED_LIMIT"))
+	if feedLimit != "" {
+		postConfig.FeedLimit, err = strconv.Atoi(feedLimit)
+		if err != nil {
+			return
+		}
+	}
+	if postConfig.FeedLimit <= 0 {
+		err = errors.New("FEED_LIMIT must be positive")
+		return
+	}
+
+	// views are buffered in Redis or in memory and written in
+	// batches
+	postConfig.ViewFlushInterval = 30 * time.Second
+	flushInterval := strings.TrimSpace(os.Getenv("POST_VIEW_FLUSH_INTERVAL"))
+	if flushInterval != "" {
+		postConfig.ViewFlushInterval, err = time.ParseDuration(flushInterval)
+		if err != nil {
+			return
+		}
+	}
+	if postConfig.ViewFlushInterval <= 0 {
+		err = errors.New("POST_VIEW_FLUSH_INTERVAL must be positive")
+		return
+	}
+
 	return
 }
 
@@ -330,6 +330,44 @@ func TestGetConfig(t *testing.T) {
 	}
 	expected.ViewConfig.Directory = "templates"
 
+	expected.Media.MaxUploadSize = 10 << 20
+	expected.Media.MaxRequestSize = 11 << 20
+	expected.Media.AllowedTypes = []string{"image/jpeg", "image/png", "image/gif", "image/webp", "application/pdf"}
+	expected.Media.StripMetadata = true
+	expected.Media.MaxPixels = 50_000_000
+	expected.Media.ListLimit = 100
+	expected.Media.ListMaxLimit = 1000
+	expected.Media.ListMaxDepth = 5
+	expected.Media.Variants = []config.MediaVariant{{Name: "thumbnail", MaxSize: 150}, {Name: "medium", MaxSize: 600}, {Name: "large", MaxSize: 1200}}
+	expected.Media.VariantWorkers = 2
+	expected.Media.SignedURLTTL = 15 * time.Minute
+	expected.Media.SignedURLMaxTTL = 168 * time.Hour
+	expected.Media.UploadDir = "./uploads"
+	expected.Media.UploadExpiry = 24 * time.Hour
+	expected.Media.UploadCleanupInterval = time.Hour
+	expected.Media.ZipMaxSize = 200 << 20
+	expected.Media.ZipMaxFiles = 1000
+	expected.Media.ZipMaxExtractSize = 1 << 30
+	expected.Media.ZipMaxRatio = 100
+	expected.Media.TrashRetention = 720 * time.Hour
+	expected.Media.TrashPurgeInterval = time.Hour
+	expected.Media.Storage = "local"
+	expected.Media.Root = "./public/european_honey"
+	expected.Media.ShopDir = "shops"
+	expected.Media.Dedup = true
+
+	expected.Post.HTMLAllowList = map[string][]string{
+		"p": {}, "br": {}, "hr": {}, "h1": {}, "h2": {}, "h3": {}, "h4": {}, "h5": {}, "h6": {},
+		"blockquote": {}, "pre": {}, "code": {"class"}, "em": {}, "strong": {}, "del": {},
+		"ul": {}, "ol": {"start"}, "li": {}, "input": {"type", "checked", "disabled"},
+		"a": {"href", "title"}, "img": {"src", "alt", "title"},
+		"table": {}, "thead": {}, "tbody": {}, "tr": {}, "th": {"align"}, "td": {"align"},
+	}
+	expected.Post.CommentEditWindow = 15 * time.Minute
+	expected.Post.FeedTitle = "gintemp"
+	expected.Post.FeedLimit = 20
+	expected.Post.ViewFlushInterval = 30 * time.Second
+
 	if !reflect.DeepEqual(configAll, expected) {
 		t.Errorf("got: %v, want: %v", configAll, expected)
 	}
@@ -422,6 +460,10 @@ func TestErrorGetConfig(t *testing.T) {
 		{
 			Key: "EMAIL_PASS_RECOVER_VALIDITY_PERIOD",
 		},
+		{
+			Key:   "SITE_URL",
+			Value: "example.com",
+		},
 	}
 
 	// download a file from a remote location and save it
@@ -80,6 +80,12 @@ func IsRedis() bool {
 	return GetConfig().Database.REDIS.Activate == Activated
 }
 
+// IsSiteURL returns true when the public URL of the API is set
+// in .env
+func IsSiteURL() bool {
+	return GetConfig().Post.SiteURL != ""
+}
+
 // IsEmailService returns true when email service is enabled in .env
 func IsEmailService() bool {
 	return GetConfig().EmailConf.Activate == Activated
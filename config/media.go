@@ -0,0 +1,59 @@
+package config
+
+import "time"
+
+// MediaConfig - media library
+type MediaConfig struct {
+	MaxUploadSize  int64    // max size of one uploaded file in bytes
+	MaxRequestSize int64    // max size of a multipart request body in bytes
+	AllowedTypes   []string // MIME types detected from the file content, i.e. image/jpeg or image/*
+	StripMetadata  bool     // remove EXIF and XMP (GPS position, camera, ...) from uploaded images
+	MaxPixels      int64    // largest image (width x height) which is decoded, larger ones are refused
+
+	ListLimit    int // default page size of the media list
+	ListMaxLimit int // largest page size a client may ask for
+	ListMaxDepth int // deepest recursive tree a client may ask for
+
+	Variants       []MediaVariant // resized copies generated for uploaded images
+	VariantWebP    bool           // additionally store every variant as WebP
+	VariantWorkers int            // background workers generating the variants
+
+	SignedURLTTL    time.Duration // default lifetime of a signed URL of private media
+	SignedURLMaxTTL time.Duration // longest lifetime a client may ask for
+
+	UploadDir             string        // local folder keeping unfinished resumable uploads
+	UploadExpiry          time.Duration // unfinished uploads are dropped after this long without progress
+	UploadCleanupInterval time.Duration // how often expired uploads are dropped
+
+	ZipMaxSize        int64 // max size of an uploaded ZIP archive in bytes
+	ZipMaxFiles       int   // most entries an archive may have
+	ZipMaxExtractSize int64 // max size of all extracted files together in bytes
+	ZipMaxRatio       int64 // highest compression ratio of one entry
+
+	UserQuota int64 // bytes a user may store, 0 for no limit
+	ShopQuota int64 // bytes a shop account may store, 0 for no limit
+
+	TrashRetention     time.Duration // deleted entries are kept this long
+	TrashPurgeInterval time.Duration // how often the trash is checked
+
+	Storage string // local or s3
+	Root    string // folder of the local storage, the part inside ./public is also served under /assets
+	ShopDir string // folder keeping the namespace of every shop, i.e. shops/<user id>
+	Dedup   bool   // keep files with the same content once
+	S3      struct {
+		Endpoint  string
+		Region    string
+		Bucket    string
+		AccessKey string
+		SecretKey string
+		Prefix    string
+		PathStyle bool
+		Timeout   time.Duration // a request is cancelled when it stalls this long
+	}
+}
+
+// MediaVariant - a resized copy of an image, i.e. thumbnail
+type MediaVariant struct {
+	Name    string
+	MaxSize int // longest side in pixels
+}
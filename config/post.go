@@ -0,0 +1,18 @@
+package config
+
+import "time"
+
+// PostConfig - blog posts
+type PostConfig struct {
+	// elements and their attributes kept in the HTML rendered from
+	// the Markdown of a post, everything else is removed
+	HTMLAllowList map[string][]string
+
+	CommentEditWindow time.Duration // authors may change their comments this long after writing them
+
+	SiteURL   string // public URL of the API used in feeds and sitemaps, i.e. https://example.com, both are off when empty
+	FeedTitle string // title of the feeds
+	FeedLimit int    // newest posts in a feed
+
+	ViewFlushInterval time.Duration // buffered views are written to the database this often
+}
@@ -40,6 +40,10 @@ type SecurityConfig struct {
 	CipherKey  []byte // for 256-bit ChaCha20-Poly1305
 	Blake2bSec []byte // optional secret for blake2b hashing
 
+	// signed URLs of private media, the first key signs and
+	// all of them verify, so keys can be rotated
+	MediaURLKeys [][]byte
+
 	VerifyEmail bool
 	RecoverPass bool
 
@@ -5,4 +5,9 @@ type ServerConfig struct {
 	ServerHost string
 	ServerPort string // public port of server
 	ServerEnv  string
+
+	// robots.txt is read from RobotsFile, otherwise all crawlers
+	// are kept out of RobotsDisallow and pointed at the sitemap
+	RobotsFile     string
+	RobotsDisallow []string
 }
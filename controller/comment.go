@@ -0,0 +1,93 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	grenderer "github.com/tinkerbaj/gintemp/lib/renderer"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/handler"
+)
+
+// GetComments - GET /posts/:id/comments
+//
+// Threads of approved comments, `replies` holds the answers
+// to a comment
+func GetComments(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.GetComments(c.GetUint("userID"), id)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// CreateComment - POST /posts/:id/comments
+//
+// Accepted JSON: `body`, `parentID`: comment to reply to
+func CreateComment(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+	req := model.CommentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.CreateComment(c.GetUint("userID"), id, req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// UpdateComment - PUT /comments/:id
+//
+// Accepted JSON: `body`
+func UpdateComment(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+	req := model.CommentRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.UpdateComment(c.GetUint("userID"), id, req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// DeleteComment - DELETE /comments/:id
+func DeleteComment(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.DeleteComment(c.GetUint("userID"), id)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// GetCommentQueue - GET /comments/moderation
+//
+// Pending comments the caller may moderate, oldest first
+func GetCommentQueue(c *gin.Context) {
+	resp, statusCode := handler.GetCommentQueue(c.GetUint("userID"))
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// ApproveComment - POST /comments/:id/approve
+func ApproveComment(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.ModerateComment(c.GetUint("userID"), id, model.CommentApproved)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// RejectComment - POST /comments/:id/reject
+func RejectComment(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.ModerateComment(c.GetUint("userID"), id, model.CommentRejected)
+
+	grenderer.Render(c, resp, statusCode)
+}
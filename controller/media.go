@@ -2,106 +2,326 @@ package controller
 
 import (
 	"errors"
-	"fmt"
+	"mime"
 	"net/http"
-	"os"
-	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
 	"github.com/tinkerbaj/gintemp/handler"
+	"github.com/tinkerbaj/gintemp/lib"
 	"github.com/tinkerbaj/gintemp/lib/renderer"
+	"github.com/tinkerbaj/gintemp/lib/storage"
 )
 
+// GetMedia - GET /media
+//
+// Query parameters:
+//
+// `path`: folder to list, `q`: search in names and alt texts,
+// `tag`: repeatable, `type`: i.e. jpg, image/* or folder,
+// `sort`: name, size or mtime, `order`: asc or desc,
+// `limit`: page size, `cursor`: nextCursor of the previous
+// page, `depth`: levels of sub-folders to include
+//
+// The folder is read from the storage unless `q` or `tag`
+// is given. Private entries are only listed to a signed-in
+// caller who may change them
 func GetMedia(c *gin.Context) {
-	path := "./public/european_honey/"
-	if c.Query("path") != "" {
-		path = "./public/european_honey/" + c.Query("path")
+	filter := model.MediaFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
 	}
-	resp, statusCode := handler.GetMedia(path)
+
+	resp, statusCode := handler.GetMedia(c.GetUint("userID"), filter)
 
 	renderer.Render(c, resp, statusCode)
 }
 
+// CreateFolder - POST /media/create
+//
+// Accepted JSON: `path`: the new folder, which belongs to
+// the caller
 func CreateFolder(c *gin.Context) {
-	// Get the path parameter
-	path := c.Query("path")
+	folder := model.MediaFolder{}
+	if err := c.ShouldBindJSON(&folder); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.CreateFolder(c.GetUint("userID"), folder.Path)
+
+	renderer.Render(c, resp.Message, statusCode)
+}
+
+// RenameFolder - POST /media/rename
+//
+// Accepted JSON: `old`: current path, `new`: new path
+func RenameFolder(c *gin.Context) {
+	rename := model.MediaRename{}
+	if err := c.ShouldBindJSON(&rename); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
 
-	// Sanitize the path to avoid potential security vulnerabilities
-	cleanPath := filepath.Clean(path)
+	resp, statusCode := handler.RenameFolder(c.GetUint("userID"), rename.Old, rename.New)
 
-	// Construct the full path
-	fullPath := "./public/european_honey/" + cleanPath
+	renderer.Render(c, resp.Message, statusCode)
+}
+
+// UploadMedia - POST /media/upload
+//
+// Accepted multipart form:
+//
+// `file`: the file to upload, `path`: target folder,
+// `private`: true to serve the file only through signed URLs
+func UploadMedia(c *gin.Context) {
+	configMedia := config.GetConfig().Media
+
+	// reject oversized bodies before they hit the disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, configMedia.MaxRequestSize)
 
-	// Check if the path already exists
-	_, err := os.Stat(fullPath)
+	file, err := c.FormFile("file")
 	if err != nil {
-		if errors.Is(err, os.ErrNotExist) {
-			// Path doesn't exist, create the folder
-			err = os.Mkdir(fullPath, os.ModeDir)
-			if err != nil {
-				renderer.Render(c, "Error creating folder: "+err.Error(), http.StatusInternalServerError)
-				return
-			}
-			renderer.Render(c, "Folder created", http.StatusOK)
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			renderer.Render(c, gin.H{"message": "request too large"}, http.StatusRequestEntityTooLarge)
 			return
 		}
-		// Handle other potential errors from os.Stat
-		renderer.Render(c, "Error checking path: "+err.Error(), http.StatusInternalServerError)
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
 		return
 	}
 
-	// Path already exists
-	renderer.Render(c, "Folder already exists", http.StatusConflict)
+	private, _ := strconv.ParseBool(c.PostForm("private"))
+
+	resp, statusCode := handler.UploadMedia(c.GetUint("userID"), c.PostForm("path"), private, file)
+
+	renderer.Render(c, resp, statusCode)
 }
 
-func RenameFolder(c *gin.Context) {
-	// Get the path parameter
-	oldpath := c.Query("old")
-	newpath := c.Query("new")
-
-	// Sanitize the path to avoid potential security vulnerabilities
-	cleanOldPath := filepath.Clean(oldpath)
-	cleanNewPath := filepath.Clean(newpath)
-	// Construct the full path
-	fullOldPath := "./public/european_honey/" + cleanOldPath
-	fullNewPath := "./public/european_honey/" + cleanNewPath
-
-	// Check if the path already exists
-	_, err := os.Stat(fullOldPath)
+// ImportMedia - POST /media/import
+//
+// Accepted multipart form:
+//
+// `file`: ZIP archive, `path`: folder to extract it into
+func ImportMedia(c *gin.Context) {
+	configMedia := config.GetConfig().Media
+
+	// reject oversized bodies before they hit the disk
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, configMedia.ZipMaxSize+1<<20)
+
+	file, err := c.FormFile("file")
 	if err != nil {
+		var maxBytesErr *http.MaxBytesError
+		if errors.As(err, &maxBytesErr) {
+			renderer.Render(c, gin.H{"message": "request too large"}, http.StatusRequestEntityTooLarge)
+			return
+		}
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.ImportMedia(c.GetUint("userID"), c.PostForm("path"), file)
+
+	renderer.Render(c, resp, statusCode)
+}
 
-		// Path doesn't exist
-		renderer.Render(c, "Folder dont exist", http.StatusInternalServerError)
+// ExportMedia - GET /media/export?path=
+//
+// Streams the folder with everything in it as a ZIP archive
+func ExportMedia(c *gin.Context) {
+	folder, resp, statusCode := handler.ExportMedia(c.GetUint("userID"), c.Query("path"))
+	if statusCode != http.StatusOK {
+		renderer.Render(c, resp, statusCode)
 		return
+	}
+
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": folder.Name + ".zip"}))
+	c.Header("Cache-Control", "no-store")
+	c.Status(http.StatusOK)
 
+	handler.WriteMediaZip(c.Writer, folder)
+}
+
+// UpdateMediaMeta - PUT /media/meta
+//
+// Only the given fields are changed, tags replace the
+// existing ones
+func UpdateMediaMeta(c *gin.Context) {
+	meta := model.MediaMeta{}
+	if err := c.ShouldBindJSON(&meta); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
 	}
 
-	_, err = os.Stat(fullNewPath)
-	if err == nil {
+	resp, statusCode := handler.UpdateMediaMeta(c.GetUint("userID"), meta)
 
-		// Path doesn't exist
-		renderer.Render(c, "Folder with this name exist please try another name", http.StatusInternalServerError)
+	renderer.Render(c, resp, statusCode)
+}
+
+// GetMediaVariant - GET /media/variants/:variant/*path
+//
+// `variant`: i.e. thumbnail or thumbnail.webp, variants of
+// private files take the `expires` and `signature` of the
+// original
+func GetMediaVariant(c *gin.Context) {
+	expires, signature := mediaSignature(c)
+	file, info, mimeType, resp, statusCode := handler.GetMediaVariant(c.Param("variant"), c.Param("path"), expires, signature)
+	if statusCode != http.StatusOK {
+		renderer.Render(c, resp, statusCode)
 		return
+	}
+	defer file.Close()
 
+	cacheControl := "public, max-age=86400"
+	if signature != "" {
+		cacheControl = "private, no-cache"
 	}
+	serveMedia(c, file, info, mimeType, lib.ETag("", info.Size, info.ModTime), cacheControl)
+}
 
-	err = os.Rename(fullOldPath, fullNewPath)
-	if err != nil {
-		renderer.Render(c, "Something fails on server side", http.StatusInternalServerError)
+// DownloadMedia - GET /media/download/*path
+//
+// Supports Range requests and answers If-None-Match and
+// If-Modified-Since with 304, `download=true` asks the
+// browser to save the file instead of showing it, private
+// files need `expires` and `signature` from POST /media/sign
+func DownloadMedia(c *gin.Context) {
+	expires, signature := mediaSignature(c)
+	file, info, mimeType, etag, resp, statusCode := handler.GetMediaFile(c.Param("path"), expires, signature)
+	if statusCode != http.StatusOK {
+		renderer.Render(c, resp, statusCode)
 		return
 	}
-	// Path already exists
-	renderer.Render(c, "Folder renamed successfully", http.StatusOK)
+	defer file.Close()
+
+	disposition := "inline"
+	if download, _ := strconv.ParseBool(c.Query("download")); download {
+		disposition = "attachment"
+	}
+	c.Header("Content-Disposition", mime.FormatMediaType(disposition, map[string]string{"filename": info.Name}))
+
+	// always revalidate, the path may point at new content later
+	cacheControl := "public, no-cache"
+	if signature != "" {
+		// shared caches must not hand out private files
+		cacheControl = "private, no-cache"
+	}
+	serveMedia(c, file, info, mimeType, etag, cacheControl)
 }
 
-// Delete deletes a file or folder at the specified path
-func Delete(path string) error {
-	return os.RemoveAll(path) // Use with caution, may delete contents recursively
+// SignMediaURL - POST /media/sign
+//
+// Accepted JSON: `path`: file, `ttl`: lifetime in seconds,
+// the configured default when omitted
+func SignMediaURL(c *gin.Context) {
+	req := model.MediaSignRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.SignMediaURL(c.GetUint("userID"), req)
+
+	renderer.Render(c, resp, statusCode)
+}
+
+// mediaSignature - signature query parameters of a media URL
+func mediaSignature(c *gin.Context) (expires int64, signature string) {
+	expires, _ = strconv.ParseInt(c.Query("expires"), 10, 64)
+	return expires, c.Query("signature")
+}
+
+// serveMedia - stream a stored file, http.ServeContent takes
+// care of Range and the conditional request headers
+func serveMedia(c *gin.Context, file storage.File, info storage.FileInfo, mimeType, etag, cacheControl string) {
+	c.Header("Content-Type", mimeType)
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Cache-Control", cacheControl)
+	c.Header("ETag", etag)
+	http.ServeContent(c.Writer, c.Request, info.Name, info.ModTime, file)
+}
+
+// MoveMedia - POST /media/move
+//
+// Accepted JSON: `path`: file or folder, `folder`: target folder
+func MoveMedia(c *gin.Context) {
+	transfer := model.MediaTransfer{}
+	if err := c.ShouldBindJSON(&transfer); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.MoveMedia(c.GetUint("userID"), transfer)
+
+	renderer.Render(c, resp, statusCode)
+}
+
+// CopyMedia - POST /media/copy
+//
+// Accepted JSON: `path`: file or folder, `folder`: target folder
+func CopyMedia(c *gin.Context) {
+	transfer := model.MediaTransfer{}
+	if err := c.ShouldBindJSON(&transfer); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.CopyMedia(c.GetUint("userID"), transfer)
+
+	renderer.Render(c, resp, statusCode)
 }
 
-// DownloadFile downloads a file from the server to the client (implementation depends on your framework)
-func DownloadFile(path string) error {
-	// Implement logic to send the file content to the client (e.g., using HTTP)
-	fmt.Println("Download functionality not implemented yet")
-	return nil
+// DeleteMedia - DELETE /media?path=
+//
+// The file or folder is moved to the trash, `force=true` is
+// needed when posts show it
+func DeleteMedia(c *gin.Context) {
+	force, _ := strconv.ParseBool(c.Query("force"))
+	resp, statusCode := handler.DeleteMedia(c.GetUint("userID"), c.Query("path"), force)
+
+	renderer.Render(c, resp, statusCode)
+}
+
+// GetMediaTrash - GET /media/trash
+func GetMediaTrash(c *gin.Context) {
+	resp, statusCode := handler.GetMediaTrash(c.GetUint("userID"))
+
+	renderer.Render(c, resp, statusCode)
+}
+
+// RestoreMedia - POST /media/trash/:id/restore
+func RestoreMedia(c *gin.Context) {
+	resp, statusCode := handler.RestoreMedia(c.GetUint("userID"), strings.TrimSpace(c.Params.ByName("id")))
+
+	renderer.Render(c, resp, statusCode)
+}
+
+// GetMediaUsage - GET /media/usage
+//
+// Storage used by the caller and the quota, shops also get
+// the namespace their media has to be kept in
+func GetMediaUsage(c *gin.Context) {
+	resp, statusCode := handler.GetMediaUsage(c.GetUint("userID"))
+
+	renderer.Render(c, resp, statusCode)
+}
+
+// SetMediaQuota - PUT /media/quota/:userID
+//
+// Accepted JSON: `quota`: bytes, 0 for no limit, admins only
+func SetMediaQuota(c *gin.Context) {
+	quota := model.MediaQuota{}
+	if err := c.ShouldBindJSON(&quota); err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.SetMediaQuota(c.GetUint("userID"), strings.TrimSpace(c.Params.ByName("userID")), quota)
+
+	renderer.Render(c, resp, statusCode)
 }
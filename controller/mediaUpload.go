@@ -0,0 +1,156 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/handler"
+	"github.com/tinkerbaj/gintemp/lib"
+	"github.com/tinkerbaj/gintemp/lib/renderer"
+)
+
+// tusVersion - version of the tus protocol of the resumable
+// upload endpoints
+const tusVersion string = "1.0.0"
+
+// tusResumable - answer with the tus version and reject
+// requests of clients speaking another one
+func tusResumable(c *gin.Context) bool {
+	c.Header("Tus-Resumable", tusVersion)
+
+	if c.GetHeader("Tus-Resumable") != tusVersion {
+		c.Header("Tus-Version", tusVersion)
+		renderer.Render(c, gin.H{"message": "unsupported tus version"}, http.StatusPreconditionFailed)
+		return false
+	}
+
+	return true
+}
+
+// uploadHeaders - state of a resumable upload
+func uploadHeaders(c *gin.Context, upload model.MediaUpload) {
+	c.Header("Upload-Offset", strconv.FormatInt(upload.Offset, 10))
+	c.Header("Upload-Expires", upload.ExpiresAt.UTC().Format(http.TimeFormat))
+}
+
+// TusOptions - OPTIONS /media/tus
+//
+// Supported tus version, extensions and maximum file size
+func TusOptions(c *gin.Context) {
+	c.Header("Tus-Resumable", tusVersion)
+	c.Header("Tus-Version", tusVersion)
+	c.Header("Tus-Extension", "creation,termination,expiration")
+	c.Header("Tus-Max-Size", strconv.FormatInt(config.GetConfig().Media.MaxUploadSize, 10))
+
+	c.Status(http.StatusNoContent)
+}
+
+// CreateMediaUpload - POST /media/tus
+//
+// Headers: `Upload-Length`: size of the file,
+// `Upload-Metadata`: `filename`, `folder` and `private`
+//
+// The upload is continued at the returned Location
+func CreateMediaUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+
+	length, err := strconv.ParseInt(c.GetHeader("Upload-Length"), 10, 64)
+	if err != nil {
+		renderer.Render(c, gin.H{"message": "invalid Upload-Length"}, http.StatusBadRequest)
+		return
+	}
+	metadata, err := lib.ParseTusMetadata(c.GetHeader("Upload-Metadata"))
+	if err != nil {
+		renderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	upload, resp, statusCode := handler.CreateMediaUpload(c.GetUint("userID"), length, metadata)
+	if statusCode != http.StatusCreated {
+		renderer.Render(c, resp, statusCode)
+		return
+	}
+
+	uploadHeaders(c, upload)
+	c.Header("Location", strings.TrimSuffix(c.Request.URL.Path, "/")+"/"+upload.ID)
+	c.Status(http.StatusCreated)
+}
+
+// GetMediaUpload - HEAD /media/tus/:id
+//
+// Offset to resume the upload from
+func GetMediaUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+
+	c.Header("Cache-Control", "no-store")
+
+	upload, _, statusCode := handler.GetMediaUpload(c.GetUint("userID"), c.Param("id"))
+	if statusCode != http.StatusOK {
+		c.Status(statusCode)
+		return
+	}
+
+	uploadHeaders(c, upload)
+	c.Header("Upload-Length", strconv.FormatInt(upload.Length, 10))
+	c.Status(http.StatusOK)
+}
+
+// PatchMediaUpload - PATCH /media/tus/:id
+//
+// Headers: `Upload-Offset`: offset of the chunk in the body,
+// `Content-Type`: application/offset+octet-stream
+//
+// The file is added to the media library with the last chunk,
+// its path is returned in Media-Path
+func PatchMediaUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+
+	if c.ContentType() != "application/offset+octet-stream" {
+		renderer.Render(c, gin.H{"message": "Content-Type must be application/offset+octet-stream"}, http.StatusUnsupportedMediaType)
+		return
+	}
+	offset, err := strconv.ParseInt(c.GetHeader("Upload-Offset"), 10, 64)
+	if err != nil || offset < 0 {
+		renderer.Render(c, gin.H{"message": "invalid Upload-Offset"}, http.StatusBadRequest)
+		return
+	}
+
+	upload, resp, statusCode := handler.WriteMediaUpload(c.GetUint("userID"), c.Param("id"), offset, c.Request.Body)
+	if statusCode != http.StatusNoContent {
+		renderer.Render(c, resp, statusCode)
+		return
+	}
+
+	uploadHeaders(c, upload)
+	if upload.Media != nil {
+		c.Header("Media-Path", upload.Media.Path)
+	}
+	c.Status(http.StatusNoContent)
+}
+
+// DeleteMediaUpload - DELETE /media/tus/:id
+//
+// Abort an upload and drop the data received so far
+func DeleteMediaUpload(c *gin.Context) {
+	if !tusResumable(c) {
+		return
+	}
+
+	resp, statusCode := handler.DeleteMediaUpload(c.GetUint("userID"), c.Param("id"))
+	if statusCode != http.StatusNoContent {
+		renderer.Render(c, resp, statusCode)
+		return
+	}
+
+	c.Status(http.StatusNoContent)
+}
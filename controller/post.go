@@ -4,8 +4,6 @@ import (
 	"fmt"
 	"net/http"
 	"reflect"
-
-	// "strconv"
 	"strings"
 
 	"github.com/gin-gonic/gin"
@@ -17,17 +15,52 @@ import (
 )
 
 // GetPosts - GET /posts
+//
+// Query parameters:
+//
+// `author`: user ID, `from` and `to`: creation date range,
+// `title`: part of the title, `category`: slug including the
+// sub-categories, `tag`: slug, `sort`: created or updated,
+// `order`: asc or desc, `limit`: page size, `offset` or
+// `cursor`: nextCursor of the previous page
 func GetPosts(c *gin.Context) {
-	resp, statusCode := handler.GetPosts()
+	filter := model.PostFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.GetPosts(c.GetUint("userID"), filter)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// SearchPosts - GET /posts/search
+//
+// Query parameters:
+//
+// `q`: words to find in the title or body, `limit`: page
+// size, `offset`: results to skip
+func SearchPosts(c *gin.Context) {
+	search := model.PostSearch{}
+	if err := c.ShouldBindQuery(&search); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.SearchPosts(c.GetUint("userID"), search)
 
 	grenderer.Render(c, resp, statusCode)
 }
 
 // GetPost - GET /posts/:id
+//
+// `id`: ID or slug of the post, `stats` holds the views, the
+// reactions and the reaction of the caller when logged in
 func GetPost(c *gin.Context) {
 	id := strings.TrimSpace(c.Params.ByName("id"))
 
-	resp, statusCode := handler.GetPost(id)
+	resp, statusCode := handler.GetPost(c.GetUint("userID"), id)
 
 	if statusCode >= 400 {
 		errorMsg := model.ErrorMsg{}
@@ -42,16 +75,15 @@ func GetPost(c *gin.Context) {
 }
 
 // CreatePost - POST /posts
+//
+// Accepted JSON: `title`, `body`, `status`: draft (default),
+// scheduled, published or archived, `publishAt`: required for
+// scheduled posts, `slug`: made of the title when omitted,
+// `categories`: IDs or slugs, `tags`: names, `cover`: path or
+// ID of a public file of the media library, `attachments`:
+// paths or IDs of the gallery in order
 func CreatePost(c *gin.Context) {
-	// userIDAuth := c.GetUint64("userID")
-	// val, ok := c.Get("userID")
-	// if !ok && val == nil {
-	// 	grenderer.Render(c, "Wrong auth id", 400)
-	// 	return
-	// }
-
-	// userIDAuth, _ := strconv.ParseUint(val.(string), 10, 64)
-
+	userIDAuth := c.GetUint("userID")
 	post := model.Post{}
 
 	// bind JSON
@@ -60,7 +92,7 @@ func CreatePost(c *gin.Context) {
 		return
 	}
 
-	resp, statusCode := handler.CreatePost(17, post)
+	resp, statusCode := handler.CreatePost(userIDAuth, post)
 
 	if reflect.TypeOf(resp.Message).Kind() == reflect.String {
 		grenderer.Render(c, resp, statusCode)
@@ -71,8 +103,12 @@ func CreatePost(c *gin.Context) {
 }
 
 // UpdatePost - PUT /posts/:id
+//
+// Accepts the fields of CreatePost, status, publishAt, slug,
+// categories, tags, cover and attachments are kept when
+// omitted, an empty cover removes it
 func UpdatePost(c *gin.Context) {
-	userIDAuth := c.GetUint64("userID")
+	userIDAuth := c.GetUint("userID")
 	id := strings.TrimSpace(c.Params.ByName("id"))
 	post := model.Post{}
 
@@ -94,7 +130,7 @@ func UpdatePost(c *gin.Context) {
 
 // DeletePost - DELETE /posts/:id
 func DeletePost(c *gin.Context) {
-	userIDAuth := c.GetUint64("userID")
+	userIDAuth := c.GetUint("userID")
 	id := strings.TrimSpace(c.Params.ByName("id"))
 
 	resp, statusCode := handler.DeletePost(userIDAuth, id)
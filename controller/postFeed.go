@@ -0,0 +1,80 @@
+package controller
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	grenderer "github.com/tinkerbaj/gintemp/lib/renderer"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/handler"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// GetFeed - GET /feeds/:format
+//
+// `format`: rss, atom or json
+//
+// Query parameters:
+//
+// `author`: user ID or username, `tag`: slug
+//
+// Answers If-None-Match and If-Modified-Since with 304, served
+// only when SITE_URL is set
+func GetFeed(c *gin.Context) {
+	format := strings.ToLower(strings.TrimSpace(c.Params.ByName("format")))
+	if !lib.IsFeedFormat(format) {
+		grenderer.Render(c, gin.H{"message": "unknown feed format"}, http.StatusNotFound)
+		return
+	}
+
+	filter := model.FeedFilter{}
+	if err := c.ShouldBindQuery(&filter); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	baseURL := siteURL()
+	feed, resp, statusCode := handler.GetFeed(baseURL, baseURL+c.Request.URL.RequestURI(), filter)
+	if statusCode != http.StatusOK {
+		grenderer.Render(c, resp, statusCode)
+		return
+	}
+
+	body, err := feed.Encode(format)
+	if err != nil {
+		log.WithError(err).Error("error code: 1711")
+		grenderer.Render(c, gin.H{"message": "internal server error"}, http.StatusInternalServerError)
+		return
+	}
+
+	serveGenerated(c, lib.FeedContentType(format), feed.Updated, body)
+}
+
+// serveGenerated - send a generated document, the ETag is made
+// of its content, http.ServeContent answers the conditional
+// request headers
+func serveGenerated(c *gin.Context, contentType string, modTime time.Time, body []byte) {
+	sum := sha256.Sum256(body)
+
+	c.Header("Content-Type", contentType)
+	c.Header("Cache-Control", "public, no-cache")
+	c.Header("ETag", lib.ETag(hex.EncodeToString(sum[:16]), 0, modTime))
+	http.ServeContent(c.Writer, c.Request, "", modTime, bytes.NewReader(body))
+}
+
+// siteURL - configured public URL of the API
+//
+// The Host header of the request is never trusted, a forged one
+// would end up in cached feeds and sitemaps
+func siteURL() string {
+	return config.GetConfig().Post.SiteURL
+}
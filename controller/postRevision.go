@@ -0,0 +1,46 @@
+package controller
+
+import (
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	grenderer "github.com/tinkerbaj/gintemp/lib/renderer"
+
+	"github.com/tinkerbaj/gintemp/handler"
+)
+
+// GetPostRevisions - GET /posts/:id/revisions
+//
+// Every version of the post, newest first, authors only
+func GetPostRevisions(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.GetPostRevisions(c.GetUint("userID"), id)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// DiffPostRevisions - GET /posts/:id/revisions/diff?from=&to=
+//
+// `from` and `to`: revision numbers, lines are marked with
+// `=` (unchanged), `-` (removed) or `+` (added)
+func DiffPostRevisions(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.DiffPostRevisions(c.GetUint("userID"), id, c.Query("from"), c.Query("to"))
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// RestorePostRevision - POST /posts/:id/revisions/:number/restore
+//
+// The content of the old revision becomes the newest revision
+func RestorePostRevision(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+	number := strings.TrimSpace(c.Params.ByName("number"))
+
+	resp, statusCode := handler.RestorePostRevision(c.GetUint("userID"), id, number)
+
+	grenderer.Render(c, resp, statusCode)
+}
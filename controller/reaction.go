@@ -0,0 +1,39 @@
+package controller
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	grenderer "github.com/tinkerbaj/gintemp/lib/renderer"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/handler"
+)
+
+// SetPostReaction - PUT /posts/:id/reaction
+//
+// Accepted JSON: `kind`: like, love, laugh, wow, sad or angry,
+// replaces the previous reaction of the user
+func SetPostReaction(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+	req := model.PostReactionRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.SetPostReaction(c.GetUint("userID"), id, req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// DeletePostReaction - DELETE /posts/:id/reaction
+func DeletePostReaction(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.DeletePostReaction(c.GetUint("userID"), id)
+
+	grenderer.Render(c, resp, statusCode)
+}
@@ -0,0 +1,103 @@
+package controller
+
+import (
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+
+	grenderer "github.com/tinkerbaj/gintemp/lib/renderer"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/handler"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// GetSitemapIndex - GET /sitemap.xml
+//
+// Index of the sitemaps of the published posts, the user and
+// shop profiles and the category pages, served only when
+// SITE_URL is set
+func GetSitemapIndex(c *gin.Context) {
+	sitemaps, resp, statusCode := handler.GetSitemapIndex(siteURL())
+	if statusCode != http.StatusOK {
+		grenderer.Render(c, resp, statusCode)
+		return
+	}
+
+	body, err := lib.SitemapIndex(sitemaps)
+	if err != nil {
+		log.WithError(err).Error("error code: 1741")
+		grenderer.Render(c, gin.H{"message": "internal server error"}, http.StatusInternalServerError)
+		return
+	}
+
+	serveGenerated(c, "application/xml; charset=utf-8", latestSitemapURL(sitemaps), body)
+}
+
+// GetSitemap - GET /sitemaps/:name
+//
+// `name`: sitemap of the index, i.e. posts-1.xml
+func GetSitemap(c *gin.Context) {
+	urls, resp, statusCode := handler.GetSitemap(siteURL(), c.Params.ByName("name"))
+	if statusCode != http.StatusOK {
+		grenderer.Render(c, resp, statusCode)
+		return
+	}
+
+	body, err := lib.Sitemap(urls)
+	if err != nil {
+		log.WithError(err).Error("error code: 1742")
+		grenderer.Render(c, gin.H{"message": "internal server error"}, http.StatusInternalServerError)
+		return
+	}
+
+	serveGenerated(c, "application/xml; charset=utf-8", latestSitemapURL(urls), body)
+}
+
+// latestSitemapURL - time of the latest change
+func latestSitemapURL(urls []lib.SitemapURL) (latest time.Time) {
+	for _, url := range urls {
+		if url.LastMod.After(latest) {
+			latest = url.LastMod
+		}
+	}
+	return
+}
+
+// GetRobots - GET /robots.txt
+//
+// Content of ROBOTS_TXT_FILE, otherwise the paths of
+// ROBOTS_DISALLOW are closed to all crawlers which are
+// pointed at the sitemap when there is one
+func GetRobots(c *gin.Context) {
+	serverConfig := config.GetConfig().Server
+
+	if serverConfig.RobotsFile != "" {
+		robots, err := os.ReadFile(serverConfig.RobotsFile)
+		if err != nil {
+			log.WithError(err).Error("error code: 1743")
+			c.String(http.StatusInternalServerError, "internal server error")
+			return
+		}
+		c.Data(http.StatusOK, "text/plain; charset=utf-8", robots)
+		return
+	}
+
+	robots := strings.Builder{}
+	robots.WriteString("User-agent: *\n")
+	if len(serverConfig.RobotsDisallow) == 0 {
+		robots.WriteString("Disallow:\n")
+	}
+	for _, p := range serverConfig.RobotsDisallow {
+		robots.WriteString("Disallow: " + p + "\n")
+	}
+	if config.IsRDBMS() && config.IsSiteURL() {
+		robots.WriteString("\nSitemap: " + siteURL() + "/sitemap.xml\n")
+	}
+
+	c.String(http.StatusOK, robots.String())
+}
@@ -0,0 +1,130 @@
+package controller
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+
+	grenderer "github.com/tinkerbaj/gintemp/lib/renderer"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/handler"
+)
+
+// GetCategories - GET /categories
+//
+// Tree of all categories with the number of published posts
+func GetCategories(c *gin.Context) {
+	resp, statusCode := handler.GetCategories()
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// CreateCategory - POST /categories
+//
+// Accepted JSON: `name`, `slug`: made of the name when omitted,
+// `parentID`, `description`, admins only
+func CreateCategory(c *gin.Context) {
+	req := model.CategoryRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.CreateCategory(c.GetUint("userID"), req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// UpdateCategory - PUT /categories/:id
+//
+// Accepts the fields of CreateCategory, the slug is kept when
+// omitted, admins only
+func UpdateCategory(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+	req := model.CategoryRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.UpdateCategory(c.GetUint("userID"), id, req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// DeleteCategory - DELETE /categories/:id
+//
+// Sub-categories move up to the parent, admins only
+func DeleteCategory(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.DeleteCategory(c.GetUint("userID"), id)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// GetTags - GET /tags?limit=
+//
+// Tag cloud, tags with the number of published posts, most
+// used first
+func GetTags(c *gin.Context) {
+	limit := 0
+	if s := strings.TrimSpace(c.Query("limit")); s != "" {
+		var err error
+		if limit, err = strconv.Atoi(s); err != nil {
+			grenderer.Render(c, gin.H{"message": "limit must be a number"}, http.StatusBadRequest)
+			return
+		}
+	}
+
+	resp, statusCode := handler.GetTags(limit)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// CreateTag - POST /tags
+//
+// Accepted JSON: `name`, `slug`: made of the name when omitted,
+// admins only
+func CreateTag(c *gin.Context) {
+	req := model.TagRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.CreateTag(c.GetUint("userID"), req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// UpdateTag - PUT /tags/:id
+//
+// Accepts the fields of CreateTag, the slug is kept when
+// omitted, admins only
+func UpdateTag(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+	req := model.TagRequest{}
+	if err := c.ShouldBindJSON(&req); err != nil {
+		grenderer.Render(c, gin.H{"message": err.Error()}, http.StatusBadRequest)
+		return
+	}
+
+	resp, statusCode := handler.UpdateTag(c.GetUint("userID"), id, req)
+
+	grenderer.Render(c, resp, statusCode)
+}
+
+// DeleteTag - DELETE /tags/:id
+//
+// The tag is removed from all posts, admins only
+func DeleteTag(c *gin.Context) {
+	id := strings.TrimSpace(c.Params.ByName("id"))
+
+	resp, statusCode := handler.DeleteTag(c.GetUint("userID"), id)
+
+	grenderer.Render(c, resp, statusCode)
+}
@@ -0,0 +1,57 @@
+package database
+
+import (
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// blobIndex - reference counts of the media blobs kept in
+// the media_blobs table
+type blobIndex struct{}
+
+// Acquire adds a reference to a blob
+func (blobIndex) Acquire(digest string, size int64) error {
+	db := GetDB()
+
+	for retry := false; ; retry = true {
+		result := db.Model(&model.MediaBlob{}).Where("digest = ?", digest).UpdateColumn("refs", gorm.Expr("refs + ?", 1))
+		if result.Error != nil || result.RowsAffected > 0 {
+			return result.Error
+		}
+
+		err := db.Create(&model.MediaBlob{Digest: digest, Size: size, Refs: 1}).Error
+		if err == nil || retry {
+			return err
+		}
+		// created by a concurrent upload in the meantime
+	}
+}
+
+// Release drops a reference to a blob, the row goes with the
+// last one after remove has taken the blob away
+//
+// The row stays locked meanwhile, an upload of the same content
+// waits for it and stores the blob again. Blobs without a row
+// were never counted and are kept.
+func (blobIndex) Release(digest string, remove func() error) error {
+	return GetDB().Transaction(func(tx *gorm.DB) error {
+		result := tx.Model(&model.MediaBlob{}).Where("digest = ? AND refs > 0", digest).UpdateColumn("refs", gorm.Expr("refs - ?", 1))
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+
+		blob := model.MediaBlob{}
+		if err := tx.Where("digest = ?", digest).First(&blob).Error; err != nil {
+			return err
+		}
+		if blob.Refs > 0 {
+			return nil
+		}
+
+		if err := remove(); err != nil {
+			return err
+		}
+		return tx.Delete(&blob).Error
+	})
+}
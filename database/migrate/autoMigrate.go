@@ -15,7 +15,20 @@ type twoFABackup model.TwoFABackup
 type tempEmail model.TempEmail
 type user model.User
 type post model.Post
+type postRevision model.PostRevision
+type postMedia model.PostMedia
+type postReaction model.PostReaction
+type postView model.PostView
+type comment model.Comment
+type category model.Category
+type tag model.Tag
 type hobby model.Hobby
+type media model.Media
+type mediaTag model.MediaTag
+type mediaTrash model.MediaTrash
+type mediaQuota model.MediaQuota
+type mediaBlob model.MediaBlob
+type mediaUpload model.MediaUpload
 
 // type auth model.Auth
 // type twoFA model.TwoFA
@@ -25,8 +38,30 @@ type hobby model.Hobby
 func DropAllTables() error {
 	db := database.GetDB()
 
+	if err := dropPostSearchIndex(db); err != nil {
+		return err
+	}
+
+	// join tables of the post taxonomy
+	if err := db.Migrator().DropTable("post_tags", "post_categories"); err != nil {
+		return err
+	}
+
 	if err := db.Migrator().DropTable(
+		&postView{},
+		&postReaction{},
+		&postMedia{},
+		&mediaUpload{},
+		&mediaBlob{},
+		&mediaQuota{},
+		&mediaTrash{},
+		&mediaTag{},
+		&media{},
 		&hobby{},
+		&comment{},
+		&postRevision{},
+		&tag{},
+		&category{},
 		&post{},
 		&user{},
 		&tempEmail{},
@@ -56,12 +91,29 @@ func StartMigration(configure config.Configuration) error {
 			&twoFABackup{},
 			&tempEmail{},
 			&user{},
+			&category{},
+			&tag{},
 			&post{},
+			&postRevision{},
+			&comment{},
 			&hobby{},
+			&media{},
+			&mediaTag{},
+			&mediaTrash{},
+			&mediaQuota{},
+			&mediaBlob{},
+			&mediaUpload{},
+			&postMedia{},
+			&postReaction{},
+			&postView{},
 		); err != nil {
 			return err
 		}
 
+		if err := createPostSearchIndex(db); err != nil {
+			return err
+		}
+
 		fmt.Println("new tables are  migrated successfully!")
 		return nil
 	}
@@ -71,12 +123,29 @@ func StartMigration(configure config.Configuration) error {
 		&twoFABackup{},
 		&tempEmail{},
 		&user{},
+		&category{},
+		&tag{},
 		&post{},
+		&postRevision{},
+		&comment{},
 		&hobby{},
+		&media{},
+		&mediaTag{},
+		&mediaTrash{},
+		&mediaQuota{},
+		&mediaBlob{},
+		&mediaUpload{},
+		&postMedia{},
+		&postReaction{},
+		&postView{},
 	); err != nil {
 		return err
 	}
 
+	if err := createPostSearchIndex(db); err != nil {
+		return err
+	}
+
 	fmt.Println("new tables are  migrated successfully!")
 	return nil
 }
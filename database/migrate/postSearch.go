@@ -0,0 +1,86 @@
+package migrate
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// createPostSearchIndex - full-text index of the title and body
+// of the posts in the engine's native format
+//
+// SQLite needs FTS5, which go-sqlite3 only compiles in with the
+// build tag sqlite_fts5. A binary built without it refuses to
+// migrate: go build -tags sqlite_fts5
+func createPostSearchIndex(db *gorm.DB) error {
+	switch db.Dialector.Name() {
+	case "mysql":
+		if db.Migrator().HasIndex(&post{}, "idx_posts_fulltext") {
+			return nil
+		}
+		return db.Exec("ALTER TABLE posts ADD FULLTEXT INDEX idx_posts_fulltext (title, body)").Error
+
+	case "postgres":
+		// the title weighs more than the body when ranking
+		err := db.Exec(`ALTER TABLE posts ADD COLUMN IF NOT EXISTS search_vector tsvector
+			GENERATED ALWAYS AS (
+				setweight(to_tsvector('simple', coalesce(title, '')), 'A') ||
+				setweight(to_tsvector('simple', coalesce(body, '')), 'B')
+			) STORED`).Error
+		if err != nil {
+			return err
+		}
+		return db.Exec("CREATE INDEX IF NOT EXISTS idx_posts_search ON posts USING GIN (search_vector)").Error
+
+	case "sqlite":
+		if db.Migrator().HasTable(model.PostSearchTable) {
+			return nil
+		}
+
+		err := db.Exec("CREATE VIRTUAL TABLE " + model.PostSearchTable + " USING fts5(title, body, content='posts', content_rowid='id')").Error
+		if err != nil {
+			if strings.Contains(err.Error(), "no such module") {
+				return errors.New("SQLite is built without FTS5, build with -tags sqlite_fts5 for the post search")
+			}
+			return err
+		}
+
+		// keep the external content table in sync with the posts
+		return db.Transaction(func(tx *gorm.DB) error {
+			for _, stmt := range []string{
+				`CREATE TRIGGER posts_fts_insert AFTER INSERT ON posts BEGIN
+					INSERT INTO posts_fts(rowid, title, body) VALUES (new.id, new.title, new.body);
+				END`,
+				`CREATE TRIGGER posts_fts_delete AFTER DELETE ON posts BEGIN
+					INSERT INTO posts_fts(posts_fts, rowid, title, body) VALUES ('delete', old.id, old.title, old.body);
+				END`,
+				`CREATE TRIGGER posts_fts_update AFTER UPDATE OF title, body ON posts BEGIN
+					INSERT INTO posts_fts(posts_fts, rowid, title, body) VALUES ('delete', old.id, old.title, old.body);
+					INSERT INTO posts_fts(rowid, title, body) VALUES (new.id, new.title, new.body);
+				END`,
+				// index the posts written so far
+				"INSERT INTO posts_fts(posts_fts) VALUES ('rebuild')",
+			} {
+				if err := tx.Exec(stmt).Error; err != nil {
+					return err
+				}
+			}
+			return nil
+		})
+	}
+
+	return nil
+}
+
+// dropPostSearchIndex - FTS5 table of SQLite, the other engines
+// drop their index with the posts table
+func dropPostSearchIndex(db *gorm.DB) error {
+	if db.Dialector.Name() != "sqlite" {
+		return nil
+	}
+
+	return db.Exec("DROP TABLE IF EXISTS " + model.PostSearchTable).Error
+}
@@ -0,0 +1,37 @@
+package model
+
+import (
+	"time"
+
+	"gorm.io/gorm"
+)
+
+// Comment states
+const (
+	CommentPending  string = "pending"  // waiting in the moderation queue
+	CommentApproved string = "approved" // visible to everyone
+	CommentRejected string = "rejected" // only visible to the author
+)
+
+// Comment model - `comments` table
+type Comment struct {
+	gorm.Model
+	PostID   uint       `gorm:"index" json:"postID"`
+	UserID   uint       `gorm:"index" json:"authorID"`
+	ParentID *uint      `gorm:"index" json:"parentID,omitempty"` // comment replied to
+	Body     string     `json:"body"`
+	Status   string     `gorm:"index;size:16" json:"status"`
+	EditedAt *time.Time `json:"editedAt,omitempty"`
+
+	ModeratedBy *uint      `json:"moderatedBy,omitempty"`
+	ModeratedAt *time.Time `json:"moderatedAt,omitempty"`
+
+	Deleted bool      `gorm:"-" json:"deleted,omitempty"` // kept as a placeholder for its replies
+	Replies []Comment `gorm:"-" json:"replies,omitempty"`
+}
+
+// CommentRequest - new comment or a changed text
+type CommentRequest struct {
+	Body     string `json:"body"`
+	ParentID *uint  `json:"parentID,omitempty"`
+}
@@ -1,9 +1,212 @@
 package model
 
-// Hobby model - `hobbies` table
+import (
+	"encoding/json"
+	"time"
+)
+
+// Media model - `media` table
+//
+// One row per file or folder of the media library
 type Media struct {
-	Name     string  `json:"name"`
-	Type     string  `json:"type"`
-	IsFolder bool    `json:"isFolder"`
-	Children []Media `json:"children,omitempty"`
+	ID        uint      `gorm:"primarykey" json:"id,omitempty"`
+	CreatedAt time.Time `json:"createdAt,omitempty"`
+	UpdatedAt time.Time `json:"updatedAt,omitempty"`
+
+	OwnerID  uint   `gorm:"index" json:"ownerID,omitempty"`
+	Name     string `json:"name"`
+	Folder   string `gorm:"index;size:512" json:"folder"`
+	Path     string `gorm:"uniqueIndex;size:512" json:"path,omitempty"`
+	Type     string `gorm:"index;size:32" json:"type"`
+	IsFolder bool   `json:"isFolder"`
+	Size     int64  `json:"size,omitempty"`
+	Checksum string `gorm:"index;size:64" json:"checksum,omitempty"` // sha256, hex
+	MimeType string `gorm:"index;size:128" json:"mimeType,omitempty"`
+	Width    int    `json:"width,omitempty"`
+	Height   int    `json:"height,omitempty"`
+	AltText  string `json:"altText,omitempty"`
+	Private  bool   `gorm:"index" json:"private"` // only served through signed URLs, with everything inside
+
+	Orientation int        `json:"orientation,omitempty"` // EXIF orientation of the upload, already applied when the metadata is stripped
+	CapturedAt  *time.Time `json:"capturedAt,omitempty"`  // when the picture was taken, from EXIF
+
+	Tags     []MediaTag     `gorm:"foreignKey:MediaID;constraint:OnUpdate:CASCADE,OnDelete:CASCADE" json:"tags,omitempty"`
+	Variants []MediaVariant `gorm:"serializer:json;type:text" json:"variants,omitempty"`
+	Children []Media        `gorm:"-" json:"children,omitempty"`
+
+	ModTime    time.Time `gorm:"-" json:"modTime,omitempty"`
+	NextCursor string    `gorm:"-" json:"nextCursor,omitempty"` // more children to fetch
+}
+
+// MediaTag model - `media_tags` table
+type MediaTag struct {
+	ID      uint   `gorm:"primarykey"`
+	MediaID uint   `gorm:"index"`
+	Name    string `gorm:"index;size:64"`
+}
+
+// MarshalJSON - a tag is rendered as its name
+func (t MediaTag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Name)
+}
+
+// UnmarshalJSON - a tag is accepted as its name
+func (t *MediaTag) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &t.Name)
+}
+
+// MediaVariant - resized copy of an image
+type MediaVariant struct {
+	Name     string `json:"name"`
+	MimeType string `json:"mimeType"`
+	Width    int    `json:"width"`
+	Height   int    `json:"height"`
+	Size     int64  `json:"size"`
+	URL      string `json:"url,omitempty"`
+}
+
+// MediaMeta - editable metadata of a media entry
+type MediaMeta struct {
+	Path    string   `json:"path"`
+	AltText *string  `json:"altText,omitempty"`
+	Private *bool    `json:"private,omitempty"`
+	Tags    []string `json:"tags,omitempty"`
+}
+
+// MediaFilter - search parameters of the media list
+type MediaFilter struct {
+	Path  string   `form:"path"`
+	Query string   `form:"q"`
+	Tags  []string `form:"tag"`
+	Type  string   `form:"type"` // extension, MIME type, MIME family (image/*) or folder
+
+	Sort   string `form:"sort"`  // name, size or mtime
+	Order  string `form:"order"` // asc or desc
+	Limit  int    `form:"limit"`
+	Cursor string `form:"cursor"`
+	Depth  int    `form:"depth"` // levels of sub-folders to include, 1 by default
+}
+
+// IsSearch returns true when the list must be served from
+// the stored metadata instead of the storage
+func (f MediaFilter) IsSearch() bool {
+	return f.Query != "" || len(f.Tags) > 0
+}
+
+// MediaPage - one page of search results
+type MediaPage struct {
+	Items      []Media `json:"items"`
+	NextCursor string  `json:"nextCursor,omitempty"`
+}
+
+// MediaTransfer - move or copy an entry into another folder
+type MediaTransfer struct {
+	Path   string `json:"path"`
+	Folder string `json:"folder"` // target folder, "" for the root
+}
+
+// MediaTrash model - `media_trashes` table
+//
+// One row per deleted file or folder until it is restored
+// or purged
+type MediaTrash struct {
+	ID           uint      `gorm:"primarykey" json:"id"`
+	CreatedAt    time.Time `gorm:"index" json:"deletedAt"`
+	DeletedBy    uint      `gorm:"index" json:"deletedBy,omitempty"`
+	OriginalPath string    `gorm:"size:512" json:"originalPath"`
+	TrashPath    string    `gorm:"uniqueIndex;size:512" json:"-"`
+	IsFolder     bool      `json:"isFolder"`
+	Size         int64     `json:"size,omitempty"`
+	PurgeAt      time.Time `gorm:"-" json:"purgeAt"`
+	UsedBy       []uint    `gorm:"-" json:"usedBy,omitempty"` // posts which show the entry again once it is restored
+}
+
+// MediaSignRequest - ask for a signed URL of a private file
+type MediaSignRequest struct {
+	Path string `json:"path"`
+	TTL  int64  `json:"ttl,omitempty"` // lifetime in seconds
+}
+
+// MediaSignedURL - temporary URL of a private file, the same
+// expires and signature parameters are accepted by the
+// variant URLs of the file
+type MediaSignedURL struct {
+	URL       string    `json:"url"`
+	Expires   int64     `json:"expires"`
+	Signature string    `json:"signature"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// MediaQuota model - `media_quota` table
+//
+// Storage quota of one user in place of the configured default
+type MediaQuota struct {
+	UserID    uint      `gorm:"primaryKey;autoIncrement:false" json:"userID"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	Quota     int64     `json:"quota"` // bytes, 0 for no limit
+}
+
+// MediaUsage - storage used by one user, trashed files count
+// until they are purged
+type MediaUsage struct {
+	UserID  uint  `json:"userID"`
+	Files   int64 `json:"files"`
+	Used    int64 `json:"used"`
+	Trashed int64 `json:"trashed"`
+	Quota   int64 `json:"quota"` // bytes, 0 for no limit
+
+	Namespace string `json:"namespace,omitempty"` // folder of a shop, it cannot change anything outside
+}
+
+// MediaFolder - folder to create
+type MediaFolder struct {
+	Path string `json:"path"`
+}
+
+// MediaRename - new path of a file or folder
+type MediaRename struct {
+	Old string `json:"old"`
+	New string `json:"new"`
+}
+
+// MediaImport - outcome of a ZIP import
+type MediaImport struct {
+	Folder  string            `json:"folder"`
+	Created []string          `json:"created"`
+	Skipped []MediaImportSkip `json:"skipped,omitempty"`
+}
+
+// MediaImportSkip - entry of a ZIP archive which was not imported
+type MediaImportSkip struct {
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// MediaBlob model - `media_blobs` table
+//
+// Content shared by all files with the same SHA-256 digest,
+// removed with the last file pointing at it
+type MediaBlob struct {
+	Digest    string    `gorm:"primaryKey;size:64" json:"digest"`
+	CreatedAt time.Time `json:"createdAt"`
+	Size      int64     `json:"size"`
+	Refs      int64     `json:"refs"`
+}
+
+// MediaUpload model - `media_uploads` table
+//
+// Resumable upload in progress, the data received so far is
+// kept in a local file named after the ID, its size is the
+// offset to resume from
+type MediaUpload struct {
+	ID        string    `gorm:"primaryKey;size:36" json:"id"`
+	CreatedAt time.Time `json:"createdAt"`
+	ExpiresAt time.Time `gorm:"index" json:"expiresAt"`
+	OwnerID   uint      `gorm:"index" json:"ownerID"`
+	Folder    string    `gorm:"size:512" json:"folder"`
+	Name      string    `json:"name"`
+	Private   bool      `json:"private"`
+	Length    int64     `json:"length"`
+	Offset    int64     `gorm:"-" json:"offset"`
+	Media     *Media    `gorm:"-" json:"media,omitempty"` // the stored file once complete
 }
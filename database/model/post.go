@@ -1,13 +1,187 @@
 package model
 
 import (
+	"encoding/json"
+	"time"
+
 	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// Post states
+const (
+	PostDraft     string = "draft"     // only visible to the author
+	PostScheduled string = "scheduled" // published at PublishAt
+	PostPublished string = "published" // visible to everyone
+	PostArchived  string = "archived"  // no longer listed, only visible to the author
 )
 
+// PostSearchTable - FTS5 table indexing the posts in SQLite
+const PostSearchTable string = "posts_fts"
+
 // Post model - `posts` table
 type Post struct {
 	gorm.Model
-	Title     string         `json:"title,omitempty" structs:"title,omitempty"`
-	Body      string         `json:"body,omitempty" structs:"body,omitempty"`
-	UserID    uint     `json:"-"`
+	Title  string `json:"title,omitempty" structs:"title,omitempty"`
+	Body   string `json:"body,omitempty" structs:"body,omitempty"`
+	UserID uint   `gorm:"index" json:"authorID,omitempty"`
+
+	Slug      *string    `gorm:"uniqueIndex;size:191" json:"slug,omitempty" structs:"slug,omitempty"`
+	Status    string     `gorm:"index;size:16;default:published" json:"status,omitempty" structs:"status,omitempty"` // posts written before states existed stay live
+	PublishAt *time.Time `gorm:"index" json:"publishAt,omitempty" structs:"publishAt,omitempty"`
+
+	// Body is Markdown, BodyHTML the sanitized HTML of it
+	BodyHTML string `gorm:"-" json:"bodyHTML,omitempty" structs:"bodyHTML,omitempty"`
+
+	Categories []Category `gorm:"many2many:post_categories;constraint:OnDelete:CASCADE" json:"categories,omitempty" structs:"categories,omitempty"` // IDs or slugs when writing
+	Tags       []Tag      `gorm:"many2many:post_tags;constraint:OnDelete:CASCADE" json:"tags,omitempty" structs:"tags,omitempty"`                   // names, missing tags are created
+
+	// files of the media library, paths or IDs when writing,
+	// an empty cover removes it
+	Cover       *PostAttachment  `gorm:"-" json:"cover,omitempty" structs:"cover,omitempty"`
+	Attachments []PostAttachment `gorm:"-" json:"attachments,omitempty" structs:"attachments,omitempty"` // gallery in order
+
+	Stats *PostStats `gorm:"-" json:"stats,omitempty" structs:"stats,omitempty"` // views and reactions, only with a single post
+}
+
+// IsPostStatus returns true for the known post states
+func IsPostStatus(status string) bool {
+	switch status {
+	case PostDraft, PostScheduled, PostPublished, PostArchived:
+		return true
+	}
+	return false
+}
+
+// PostRevision model - `post_revisions` table, the content
+// of a post after each change
+type PostRevision struct {
+	ID           uint      `gorm:"primarykey" json:"-"`
+	CreatedAt    time.Time `json:"createdAt"`
+	PostID       uint      `gorm:"uniqueIndex:idx_post_revision_number" json:"-"`
+	Number       int       `gorm:"uniqueIndex:idx_post_revision_number" json:"number"` // 1 for the first version
+	UserID       uint      `json:"authorID"`
+	Title        string    `json:"title"`
+	Body         string    `json:"body"`
+	RestoredFrom *int      `json:"restoredFrom,omitempty"` // number of the restored revision
+
+	// rendered body, outdated when HTMLKey differs from the
+	// key of the current renderer
+	BodyHTML string `json:"-"`
+	HTMLKey  string `gorm:"size:64" json:"-"`
+}
+
+// PostMedia model - `post_media` table, links a post with
+// entries of the media library
+//
+// Position 0 is the cover image, the attachments of the
+// gallery follow from 1
+type PostMedia struct {
+	ID       uint `gorm:"primarykey"`
+	PostID   uint `gorm:"uniqueIndex:idx_post_media_position"`
+	Position int  `gorm:"uniqueIndex:idx_post_media_position"`
+	MediaID  uint `gorm:"index"`
+}
+
+// PostAttachment - media entry linked to a post with the URLs
+// it is served from
+type PostAttachment struct {
+	MediaID  uint           `json:"mediaID,omitempty" structs:"mediaID,omitempty"`
+	Path     string         `json:"path" structs:"path"`
+	Name     string         `json:"name,omitempty" structs:"name,omitempty"`
+	MimeType string         `json:"mimeType,omitempty" structs:"mimeType,omitempty"`
+	Size     int64          `json:"size,omitempty" structs:"size,omitempty"`
+	Width    int            `json:"width,omitempty" structs:"width,omitempty"`
+	Height   int            `json:"height,omitempty" structs:"height,omitempty"`
+	AltText  string         `json:"altText,omitempty" structs:"altText,omitempty"`
+	URL      string         `json:"url,omitempty" structs:"url,omitempty"`
+	Variants []MediaVariant `json:"variants,omitempty" structs:"variants,omitempty"`
+}
+
+// UnmarshalJSON - an attachment is also accepted as its path
+// or media ID
+func (a *PostAttachment) UnmarshalJSON(b []byte) error {
+	switch {
+	case len(b) > 0 && b[0] == '"':
+		return json.Unmarshal(b, &a.Path)
+	case len(b) > 0 && b[0] >= '0' && b[0] <= '9':
+		return json.Unmarshal(b, &a.MediaID)
+	}
+
+	type attachment PostAttachment
+	return json.Unmarshal(b, (*attachment)(a))
+}
+
+// PostRevisionDiff - changes between two revisions
+type PostRevisionDiff struct {
+	From  int            `json:"from"`
+	To    int            `json:"to"`
+	Title []lib.DiffLine `json:"title"`
+	Body  []lib.DiffLine `json:"body"`
+}
+
+// PostFilter - query parameters of the post list
+type PostFilter struct {
+	Author uint   `form:"author"` // user ID
+	From   string `form:"from"`   // created at or after, RFC 3339 or YYYY-MM-DD
+	To     string `form:"to"`     // created before, a date includes the whole day
+	Title  string `form:"title"`  // part of the title, case-insensitive
+	Status string `form:"status"` // authors may list their posts of any state
+
+	Category string `form:"category"` // slug, sub-categories included
+	Tag      string `form:"tag"`      // slug
+
+	Sort   string `form:"sort"`  // created or updated
+	Order  string `form:"order"` // asc or desc, newest first by default
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+	Cursor string `form:"cursor"` // nextCursor of the previous page
+}
+
+// FeedFilter - query parameters of the post feeds
+type FeedFilter struct {
+	Author string `form:"author"` // user ID or username
+	Tag    string `form:"tag"`    // slug
+}
+
+// PostSearch - query parameters of the post search
+type PostSearch struct {
+	Q      string `form:"q"` // words to find, all of them must match
+	Limit  int    `form:"limit"`
+	Offset int    `form:"offset"`
+}
+
+// PostSearchHit - post found by the search, best match first
+type PostSearchHit struct {
+	ID        uint       `json:"id"`
+	Title     string     `json:"title"`
+	Slug      *string    `json:"slug,omitempty"`
+	UserID    uint       `json:"authorID"`
+	PublishAt *time.Time `json:"publishAt,omitempty"`
+	Score     float64    `json:"score"` // relevance, only comparable within one search
+
+	// HTML-escaped, the matches are wrapped in <mark></mark>
+	TitleHighlight string `json:"titleHighlight"`
+	Snippet        string `json:"snippet"`
+}
+
+// PostSearchPage - one page of search results
+type PostSearchPage struct {
+	Items []PostSearchHit `json:"items"`
+	Meta  PageMeta        `json:"meta"`
+}
+
+// PostPage - one page of posts
+type PostPage struct {
+	Items []Post   `json:"items"`
+	Meta  PageMeta `json:"meta"`
+}
+
+// PageMeta - position of a page in the whole list
+type PageMeta struct {
+	Total      int64  `json:"total"` // items matching the filters
+	Limit      int    `json:"limit"`
+	Offset     int    `json:"offset,omitempty"`
+	NextCursor string `json:"nextCursor,omitempty"` // more items to fetch
 }
@@ -0,0 +1,61 @@
+package model
+
+import "time"
+
+// Reaction kinds
+const (
+	ReactionLike  string = "like"
+	ReactionLove  string = "love"
+	ReactionLaugh string = "laugh"
+	ReactionWow   string = "wow"
+	ReactionSad   string = "sad"
+	ReactionAngry string = "angry"
+)
+
+// ReactionKinds - the known reactions in the order they are
+// shown
+var ReactionKinds = []string{ReactionLike, ReactionLove, ReactionLaugh, ReactionWow, ReactionSad, ReactionAngry}
+
+// IsReactionKind returns true for the known reactions
+func IsReactionKind(kind string) bool {
+	for _, known := range ReactionKinds {
+		if kind == known {
+			return true
+		}
+	}
+	return false
+}
+
+// PostReaction model - `post_reactions` table, one reaction
+// per user and post
+type PostReaction struct {
+	ID        uint      `gorm:"primarykey" json:"-"`
+	CreatedAt time.Time `json:"createdAt"`
+	UpdatedAt time.Time `json:"updatedAt"`
+	PostID    uint      `gorm:"uniqueIndex:idx_post_reaction_user" json:"postID"`
+	UserID    uint      `gorm:"uniqueIndex:idx_post_reaction_user;index" json:"userID"`
+	Kind      string    `gorm:"size:16" json:"kind"`
+}
+
+// PostReactionRequest - reaction of the user to a post,
+// replaces the previous one
+type PostReactionRequest struct {
+	Kind string `json:"kind"`
+}
+
+// PostView model - `post_views` table, views of a post written
+// from the buffer
+//
+// Kept apart from the posts so that saving a post never
+// overwrites a count
+type PostView struct {
+	PostID uint  `gorm:"primaryKey;autoIncrement:false"`
+	Views  int64 `gorm:"not null;default:0"`
+}
+
+// PostStats - engagement of a post
+type PostStats struct {
+	Views      int64            `json:"views" structs:"views"`
+	Reactions  map[string]int64 `json:"reactions" structs:"reactions"`                       // count per kind, every kind is listed
+	MyReaction string           `json:"myReaction,omitempty" structs:"myReaction,omitempty"` // reaction of the caller
+}
@@ -0,0 +1,70 @@
+package model
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// Category model - `categories` table, categories nest
+type Category struct {
+	ID          uint      `gorm:"primarykey" json:"id"`
+	CreatedAt   time.Time `json:"-"`
+	UpdatedAt   time.Time `json:"-"`
+	ParentID    *uint     `gorm:"index" json:"parentID,omitempty"`
+	Name        string    `gorm:"size:100" json:"name"`
+	Slug        string    `gorm:"uniqueIndex;size:191" json:"slug"`
+	Description string    `json:"description,omitempty"`
+
+	PostCount *int64     `gorm:"-" json:"postCount,omitempty"` // published posts in the category itself
+	Children  []Category `gorm:"-" json:"children,omitempty"`
+}
+
+// UnmarshalJSON - a category is also accepted as its slug
+func (c *Category) UnmarshalJSON(b []byte) error {
+	if len(b) > 0 && b[0] == '"' {
+		return json.Unmarshal(b, &c.Slug)
+	}
+
+	type category Category
+	return json.Unmarshal(b, (*category)(c))
+}
+
+// CategoryRequest - new or changed category, admins only
+type CategoryRequest struct {
+	Name        string  `json:"name"`
+	Slug        *string `json:"slug,omitempty"` // made of the name when omitted
+	ParentID    *uint   `json:"parentID,omitempty"`
+	Description string  `json:"description,omitempty"`
+}
+
+// Tag model - `tags` table, free-form labels of the posts
+type Tag struct {
+	ID        uint `gorm:"primarykey"`
+	CreatedAt time.Time
+	Name      string `gorm:"size:64"`
+	Slug      string `gorm:"uniqueIndex;size:191"`
+}
+
+// MarshalJSON - a tag is rendered as its name
+func (t Tag) MarshalJSON() ([]byte, error) {
+	return json.Marshal(t.Name)
+}
+
+// UnmarshalJSON - a tag is accepted as its name
+func (t *Tag) UnmarshalJSON(b []byte) error {
+	return json.Unmarshal(b, &t.Name)
+}
+
+// TagRequest - new or changed tag, admins only
+type TagRequest struct {
+	Name string  `json:"name"`
+	Slug *string `json:"slug,omitempty"` // made of the name when omitted
+}
+
+// TagCount - tag of the tag cloud
+type TagCount struct {
+	ID        uint   `json:"id"`
+	Name      string `json:"name"`
+	Slug      string `json:"slug"`
+	PostCount int64  `json:"postCount"` // published posts with the tag
+}
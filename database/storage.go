@@ -0,0 +1,80 @@
+package database
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/lib/storage"
+)
+
+// assetsDir - local folder served under /assets
+const assetsDir string = "./public"
+
+// MediaAssetsPrefix - URL path of the local media root when it
+// lives inside the folder served under /assets, "" otherwise
+func MediaAssetsPrefix() string {
+	rel, err := filepath.Rel(assetsDir, config.GetConfig().Media.Root)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, "../") {
+		return ""
+	}
+
+	return "/assets/" + filepath.ToSlash(rel) + "/"
+}
+
+// storageClient variable to access the media storage
+var storageClient storage.Storage
+
+// InitStorage - function to initialize the media storage
+func InitStorage() (storage.Storage, error) {
+	configureMedia := config.GetConfig().Media
+
+	switch configureMedia.Storage {
+	case "s3":
+		s, err := storage.NewS3(storage.S3Config{
+			Endpoint:  configureMedia.S3.Endpoint,
+			Region:    configureMedia.S3.Region,
+			Bucket:    configureMedia.S3.Bucket,
+			AccessKey: configureMedia.S3.AccessKey,
+			SecretKey: configureMedia.S3.SecretKey,
+			Prefix:    configureMedia.S3.Prefix,
+			PathStyle: configureMedia.S3.PathStyle,
+			Timeout:   configureMedia.S3.Timeout,
+		})
+		if err != nil {
+			log.WithError(err).Error("error code: 171")
+			return nil, err
+		}
+		storageClient = s
+
+	default:
+		s, err := storage.NewLocal(configureMedia.Root)
+		if err != nil {
+			log.WithError(err).Error("error code: 172")
+			return nil, err
+		}
+		storageClient = s
+	}
+
+	// the reference counts of the blobs live in the database
+	if configureMedia.Dedup && config.IsRDBMS() {
+		s, err := storage.NewDedup(storageClient, blobIndex{})
+		if err != nil {
+			log.WithError(err).Error("error code: 173")
+			return nil, err
+		}
+		storageClient = s
+	}
+	// Only for debugging
+	fmt.Println("media storage (" + configureMedia.Storage + ") ready!")
+
+	return storageClient, nil
+}
+
+// GetStorage - get the media storage
+func GetStorage() storage.Storage {
+	return storageClient
+}
@@ -0,0 +1,379 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// commentMaxLength - longest comment in characters
+const commentMaxLength int = 5000
+
+// commentMaxDepth - deepest level of replies, top-level
+// comments are level 1
+const commentMaxDepth int = 8
+
+// commentQueueLimit - pending comments returned at once,
+// oldest first
+const commentQueueLimit int = 100
+
+// GetComments handles jobs for controller.GetComments
+//
+// The approved comments of the post as threads, authors also
+// see their own pending and rejected comments. Deleted comments
+// with replies are kept without their text.
+func GetComments(userID uint, postID string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	post := model.Post{}
+	if err := visiblePosts(db, userID).Where("id = ?", postID).First(&post).Error; err != nil {
+		httpResponse.Message = "article not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	query := db.Unscoped().Where("post_id = ?", post.ID)
+	if userID == 0 {
+		query = query.Where("status = ?", model.CommentApproved)
+	} else {
+		query = query.Where("(status = ? OR user_id = ?)", model.CommentApproved, userID)
+	}
+
+	comments := []model.Comment{}
+	if err := query.Order("created_at").Order("id").Find(&comments).Error; err != nil {
+		log.WithError(err).Error("error code: 1501")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = commentThreads(comments)
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// commentThreads - nest the replies under their comments,
+// replies to comments which are not shown are left out
+func commentThreads(comments []model.Comment) []model.Comment {
+	children := map[uint][]int{}
+	roots := []int{}
+	shown := map[uint]bool{}
+	for _, comment := range comments {
+		shown[comment.ID] = true
+	}
+	for i, comment := range comments {
+		switch {
+		case comment.ParentID == nil:
+			roots = append(roots, i)
+		case shown[*comment.ParentID]:
+			children[*comment.ParentID] = append(children[*comment.ParentID], i)
+		}
+	}
+
+	// build returns false for deleted comments without replies
+	var build func(i int) (model.Comment, bool)
+	build = func(i int) (model.Comment, bool) {
+		comment := comments[i]
+		for _, child := range children[comment.ID] {
+			if reply, ok := build(child); ok {
+				comment.Replies = append(comment.Replies, reply)
+			}
+		}
+		if comment.DeletedAt.Valid {
+			if len(comment.Replies) == 0 {
+				return comment, false
+			}
+			comment.Deleted = true
+			comment.Body = ""
+		}
+		return comment, true
+	}
+
+	threads := []model.Comment{}
+	for _, i := range roots {
+		if thread, ok := build(i); ok {
+			threads = append(threads, thread)
+		}
+	}
+
+	return threads
+}
+
+// commentBody - trimmed text of a comment
+func commentBody(body string) (string, model.HTTPResponse, int) {
+	body = strings.TrimSpace(body)
+	if body == "" {
+		return "", model.HTTPResponse{Message: "comment must not be empty"}, http.StatusBadRequest
+	}
+	if utf8.RuneCountInString(body) > commentMaxLength {
+		return "", model.HTTPResponse{Message: "comment must not be longer than " + strconv.Itoa(commentMaxLength) + " characters"}, http.StatusBadRequest
+	}
+
+	return body, model.HTTPResponse{}, http.StatusOK
+}
+
+// CreateComment handles jobs for controller.CreateComment
+//
+// Only published posts can be commented, replies need an
+// approved comment. Comments of admins and the author of the
+// post are approved right away, all others are queued.
+func CreateComment(userIDAuth uint, postID string, req model.CommentRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+	user := model.User{}
+
+	// does the user have an existing profile
+	if err := db.Where("id = ?", userIDAuth).First(&user).Error; err != nil {
+		httpResponse.Message = "no user profile found"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	post := model.Post{}
+	if err := visiblePosts(db, 0).Where("id = ?", postID).First(&post).Error; err != nil {
+		httpResponse.Message = "article not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	body, httpResponse, httpStatusCode := commentBody(req.Body)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if req.ParentID != nil {
+		parent := model.Comment{}
+		err := db.Where("id = ? AND post_id = ? AND status = ?", *req.ParentID, post.ID, model.CommentApproved).First(&parent).Error
+		if err != nil {
+			httpResponse.Message = "comment to reply to not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+
+		// the parent is approved, so are all its ancestors
+		depth := 1
+		for parent.ParentID != nil {
+			depth++
+			if depth >= commentMaxDepth {
+				httpResponse.Message = "replies must not be nested deeper than " + strconv.Itoa(commentMaxDepth) + " levels"
+				httpStatusCode = http.StatusBadRequest
+				return
+			}
+			if err := db.Unscoped().Select("id", "parent_id").Where("id = ?", *parent.ParentID).First(&parent).Error; err != nil {
+				log.WithError(err).Error("error code: 1511")
+				httpResponse.Message = "internal server error"
+				httpStatusCode = http.StatusInternalServerError
+				return
+			}
+		}
+	}
+
+	moderator, err := service.CanModerateComments(user.ID, post.ID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1512")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	comment := model.Comment{
+		PostID:   post.ID,
+		UserID:   user.ID,
+		ParentID: req.ParentID,
+		Body:     body,
+		Status:   model.CommentPending,
+	}
+	if moderator {
+		comment.Status = model.CommentApproved
+	}
+
+	if err := db.Create(&comment).Error; err != nil {
+		log.WithError(err).Error("error code: 1513")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = comment
+	httpStatusCode = http.StatusCreated
+	return
+}
+
+// UpdateComment handles jobs for controller.UpdateComment
+//
+// Authors may change their text within the edit window,
+// comments which need moderation are queued again
+func UpdateComment(userIDAuth uint, id string, req model.CommentRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+	comment := model.Comment{}
+
+	if err := db.Where("id = ? AND user_id = ?", id, userIDAuth).First(&comment).Error; err != nil {
+		httpResponse.Message = "user may not have access to perform this task"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	if time.Since(comment.CreatedAt) > config.GetConfig().Post.CommentEditWindow {
+		httpResponse.Message = "comment can no longer be changed"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	body, httpResponse, httpStatusCode := commentBody(req.Body)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	moderator, err := service.CanModerateComments(userIDAuth, comment.PostID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1521")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	now := time.Now()
+	comment.Body = body
+	comment.EditedAt = &now
+	if !moderator {
+		comment.Status = model.CommentPending
+		comment.ModeratedBy = nil
+		comment.ModeratedAt = nil
+	}
+
+	if err := db.Save(&comment).Error; err != nil {
+		log.WithError(err).Error("error code: 1522")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = comment
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// DeleteComment handles jobs for controller.DeleteComment
+//
+// Authors delete their own comments, moderators every comment
+// of the post. Replies stay visible.
+func DeleteComment(userIDAuth uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+	comment := model.Comment{}
+
+	if err := db.Where("id = ?", id).First(&comment).Error; err != nil {
+		httpResponse.Message = "comment not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	if comment.UserID != userIDAuth {
+		moderator, err := service.CanModerateComments(userIDAuth, comment.PostID)
+		if err != nil {
+			log.WithError(err).Error("error code: 1531")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if !moderator {
+			httpResponse.Message = "user may not have access to perform this task"
+			httpStatusCode = http.StatusForbidden
+			return
+		}
+	}
+
+	if err := db.Delete(&comment).Error; err != nil {
+		log.WithError(err).Error("error code: 1532")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = "comment ID# " + id + " deleted!"
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetCommentQueue handles jobs for controller.GetCommentQueue
+//
+// Pending comments, admins see all of them, authors those on
+// their own posts
+func GetCommentQueue(userIDAuth uint) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	admin, err := service.IsCommentAdmin(userIDAuth)
+	if err != nil {
+		log.WithError(err).Error("error code: 1541")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	query := db.Where("status = ?", model.CommentPending)
+	if !admin {
+		query = query.Where("post_id IN (?)", db.Model(&model.Post{}).Select("id").Where("user_id = ?", userIDAuth))
+	}
+
+	comments := []model.Comment{}
+	if err := query.Order("created_at").Order("id").Limit(commentQueueLimit).Find(&comments).Error; err != nil {
+		log.WithError(err).Error("error code: 1542")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = comments
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// ModerateComment handles jobs for controller.ApproveComment
+// and controller.RejectComment
+func ModerateComment(userIDAuth uint, id, status string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+	comment := model.Comment{}
+
+	if err := db.Where("id = ?", id).First(&comment).Error; err != nil {
+		httpResponse.Message = "comment not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	moderator, err := service.CanModerateComments(userIDAuth, comment.PostID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1551")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if !moderator {
+		httpResponse.Message = "user may not have access to perform this task"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	now := time.Now()
+	comment.Status = status
+	comment.ModeratedBy = &userIDAuth
+	comment.ModeratedAt = &now
+
+	if err := db.Save(&comment).Error; err != nil {
+		log.WithError(err).Error("error code: 1552")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = comment
+	httpStatusCode = http.StatusOK
+	return
+}
@@ -1,13 +1,32 @@
 package handler
 
 import (
-	"encoding/json"
-	"fmt"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"image"
+	"io"
+	"io/fs"
+	"mime/multipart"
 	"net/http"
-	"os"
+	"path"
 	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
 
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
 	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+	"github.com/tinkerbaj/gintemp/lib/storage"
+	"github.com/tinkerbaj/gintemp/service"
+
+	"gorm.io/gorm"
 )
 
 // FileInfo represents information about a file or folder
@@ -38,52 +57,200 @@ import (
 
 //		return info, nil
 //	}
-func GetFileInfo(path string) (model.Media, error) {
-	fileInfo, err := os.Stat(path)
+func GetFileInfo(path string, listing mediaListing) (model.Media, error) {
+	fileInfo, err := database.GetStorage().Stat(path)
 	if err != nil {
 		return model.Media{}, err
 	}
 
 	info := model.Media{
-		Name:     fileInfo.Name(),
-		IsFolder: fileInfo.IsDir(),
+		Name:     fileInfo.Name,
+		IsFolder: fileInfo.IsDir,
+		Path:     mediaPath(path),
+		Size:     fileInfo.Size,
+		ModTime:  fileInfo.ModTime,
 	}
 
 	if info.IsFolder {
-		// Get children info (without recursion)
-		children, err := getChildrenInfo(path)
+		// Get children info, recursing as deep as requested
+		children, next, err := getChildrenInfo(path, listing, listing.after, listing.depth)
 		if err != nil {
 			return info, err
 		}
 		info.Children = children
+		info.NextCursor = next
 	} else {
-		fmt.Println("Why", path)
 		// Get extension for files
 		info.Type = getFileExtension(path)
+
+		// resized copies, if already generated
+		variants, err := service.ReadVariants(info.Path)
+		if err == nil {
+			info.Variants = variants
+		}
+	}
+
+	// stored metadata, if any
+	entries, err := mediaEntries([]string{info.Path})
+	if err != nil {
+		return info, err
+	}
+	if entry, ok := entries[info.Path]; ok {
+		withMeta(&info, entry)
 	}
 
 	return info, nil
 }
 
-func getChildrenInfo(path string) ([]model.Media, error) {
-	var children []model.Media
+// getChildrenInfo - one sorted page of the children of a folder
+// following the cursor position, sub-folders get their own
+// first page until depth is exhausted
+func getChildrenInfo(path string, listing mediaListing, after *mediaCursor, depth int) ([]model.Media, string, error) {
+	children := []model.Media{}
 
-	files, err := os.ReadDir(path)
+	files, err := database.GetStorage().List(path)
 	if err != nil {
-		return nil, err
+		return nil, "", err
+	}
+
+	// originals which already have variants
+	hasVariants := map[string]bool{}
+	for _, file := range files {
+		if file.Name == ".variants" && file.IsDir {
+			generated, err := database.GetStorage().List(path + "/.variants")
+			if err != nil {
+				return nil, "", err
+			}
+			for _, g := range generated {
+				hasVariants[g.Name] = true
+			}
+		}
+	}
+
+	// stored metadata of all children in one query
+	entries, err := mediaFolderEntries(mediaPath(path))
+	if err != nil {
+		return nil, "", err
 	}
 
 	for _, file := range files {
-		//   childPath := filepath.Join(path, file.Name())
+		// hidden entries, i.e. uploads in progress
+		if strings.HasPrefix(file.Name, ".") {
+			continue
+		}
 		childInfo := model.Media{
-			Name:     file.Name(), // Set basic info for child
-			IsFolder: file.IsDir(),
-			Type:     getFileExtension(file.Name()),
+			Name:     file.Name, // Set basic info for child
+			IsFolder: file.IsDir,
+			Type:     getFileExtension(file.Name),
+			Path:     mediaPath(path + "/" + file.Name),
+			Size:     file.Size,
+			ModTime:  file.ModTime,
+		}
+		if entry, ok := entries[childInfo.Path]; ok {
+			withMeta(&childInfo, entry)
+		}
+		if !listing.matches(childInfo) || !listing.isAfter(childInfo, after) {
+			continue
+		}
+		// entries inside a private folder are only reached
+		// through the folder, which is checked the same way
+		if childInfo.Private {
+			allowed, err := service.CanManageMedia(database.GetDB(), listing.userID, childInfo.Path)
+			if err != nil {
+				return nil, "", err
+			}
+			if !allowed {
+				continue
+			}
 		}
 		children = append(children, childInfo)
 	}
 
-	return children, nil
+	listing.sort(children)
+
+	next := ""
+	if len(children) > listing.limit {
+		children = children[:listing.limit]
+		next, err = listing.cursor(children[len(children)-1])
+		if err != nil {
+			return nil, "", err
+		}
+	}
+
+	for i := range children {
+		if children[i].IsFolder && depth > 1 {
+			grandChildren, next, err := getChildrenInfo(path+"/"+children[i].Name, listing, nil, depth-1)
+			if err != nil {
+				return nil, "", err
+			}
+			children[i].Children = grandChildren
+			children[i].NextCursor = next
+		}
+		if hasVariants[children[i].Name] && len(children[i].Variants) == 0 {
+			variants, err := service.ReadVariants(children[i].Path)
+			if err == nil {
+				children[i].Variants = variants
+			}
+		}
+	}
+
+	return children, next, nil
+}
+
+// mediaFolderEntries - stored metadata of the direct children
+// of a folder, keyed by path
+func mediaFolderEntries(folder string) (map[string]model.Media, error) {
+	rows := []model.Media{}
+	if err := database.GetDB().Preload("Tags").Where("folder = ?", folder).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+
+	entries := map[string]model.Media{}
+	for _, row := range rows {
+		entries[row.Path] = row
+	}
+
+	return entries, nil
+}
+
+// mediaEntries - stored metadata of the given paths, keyed by path
+func mediaEntries(paths []string) (map[string]model.Media, error) {
+	entries := map[string]model.Media{}
+	if len(paths) == 0 {
+		return entries, nil
+	}
+
+	rows := []model.Media{}
+	if err := database.GetDB().Preload("Tags").Where("path IN ?", paths).Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		entries[row.Path] = row
+	}
+
+	return entries, nil
+}
+
+// withMeta - copy the stored metadata into an entry read
+// from the storage, the storage stays authoritative for
+// name, path, size and folder flag
+func withMeta(info *model.Media, entry model.Media) {
+	info.ID = entry.ID
+	info.CreatedAt = entry.CreatedAt
+	info.UpdatedAt = entry.UpdatedAt
+	info.OwnerID = entry.OwnerID
+	info.Folder = entry.Folder
+	info.Checksum = entry.Checksum
+	info.MimeType = entry.MimeType
+	info.Width = entry.Width
+	info.Height = entry.Height
+	info.AltText = entry.AltText
+	info.Private = entry.Private
+	info.Tags = entry.Tags
+	if len(entry.Variants) > 0 {
+		info.Variants = entry.Variants
+		service.SetVariantURLs(info.Path, info.Variants)
+	}
 }
 
 // // getChildren gets information about child files and folders
@@ -114,26 +281,1181 @@ func getFileExtension(path string) string {
 	return ext[1:] // Skip leading "." only if extension exists
 }
 
-func GetMedia(path string) (httpResponse model.HTTPResponse, httpStatusCode int) {
-	info, err := GetFileInfo(path)
-	if err != nil {
-		fmt.Println("Error:", err)
+// isHiddenPath returns true when any part of the path is
+// hidden, i.e. the trash or the variants of an image, which
+// are never changed directly by the API consumers
+func isHiddenPath(p string) bool {
+	for _, part := range strings.Split(p, "/") {
+		if strings.HasPrefix(part, ".") {
+			return true
+		}
 	}
+	return false
+}
 
-	jsonData, err := json.Marshal(info)
+// mediaPath - clean slash-separated path of an entry as
+// exposed to the API consumers
+func mediaPath(path string) string {
+	p, err := storage.Clean(path)
+	if err != nil || p == "." {
+		return ""
+	}
+	return p
+}
+
+// GetMedia handles jobs for controller.GetMedia
+//
+// Private entries are left out unless the caller may change them
+func GetMedia(userID uint, filter model.MediaFilter) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	if filter.IsSearch() {
+		return SearchMedia(userID, filter)
+	}
+
+	listing, err := newMediaListing(filter)
 	if err != nil {
-		fmt.Println("Error:", err)
+		httpResponse.Message = err.Error()
+		httpStatusCode = http.StatusBadRequest
+		return
 	}
+	listing.userID = userID
 
-	//convert jsonData to struct type
+	p := mediaPath(filter.Path)
+	if isHiddenPath(p) {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
 
-	err = json.Unmarshal(jsonData, &info)
+	allowed, err := canSeeMedia(userID, p)
 	if err != nil {
-		fmt.Println("Error:", err)
+		log.WithError(err).Error("error code: 1400")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if !allowed {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	info, err := GetFileInfo(filter.Path, listing)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrInvalid) {
+			httpResponse.Message = "folder not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		log.WithError(err).Error("error code: 1400")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
 	}
 
 	httpResponse.Message = info
 	httpStatusCode = http.StatusOK
 	return
+}
+
+// canSeeMedia returns false when the entry is private or in
+// a private folder and the user may not change it
+func canSeeMedia(userID uint, p string) (bool, error) {
+	private, err := service.IsPrivateMedia(database.GetDB(), p)
+	if err != nil || !private {
+		return !private, err
+	}
+
+	return service.CanManageMedia(database.GetDB(), userID, p)
+}
+
+// visibleMedia - drop the search results which are private or
+// in a private folder and which the user may not change
+func visibleMedia(userID uint, entries []model.Media) ([]model.Media, error) {
+	paths := make([]string, len(entries))
+	for i := range entries {
+		paths[i] = entries[i].Path
+	}
+	private, err := service.PrivateMedia(database.GetDB(), paths)
+	if err != nil {
+		return nil, err
+	}
+
+	kept := []model.Media{}
+	for _, entry := range entries {
+		if private[entry.Path] {
+			allowed, err := service.CanManageMedia(database.GetDB(), userID, entry.Path)
+			if err != nil {
+				return nil, err
+			}
+			if !allowed {
+				continue
+			}
+		}
+		kept = append(kept, entry)
+	}
+
+	return kept, nil
+}
+
+// SearchMedia - find entries by name, alt text, tag or type
+// in the stored metadata, the storage is not touched
+//
+// Private entries are left out unless the caller may change them
+func SearchMedia(userID uint, filter model.MediaFilter) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	listing, err := newMediaListing(filter)
+	if err != nil {
+		httpResponse.Message = err.Error()
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	// entries in the trash are not searchable
+	db := database.GetDB().Model(&model.Media{}).Where("path NOT LIKE ?", service.TrashDir+"/%")
+
+	if folder := mediaPath(filter.Path); folder != "" {
+		db = db.Where("folder = ? OR folder LIKE ?", folder, folder+"/%")
+	}
+
+	if q := strings.TrimSpace(filter.Query); q != "" {
+		q = "%" + strings.ToLower(q) + "%"
+		db = db.Where("LOWER(name) LIKE ? OR LOWER(alt_text) LIKE ?", q, q)
+	}
+
+	if tags := lib.NormalizeTags(filter.Tags); len(tags) > 0 {
+		db = db.Where("id IN (?)", database.GetDB().Model(&model.MediaTag{}).Select("media_id").Where("name IN ?", tags))
+	}
+
+	switch t := listing.kind; {
+	case t == "":
+	case t == "folder":
+		db = db.Where("is_folder = ?", true)
+	case strings.HasSuffix(t, "/*"):
+		db = db.Where("mime_type LIKE ?", strings.TrimSuffix(t, "*")+"%")
+	case strings.Contains(t, "/"):
+		db = db.Where("mime_type = ?", t)
+	default:
+		db = db.Where("type = ?", t)
+	}
+
+	// keyset pagination, the ID breaks ties
+	column, op, order := listing.column(), ">", " ASC"
+	if listing.desc {
+		op, order = "<", " DESC"
+	}
+	db = db.Session(&gorm.Session{})
+
+	// hidden results are skipped before the page is cut, the
+	// next batch follows the last result read
+	entries := []model.Media{}
+	after := listing.after
+	for len(entries) <= listing.limit {
+		batchDB := db
+		if after != nil {
+			var value interface{} = after.Name
+			switch column {
+			case "size":
+				value = after.Size
+			case "updated_at":
+				value = time.Unix(0, after.ModTime)
+			}
+			batchDB = batchDB.Where(column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?)", value, value, after.ID)
+		}
+
+		batch := []model.Media{}
+		if err := batchDB.Preload("Tags").Order(column + order).Order("id" + order).Limit(listing.limit + 1).Find(&batch).Error; err != nil {
+			log.WithError(err).Error("error code: 1402")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+
+		visible, err := visibleMedia(userID, batch)
+		if err != nil {
+			log.WithError(err).Error("error code: 1402")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		entries = append(entries, visible...)
+
+		if len(batch) <= listing.limit {
+			break
+		}
+		last := batch[len(batch)-1]
+		after = &mediaCursor{Name: last.Name, Size: last.Size, ModTime: last.UpdatedAt.UnixNano(), ID: last.ID}
+	}
+
+	page := model.MediaPage{Items: entries}
+	if len(entries) > listing.limit {
+		page.Items = entries[:listing.limit]
+		next, err := listing.searchCursor(page.Items[len(page.Items)-1])
+		if err != nil {
+			log.WithError(err).Error("error code: 1403")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		page.NextCursor = next
+	}
+	for i := range page.Items {
+		service.SetVariantURLs(page.Items[i].Path, page.Items[i].Variants)
+	}
+
+	httpResponse.Message = page
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// UpdateMediaMeta handles jobs for controller.UpdateMediaMeta
+func UpdateMediaMeta(userID uint, meta model.MediaMeta) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := database.GetDB()
+	entry := model.Media{}
+
+	httpResponse, httpStatusCode = checkMediaManager(userID, mediaPath(meta.Path))
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if err := db.Where("path = ?", mediaPath(meta.Path)).First(&entry).Error; err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			httpResponse.Message = "media not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		log.WithError(err).Error("error code: 1431")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if meta.AltText != nil {
+			entry.AltText = strings.TrimSpace(*meta.AltText)
+			if err := tx.Model(&entry).Update("alt_text", entry.AltText).Error; err != nil {
+				return err
+			}
+		}
+		if meta.Private != nil {
+			entry.Private = *meta.Private
+			if err := tx.Model(&entry).Update("private", entry.Private).Error; err != nil {
+				return err
+			}
+		}
+
+		// tags are replaced as a whole
+		if meta.Tags != nil {
+			if err := tx.Where("media_id = ?", entry.ID).Delete(&model.MediaTag{}).Error; err != nil {
+				return err
+			}
+			entry.Tags = []model.MediaTag{}
+			for _, name := range lib.NormalizeTags(meta.Tags) {
+				entry.Tags = append(entry.Tags, model.MediaTag{MediaID: entry.ID, Name: name})
+			}
+			if len(entry.Tags) > 0 {
+				if err := tx.Create(&entry.Tags).Error; err != nil {
+					return err
+				}
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		log.WithError(err).Error("error code: 1432")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if meta.Tags == nil {
+		if err := db.Where("media_id = ?", entry.ID).Find(&entry.Tags).Error; err != nil {
+			log.WithError(err).Error("error code: 1433")
+		}
+	}
+	service.SetVariantURLs(entry.Path, entry.Variants)
+
+	httpResponse.Message = entry
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// CreateFolder handles jobs for controller.CreateFolder
+func CreateFolder(ownerID uint, folderPath string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	folderPath = mediaPath(folderPath)
+	if folderPath == "" || isHiddenPath(folderPath) {
+		httpResponse.Message = "invalid path"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaManager(ownerID, mediaPath(path.Dir(folderPath)))
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if err := database.GetStorage().Mkdir(folderPath); err != nil {
+		if errors.Is(err, fs.ErrExist) {
+			httpResponse.Message = "Folder already exists"
+			httpStatusCode = http.StatusConflict
+			return
+		}
+		if errors.Is(err, fs.ErrNotExist) {
+			httpResponse.Message = "parent folder not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		log.WithError(err).Error("error code: 1411")
+		httpResponse.Message = "Error creating folder"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	folder := model.Media{
+		OwnerID:  ownerID,
+		Name:     path.Base(folderPath),
+		Folder:   mediaPath(path.Dir(folderPath)),
+		Path:     folderPath,
+		IsFolder: true,
+	}
+	if err := database.GetDB().Create(&folder).Error; err != nil {
+		log.WithError(err).Error("error code: 1412")
+	}
+
+	httpResponse.Message = "Folder created"
+	httpStatusCode = http.StatusCreated
+	return
+}
+
+// RenameFolder handles jobs for controller.RenameFolder
+func RenameFolder(userID uint, oldPath, newPath string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	oldPath = mediaPath(oldPath)
+	newPath = mediaPath(newPath)
+	if oldPath == "" || newPath == "" || isHiddenPath(oldPath) || isHiddenPath(newPath) {
+		httpResponse.Message = "invalid path"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	for _, p := range []string{oldPath, newPath} {
+		httpResponse, httpStatusCode = checkMediaNamespace(p)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	// the entry leaves its folder and enters another one
+	for _, p := range []string{oldPath, mediaPath(path.Dir(newPath))} {
+		httpResponse, httpStatusCode = checkMediaManager(userID, p)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	s := database.GetStorage()
+
+	if _, err := s.Stat(oldPath); err != nil {
+		httpResponse.Message = "Folder dont exist"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
 
+	if _, err := s.Stat(newPath); err == nil {
+		httpResponse.Message = "Folder with this name exist please try another name"
+		httpStatusCode = http.StatusConflict
+		return
+	}
+
+	if err := moveMedia(oldPath, newPath); err != nil {
+		log.WithError(err).Error("error code: 1421")
+		httpResponse.Message = "Something fails on server side"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = "Folder renamed successfully"
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// moveMedia - move a file or folder along with the variants
+// of a file and the stored metadata
+func moveMedia(src, dst string) error {
+	s := database.GetStorage()
+
+	info, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := s.Rename(src, dst); err != nil {
+		return err
+	}
+	// the variants of a folder are inside it
+	if !info.IsDir {
+		transferVariants(src, dst, s.Rename)
+	}
+
+	return renameMediaEntries(src, dst)
+}
+
+// copyMedia - copy a file or folder along with the variants
+// of a file and the stored metadata, the copy belongs to ownerID
+func copyMedia(ownerID uint, src, dst string) error {
+	s := database.GetStorage()
+
+	info, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+	if err := storage.Copy(s, src, dst); err != nil {
+		return err
+	}
+	if !info.IsDir {
+		transferVariants(src, dst, func(from, to string) error {
+			return storage.Copy(s, from, to)
+		})
+	}
+
+	return copyMediaEntries(ownerID, src, dst)
+}
+
+// transferVariants - move or copy the variants of a file, a
+// failure is only logged as variants can be generated again
+func transferVariants(src, dst string, transfer func(from, to string) error) {
+	s := database.GetStorage()
+	from, to := service.VariantDir(src), service.VariantDir(dst)
+
+	if _, err := s.Stat(from); err != nil {
+		return
+	}
+	err := storage.MkdirAll(s, path.Dir(to))
+	if err == nil {
+		err = transfer(from, to)
+	}
+	if err != nil {
+		log.WithError(err).Error("error code: 1423")
+	}
+}
+
+// renameMediaEntries - move the stored metadata of a file
+// or folder and everything in it to the new path
+func renameMediaEntries(oldPath, newPath string) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		rows := []model.Media{}
+		if err := tx.Where("path = ? OR path LIKE ?", oldPath, oldPath+"/%").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			// LIKE treats _ and % as wildcards
+			if row.Path != oldPath && !strings.HasPrefix(row.Path, oldPath+"/") {
+				continue
+			}
+			p := newPath + strings.TrimPrefix(row.Path, oldPath)
+			if err := tx.Model(&model.Media{}).Where("id = ?", row.ID).Updates(map[string]interface{}{
+				"name":   path.Base(p),
+				"folder": mediaPath(path.Dir(p)),
+				"path":   p,
+			}).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// copyMediaEntries - copy the stored metadata of a file or
+// folder and everything in it to the new path
+func copyMediaEntries(ownerID uint, src, dst string) error {
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		rows := []model.Media{}
+		if err := tx.Preload("Tags").Where("path = ? OR path LIKE ?", src, src+"/%").Find(&rows).Error; err != nil {
+			return err
+		}
+
+		for _, row := range rows {
+			// LIKE treats _ and % as wildcards
+			if row.Path != src && !strings.HasPrefix(row.Path, src+"/") {
+				continue
+			}
+			p := dst + strings.TrimPrefix(row.Path, src)
+
+			tags := []model.MediaTag{}
+			for _, tag := range row.Tags {
+				tags = append(tags, model.MediaTag{Name: tag.Name})
+			}
+
+			row.ID = 0
+			row.CreatedAt = time.Time{}
+			row.UpdatedAt = time.Time{}
+			row.OwnerID = ownerID
+			row.Name = path.Base(p)
+			row.Folder = mediaPath(path.Dir(p))
+			row.Path = p
+			row.Tags = tags
+			if err := tx.Create(&row).Error; err != nil {
+				return err
+			}
+		}
+
+		return nil
+	})
+}
+
+// MoveMedia handles jobs for controller.MoveMedia
+func MoveMedia(userID uint, transfer model.MediaTransfer) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	src, dst, httpResponse, httpStatusCode := transferPaths(transfer)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaNamespace(src)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	for _, p := range []string{src, mediaPath(transfer.Folder)} {
+		httpResponse, httpStatusCode = checkMediaManager(userID, p)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	if err := moveMedia(src, dst); err != nil {
+		log.WithError(err).Error("error code: 1471")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	return mediaInfo(userID, dst, http.StatusOK)
+}
+
+// CopyMedia handles jobs for controller.CopyMedia
+func CopyMedia(ownerID uint, transfer model.MediaTransfer) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	src, dst, httpResponse, httpStatusCode := transferPaths(transfer)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaManager(ownerID, mediaPath(transfer.Folder))
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	// public entries may be copied by everyone
+	private, err := service.IsPrivateMedia(database.GetDB(), src)
+	if err != nil {
+		log.WithError(err).Error("error code: 1495")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if private {
+		httpResponse, httpStatusCode = checkMediaManager(ownerID, src)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	// the copy belongs to the caller and counts towards the quota
+	size, err := mediaSize(src)
+	if err != nil {
+		log.WithError(err).Error("error code: 1472.1")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	httpResponse, httpStatusCode = checkMediaQuota(ownerID, size)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if err := copyMedia(ownerID, src, dst); err != nil {
+		log.WithError(err).Error("error code: 1472.2")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	return mediaInfo(ownerID, dst, http.StatusCreated)
+}
+
+// transferPaths - check the source and target of a move or
+// copy, the entry keeps its name in the target folder
+func transferPaths(transfer model.MediaTransfer) (src, dst string, httpResponse model.HTTPResponse, httpStatusCode int) {
+	s := database.GetStorage()
+
+	src = mediaPath(transfer.Path)
+	folder := mediaPath(transfer.Folder)
+	if src == "" || isHiddenPath(src) || isHiddenPath(folder) {
+		httpResponse.Message = "invalid path"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	dst = path.Join(folder, path.Base(src))
+
+	// a folder cannot go into itself
+	if dst == src || strings.HasPrefix(folder+"/", src+"/") {
+		httpResponse.Message = "invalid target folder"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	if _, err := s.Stat(src); err != nil {
+		httpResponse.Message = "media not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+	if info, err := s.Stat(folder); err != nil || !info.IsDir {
+		httpResponse.Message = "target folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+	if _, err := s.Stat(dst); err == nil {
+		httpResponse.Message = "target already exists"
+		httpStatusCode = http.StatusConflict
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// mediaInfo - entry of a file or folder as the response
+// of a successful change
+func mediaInfo(userID uint, p string, successCode int) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	listing, err := newMediaListing(model.MediaFilter{})
+	if err == nil {
+		listing.userID = userID
+		httpResponse.Message, err = GetFileInfo(p, listing)
+	}
+	if err != nil {
+		log.WithError(err).Error("error code: 1404")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpStatusCode = successCode
+	return
+}
+
+// DeleteMedia handles jobs for controller.DeleteMedia
+//
+// The entry goes to the trash, from where it can be restored
+// until it is purged. Entries shown by posts are only deleted
+// with force, the posts are listed in UsedBy.
+func DeleteMedia(userID uint, p string, force bool) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	s := database.GetStorage()
+
+	p = mediaPath(p)
+	if p == "" || isHiddenPath(p) {
+		httpResponse.Message = "invalid path"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaNamespace(p)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	httpResponse, httpStatusCode = checkMediaManager(userID, p)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	info, err := s.Stat(p)
+	if err != nil {
+		httpResponse.Message = "media not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	usedBy, err := postMediaUsers(database.GetDB(), p)
+	if err != nil {
+		log.WithError(err).Error("error code: 1754")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if len(usedBy) > 0 && !force {
+		ids := make([]string, 0, len(usedBy))
+		for _, id := range usedBy {
+			ids = append(ids, strconv.FormatUint(uint64(id), 10))
+		}
+		httpResponse.Message = "media is shown by post ID# " + strings.Join(ids, ", ") + ", add force=true to delete it anyway"
+		httpStatusCode = http.StatusConflict
+		return
+	}
+
+	// every entry has its own folder in the trash, so the same
+	// name can be deleted many times
+	trashed := model.MediaTrash{
+		DeletedBy:    userID,
+		OriginalPath: p,
+		TrashPath:    path.Join(service.TrashDir, uuid.NewString(), path.Base(p)),
+		IsFolder:     info.IsDir,
+		Size:         info.Size,
+	}
+
+	err = storage.MkdirAll(s, path.Dir(trashed.TrashPath))
+	if err == nil {
+		err = moveMedia(p, trashed.TrashPath)
+	}
+	if err != nil {
+		log.WithError(err).Error("error code: 1481")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if err := database.GetDB().Create(&trashed).Error; err != nil {
+		log.WithError(err).Error("error code: 1482")
+		// without the row it could never be restored
+		if err := moveMedia(trashed.TrashPath, p); err != nil {
+			log.WithError(err).Error("error code: 1483")
+		}
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	trashed.PurgeAt = trashed.CreatedAt.Add(config.GetConfig().Media.TrashRetention)
+	trashed.UsedBy = usedBy
+
+	httpResponse.Message = trashed
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetMediaTrash handles jobs for controller.GetMediaTrash
+//
+// Admins see everything, other users what they deleted
+func GetMediaTrash(userID uint) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	trashed := []model.MediaTrash{}
+
+	admin, err := service.IsMediaAdmin(userID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1497")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	tx := database.GetDB().Order("created_at DESC")
+	if !admin {
+		tx = tx.Where("deleted_by = ?", userID)
+	}
+	if err := tx.Find(&trashed).Error; err != nil {
+		log.WithError(err).Error("error code: 1484")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	for i := range trashed {
+		trashed[i].PurgeAt = trashed[i].CreatedAt.Add(config.GetConfig().Media.TrashRetention)
+	}
+
+	httpResponse.Message = trashed
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// RestoreMedia handles jobs for controller.RestoreMedia
+func RestoreMedia(userID uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := database.GetDB()
+	s := database.GetStorage()
+	trashed := model.MediaTrash{}
+
+	if err := db.Where("id = ?", id).First(&trashed).Error; err != nil {
+		httpResponse.Message = "media not found in trash"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	// whoever deleted an entry may bring it back
+	if trashed.DeletedBy != userID {
+		httpResponse, httpStatusCode = checkMediaManager(userID, trashed.TrashPath)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	if _, err := s.Stat(trashed.OriginalPath); err == nil {
+		httpResponse.Message = "original path is taken, rename or move that entry first"
+		httpStatusCode = http.StatusConflict
+		return
+	}
+
+	// the original folder may have been deleted meanwhile
+	err := storage.MkdirAll(s, path.Dir(trashed.OriginalPath))
+	if err == nil {
+		err = moveMedia(trashed.TrashPath, trashed.OriginalPath)
+	}
+	if err != nil {
+		log.WithError(err).Error("error code: 1485")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if err := s.RemoveAll(path.Dir(trashed.TrashPath)); err != nil {
+		log.WithError(err).Error("error code: 1486")
+	}
+	if err := db.Delete(&trashed).Error; err != nil {
+		log.WithError(err).Error("error code: 1487")
+	}
+
+	return mediaInfo(userID, trashed.OriginalPath, http.StatusOK)
+}
+
+// UploadMedia handles jobs for controller.UploadMedia
+func UploadMedia(ownerID uint, folder string, private bool, fileHeader *multipart.FileHeader) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	configMedia := config.GetConfig().Media
+	s := database.GetStorage()
+
+	folder = mediaPath(folder)
+
+	// the target folder must exist
+	dirInfo, err := s.Stat(folder)
+	if err != nil || !dirInfo.IsDir || isHiddenPath(folder) {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaManager(ownerID, folder)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if fileHeader.Size > configMedia.MaxUploadSize {
+		httpResponse.Message = "file too large"
+		httpStatusCode = http.StatusRequestEntityTooLarge
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaQuota(ownerID, fileHeader.Size)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		log.WithError(err).Error("error code: 1401.1")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	defer src.Close()
+
+	name := path.Base(path.Clean("/" + strings.ReplaceAll(fileHeader.Filename, "\\", "/")))
+	_, httpResponse, httpStatusCode = saveMedia(ownerID, folder, name, private, src)
+	return
+}
+
+// saveMedia - check the content of a new file and store it
+// along with its metadata in an existing folder the owner
+// may change
+func saveMedia(ownerID uint, folder, name string, private bool, src io.ReadSeeker) (entry model.Media, httpResponse model.HTTPResponse, httpStatusCode int) {
+	configMedia := config.GetConfig().Media
+	s := database.GetStorage()
+
+	name = strings.TrimSpace(name)
+	if name == "" || name == "/" || strings.HasPrefix(name, ".") {
+		httpResponse.Message = "invalid file name"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	// never trust the extension, check the content instead
+	mimeType, ext, err := lib.DetectMIME(src)
+	if err != nil {
+		log.WithError(err).Error("error code: 1401.2")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if !lib.MIMEAllowed(mimeType, configMedia.AllowedTypes) {
+		httpResponse.Message = "file type not allowed: " + mimeType
+		httpStatusCode = http.StatusUnsupportedMediaType
+		return
+	}
+	if _, err := src.Seek(0, io.SeekStart); err != nil {
+		log.WithError(err).Error("error code: 1401.3")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	// a small file may declare a huge picture, which must never
+	// reach a decoder, neither here nor in the variant workers
+	if strings.HasPrefix(mimeType, "image/") {
+		if _, err := lib.CheckImagePixels(src, configMedia.MaxPixels); errors.Is(err, lib.ErrTooManyPixels) {
+			httpResponse.Message = "image has more than " + strconv.FormatInt(configMedia.MaxPixels, 10) + " pixels"
+			httpStatusCode = http.StatusRequestEntityTooLarge
+			return
+		}
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			log.WithError(err).Error("error code: 1401.3")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+	}
+
+	// orientation and capture date are read first, then the
+	// metadata is removed as it may reveal where a picture was
+	// taken, i.e. GPS coordinates
+	meta := lib.ImageMeta{}
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/webp":
+		data, err := io.ReadAll(io.LimitReader(src, configMedia.MaxUploadSize+1))
+		if err != nil {
+			log.WithError(err).Error("error code: 1401.3")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if int64(len(data)) > configMedia.MaxUploadSize {
+			httpResponse.Message = "file too large"
+			httpStatusCode = http.StatusRequestEntityTooLarge
+			return
+		}
+
+		meta = lib.ReadImageMeta(data, mimeType)
+		if configMedia.StripMetadata {
+			data, err = cleanImage(data, mimeType, meta.Orientation, configMedia.MaxPixels)
+			if errors.Is(err, lib.ErrTooManyPixels) {
+				httpResponse.Message = "image has more than " + strconv.FormatInt(configMedia.MaxPixels, 10) + " pixels"
+				httpStatusCode = http.StatusRequestEntityTooLarge
+				return
+			}
+			if err != nil {
+				httpResponse.Message = "invalid image"
+				httpStatusCode = http.StatusUnsupportedMediaType
+				return
+			}
+		}
+		src = bytes.NewReader(data)
+	}
+
+	// dimensions of images, without decoding the whole picture
+	width, height := 0, 0
+	if strings.HasPrefix(mimeType, "image/") {
+		if cfg, _, err := image.DecodeConfig(src); err == nil {
+			width, height = cfg.Width, cfg.Height
+		}
+		// as displayed, when the orientation is left to the viewer
+		if !configMedia.StripMetadata && meta.Orientation >= 5 {
+			width, height = height, width
+		}
+		if _, err := src.Seek(0, io.SeekStart); err != nil {
+			log.WithError(err).Error("error code: 1401.3")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+	}
+
+	// the stored file always carries the extension of its real type
+	if ext != "" && !strings.EqualFold(path.Ext(name), ext) {
+		name = strings.TrimSuffix(name, path.Ext(name)) + ext
+	}
+
+	filePath := mediaPath(folder + "/" + name)
+	if _, err := s.Stat(filePath); err == nil {
+		httpResponse.Message = "file already exists"
+		httpStatusCode = http.StatusConflict
+		return
+	}
+
+	// the header may lie about the size, so limit the copy as well
+	limited := &io.LimitedReader{R: src, N: configMedia.MaxUploadSize + 1}
+	hash := sha256.New()
+	size, err := s.Put(filePath, io.TeeReader(limited, hash), -1, mimeType)
+	if err != nil {
+		log.WithError(err).Error("error code: 1401.4")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if size > configMedia.MaxUploadSize {
+		if err := s.RemoveAll(filePath); err != nil {
+			log.WithError(err).Error("error code: 1401.5")
+		}
+		httpResponse.Message = "file too large"
+		httpStatusCode = http.StatusRequestEntityTooLarge
+		return
+	}
+	// the declared size may have been a lie
+	httpResponse, httpStatusCode = checkMediaQuota(ownerID, size)
+	if httpStatusCode != http.StatusOK {
+		if err := s.RemoveAll(filePath); err != nil {
+			log.WithError(err).Error("error code: 1401.5")
+		}
+		return
+	}
+
+	entry = model.Media{
+		OwnerID:  ownerID,
+		Name:     name,
+		Folder:   folder,
+		Path:     filePath,
+		Type:     getFileExtension(name),
+		Size:     size,
+		Checksum: hex.EncodeToString(hash.Sum(nil)),
+		MimeType: mimeType,
+		Width:    width,
+		Height:   height,
+		Private:  private,
+
+		Orientation: meta.Orientation,
+		CapturedAt:  meta.CapturedAt,
+	}
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		log.WithError(err).Error("error code: 1401.6")
+		if err := s.RemoveAll(filePath); err != nil {
+			log.WithError(err).Error("error code: 1401.5")
+		}
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	// resized copies are generated in the background
+	service.QueueVariants(filePath, mimeType)
+
+	httpResponse.Message = entry
+	httpStatusCode = http.StatusCreated
+	return
+}
+
+// cleanImage - image without EXIF and XMP, turned upright when
+// the orientation asks for it
+//
+// Turning decodes the picture, which is refused with
+// lib.ErrTooManyPixels beyond maxPixels
+func cleanImage(data []byte, mimeType string, orientation int, maxPixels int64) ([]byte, error) {
+	if orientation < 2 {
+		return lib.StripImageMeta(data, mimeType)
+	}
+
+	if _, err := lib.CheckImagePixels(bytes.NewReader(data), maxPixels); err != nil {
+		return nil, err
+	}
+	// encoding the turned picture leaves all metadata behind
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	buf := bytes.Buffer{}
+	if err := lib.EncodeImage(&buf, lib.OrientImage(img, orientation), strings.TrimPrefix(mimeType, "image/")); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// GetMediaFile handles jobs for controller.DownloadMedia,
+// the caller must close the returned file
+//
+// Private files need a valid signature, see SignMediaURL
+func GetMediaFile(p string, expires int64, signature string) (file storage.File, info storage.FileInfo, mimeType, etag string, httpResponse model.HTTPResponse, httpStatusCode int) {
+	s := database.GetStorage()
+
+	p = mediaPath(p)
+	if p == "" || isHiddenPath(p) {
+		httpResponse.Message = "file not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaAccess(p, expires, signature)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	info, err := s.Stat(p)
+	if err == nil && info.IsDir {
+		err = fs.ErrNotExist
+	}
+	if err == nil {
+		file, err = s.Open(p)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrInvalid) {
+			httpResponse.Message = "file not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		log.WithError(err).Error("error code: 1491")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	// the stored checksum gives a strong validator
+	entries, err := mediaEntries([]string{p})
+	if err != nil {
+		log.WithError(err).Error("error code: 1492")
+	}
+	entry := entries[p]
+	if entry.Size != info.Size {
+		// changed behind our back
+		entry.Checksum = ""
+	}
+	etag = lib.ETag(entry.Checksum, info.Size, info.ModTime)
+
+	mimeType = entry.MimeType
+	if mimeType == "" {
+		mimeType, _, err = lib.DetectMIME(file)
+		if err == nil {
+			_, err = file.Seek(0, io.SeekStart)
+		}
+		if err != nil {
+			file.Close()
+			log.WithError(err).Error("error code: 1493")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetMediaVariant handles jobs for controller.GetMediaVariant,
+// the caller must close the returned file
+func GetMediaVariant(variant, originalPath string, expires int64, signature string) (file storage.File, info storage.FileInfo, mimeType string, httpResponse model.HTTPResponse, httpStatusCode int) {
+	originalPath = mediaPath(originalPath)
+
+	// the signature of the original is valid for its variants
+	httpResponse, httpStatusCode = checkMediaAccess(originalPath, expires, signature)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	variantPath, mimeType, err := service.VariantFile(originalPath, variant)
+	if err == nil {
+		info, err = database.GetStorage().Stat(variantPath)
+	}
+	if err == nil {
+		file, err = database.GetStorage().Open(variantPath)
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrInvalid) {
+			httpResponse.Message = "variant not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		log.WithError(err).Error("error code: 1441")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
 }
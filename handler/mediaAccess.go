@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"errors"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+	"github.com/tinkerbaj/gintemp/lib/storage"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// checkMediaAccess - private entries are only served with a
+// valid signature which has not expired yet, everything
+// else is public
+func checkMediaAccess(p string, expires int64, signature string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	private, err := service.IsPrivateMedia(database.GetDB(), p)
+	if err != nil {
+		log.WithError(err).Error("error code: 1495")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if private && !lib.VerifyPathSignature(config.GetConfig().Security.MediaURLKeys, p, expires, signature, time.Now()) {
+		httpResponse.Message = "invalid or expired signature"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// checkMediaManager - only the owners of an entry or of a
+// folder containing it and the admins may change it
+func checkMediaManager(userID uint, p string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	allowed, err := service.CanManageMedia(database.GetDB(), userID, p)
+	if err != nil {
+		log.WithError(err).Error("error code: 1497")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if !allowed {
+		httpResponse.Message = "access denied"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	// the namespace of a shop exists before it is used
+	if _, err := service.EnsureMediaNamespace(userID); err != nil {
+		log.WithError(err).Error("error code: 1497")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// checkMediaNamespace - the folders keeping the namespaces of
+// the shops can neither be removed nor renamed
+func checkMediaNamespace(p string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	if service.IsMediaNamespace(p) {
+		httpResponse.Message = "media namespaces cannot be changed"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// SignMediaURL handles jobs for controller.SignMediaURL
+//
+// Only those who may change a private file can share it
+func SignMediaURL(userID uint, req model.MediaSignRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	configure := config.GetConfig()
+
+	keys := configure.Security.MediaURLKeys
+	if len(keys) == 0 {
+		httpResponse.Message = "signed URLs are not enabled"
+		httpStatusCode = http.StatusServiceUnavailable
+		return
+	}
+
+	ttl := time.Duration(req.TTL) * time.Second
+	if ttl == 0 {
+		ttl = configure.Media.SignedURLTTL
+	}
+	if ttl < 0 || ttl > configure.Media.SignedURLMaxTTL {
+		httpResponse.Message = "ttl must be between 1 and " + strconv.FormatInt(int64(configure.Media.SignedURLMaxTTL/time.Second), 10) + " seconds"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	p := mediaPath(req.Path)
+	if p == "" || isHiddenPath(p) {
+		httpResponse.Message = "file not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	info, err := database.GetStorage().Stat(p)
+	if err == nil && info.IsDir {
+		err = fs.ErrNotExist
+	}
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) || errors.Is(err, storage.ErrInvalid) {
+			httpResponse.Message = "file not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		log.WithError(err).Error("error code: 1496")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	private, err := service.IsPrivateMedia(database.GetDB(), p)
+	if err != nil {
+		log.WithError(err).Error("error code: 1495")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if private {
+		httpResponse, httpStatusCode = checkMediaManager(userID, p)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	// new URLs are always signed with the first key, the others
+	// are only kept to verify URLs issued before a rotation
+	expiresAt := time.Now().Add(ttl).Truncate(time.Second)
+	signed := model.MediaSignedURL{
+		Expires:   expiresAt.Unix(),
+		Signature: lib.SignPath(keys[0], p, expiresAt.Unix()),
+		ExpiresAt: expiresAt,
+	}
+	signed.URL = service.DownloadURL(p) + "?" + url.Values{
+		"expires":   {strconv.FormatInt(signed.Expires, 10)},
+		"signature": {signed.Signature},
+	}.Encode()
+
+	httpResponse.Message = signed
+	httpStatusCode = http.StatusOK
+	return
+}
@@ -0,0 +1,209 @@
+package handler
+
+import (
+	"cmp"
+	"errors"
+	"mime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// mediaListing - validated sort, filter and paging
+// options of the media list
+type mediaListing struct {
+	sortBy string // name, size or mtime
+	desc   bool
+	kind   string // type filter, lowercase
+	limit  int
+	depth  int
+	after  *mediaCursor
+	userID uint // private entries are listed only to their managers
+}
+
+// mediaCursor - position of the last entry of a page
+type mediaCursor struct {
+	Search  bool   `json:"q,omitempty"`
+	SortBy  string `json:"s"`
+	Desc    bool   `json:"d,omitempty"`
+	Folder  bool   `json:"f,omitempty"`
+	Name    string `json:"n,omitempty"`
+	Size    int64  `json:"z,omitempty"`
+	ModTime int64  `json:"m,omitempty"` // unix nano
+	ID      uint   `json:"i,omitempty"`
+}
+
+// newMediaListing - check the list options of a request and
+// fill in the defaults
+func newMediaListing(filter model.MediaFilter) (mediaListing, error) {
+	configMedia := config.GetConfig().Media
+
+	listing := mediaListing{
+		sortBy: strings.ToLower(strings.TrimSpace(filter.Sort)),
+		kind:   strings.TrimPrefix(strings.ToLower(strings.TrimSpace(filter.Type)), "."),
+		limit:  filter.Limit,
+		depth:  filter.Depth,
+	}
+
+	switch listing.sortBy {
+	case "":
+		listing.sortBy = "name"
+	case "name", "size", "mtime":
+	default:
+		return listing, errors.New("sort must be one of name, size or mtime")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(filter.Order)) {
+	case "", "asc":
+	case "desc":
+		listing.desc = true
+	default:
+		return listing, errors.New("order must be asc or desc")
+	}
+
+	if listing.limit < 0 || listing.depth < 0 {
+		return listing, errors.New("limit and depth must not be negative")
+	}
+	if listing.limit == 0 {
+		listing.limit = configMedia.ListLimit
+	}
+	if listing.limit > configMedia.ListMaxLimit {
+		listing.limit = configMedia.ListMaxLimit
+	}
+	if listing.depth == 0 {
+		listing.depth = 1
+	}
+	if listing.depth > configMedia.ListMaxDepth {
+		listing.depth = configMedia.ListMaxDepth
+	}
+
+	if filter.Cursor != "" {
+		after := mediaCursor{}
+		if err := lib.DecodeCursor(filter.Cursor, &after); err != nil {
+			return listing, err
+		}
+		// a cursor is only valid for the order it was issued for
+		if after.Search != filter.IsSearch() || after.SortBy != listing.sortBy || after.Desc != listing.desc {
+			return listing, lib.ErrInvalidCursor
+		}
+		listing.after = &after
+	}
+
+	return listing, nil
+}
+
+// column - column of the media table to sort the search by
+func (l mediaListing) column() string {
+	switch l.sortBy {
+	case "size":
+		return "size"
+	case "mtime":
+		return "updated_at"
+	}
+	return "name"
+}
+
+// compare - order of two entries of a folder, folders always
+// come first, entries with the same key are ordered by name
+func (l mediaListing) compare(a, b model.Media) int {
+	if a.IsFolder != b.IsFolder {
+		if a.IsFolder {
+			return -1
+		}
+		return 1
+	}
+
+	c := 0
+	switch l.sortBy {
+	case "size":
+		c = cmp.Compare(a.Size, b.Size)
+	case "mtime":
+		c = a.ModTime.Compare(b.ModTime)
+	}
+	if c == 0 {
+		c = strings.Compare(a.Name, b.Name)
+	}
+	if l.desc {
+		c = -c
+	}
+
+	return c
+}
+
+// sort - sort the entries of a folder
+func (l mediaListing) sort(entries []model.Media) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return l.compare(entries[i], entries[j]) < 0
+	})
+}
+
+// isAfter returns true when the entry follows the cursor
+func (l mediaListing) isAfter(entry model.Media, after *mediaCursor) bool {
+	if after == nil {
+		return true
+	}
+
+	return l.compare(entry, model.Media{
+		IsFolder: after.Folder,
+		Name:     after.Name,
+		Size:     after.Size,
+		ModTime:  time.Unix(0, after.ModTime),
+	}) > 0
+}
+
+// cursor - cursor pointing at an entry of a folder
+func (l mediaListing) cursor(entry model.Media) (string, error) {
+	return lib.EncodeCursor(mediaCursor{
+		SortBy:  l.sortBy,
+		Desc:    l.desc,
+		Folder:  entry.IsFolder,
+		Name:    entry.Name,
+		Size:    entry.Size,
+		ModTime: entry.ModTime.UnixNano(),
+	})
+}
+
+// searchCursor - cursor pointing at a search result
+func (l mediaListing) searchCursor(entry model.Media) (string, error) {
+	return lib.EncodeCursor(mediaCursor{
+		Search:  true,
+		SortBy:  l.sortBy,
+		Desc:    l.desc,
+		Name:    entry.Name,
+		Size:    entry.Size,
+		ModTime: entry.UpdatedAt.UnixNano(),
+		ID:      entry.ID,
+	})
+}
+
+// matches returns true when an entry of a folder passes the
+// type filter, folders are kept to keep the tree browsable
+// unless only folders are asked for
+func (l mediaListing) matches(entry model.Media) bool {
+	switch {
+	case l.kind == "":
+		return true
+	case l.kind == "folder":
+		return entry.IsFolder
+	case entry.IsFolder:
+		return true
+	}
+
+	mimeType := entry.MimeType
+	if mimeType == "" && entry.Type != "" {
+		mimeType, _, _ = mime.ParseMediaType(mime.TypeByExtension("." + entry.Type))
+	}
+
+	switch {
+	case strings.HasSuffix(l.kind, "/*"):
+		return strings.HasPrefix(mimeType, strings.TrimSuffix(l.kind, "*"))
+	case strings.Contains(l.kind, "/"):
+		return mimeType == l.kind
+	}
+
+	return strings.EqualFold(entry.Type, l.kind)
+}
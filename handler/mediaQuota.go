@@ -0,0 +1,132 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// checkMediaQuota - refuse to store size more bytes for a
+// user who would go over the quota
+func checkMediaQuota(userID uint, size int64) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	exceeded, err := service.MediaQuotaExceeded(userID, size)
+	if err != nil {
+		log.WithError(err).Error("error code: 1498")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if exceeded {
+		httpResponse.Message = "storage quota exceeded"
+		httpStatusCode = http.StatusInsufficientStorage
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// mediaSize - bytes stored in a file or folder
+func mediaSize(p string) (int64, error) {
+	info, err := database.GetStorage().Stat(p)
+	if err != nil || !info.IsDir {
+		return info.Size, err
+	}
+
+	rows := []model.Media{}
+	err = database.GetDB().Select("path", "size").Where("path LIKE ? AND is_folder = ?", p+"/%", false).Find(&rows).Error
+
+	var size int64
+	for _, row := range rows {
+		// LIKE treats _ and % as wildcards
+		if strings.HasPrefix(row.Path, p+"/") {
+			size += row.Size
+		}
+	}
+
+	return size, err
+}
+
+// GetMediaUsage handles jobs for controller.GetMediaUsage
+func GetMediaUsage(userID uint) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	usage, err := service.GetMediaUsage(userID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1498")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	usage.Namespace, err = service.EnsureMediaNamespace(userID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1497")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = usage
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// SetMediaQuota handles jobs for controller.SetMediaQuota
+//
+// Only admins may change quotas
+func SetMediaQuota(adminID uint, userID string, quota model.MediaQuota) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	admin, err := service.IsMediaAdmin(adminID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1497")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if !admin {
+		httpResponse.Message = "access denied"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	id, err := strconv.ParseUint(userID, 10, 64)
+	if err != nil || id == 0 {
+		httpResponse.Message = "invalid user id"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	if quota.Quota < 0 {
+		httpResponse.Message = "quota must not be negative"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	db := database.GetDB()
+
+	var count int64
+	if err := db.Model(&model.User{}).Where("id = ?", id).Count(&count).Error; err != nil {
+		log.WithError(err).Error("error code: 1499")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if count == 0 {
+		httpResponse.Message = "user not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	quota.UserID = uint(id)
+	if err := db.Save(&quota).Error; err != nil {
+		log.WithError(err).Error("error code: 1499")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	return GetMediaUsage(quota.UserID)
+}
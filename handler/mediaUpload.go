@@ -0,0 +1,284 @@
+package handler
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/google/uuid"
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// CreateMediaUpload handles jobs for controller.CreateMediaUpload
+//
+// Known metadata: `filename`, `folder` and `private`
+func CreateMediaUpload(ownerID uint, length int64, metadata map[string]string) (upload model.MediaUpload, httpResponse model.HTTPResponse, httpStatusCode int) {
+	configMedia := config.GetConfig().Media
+
+	if length <= 0 {
+		httpResponse.Message = "invalid Upload-Length"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	if length > configMedia.MaxUploadSize {
+		httpResponse.Message = "file too large"
+		httpStatusCode = http.StatusRequestEntityTooLarge
+		return
+	}
+
+	name := path.Base(path.Clean("/" + strings.ReplaceAll(metadata["filename"], "\\", "/")))
+	if name == "/" || strings.HasPrefix(name, ".") {
+		httpResponse.Message = "invalid file name"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	folder := mediaPath(metadata["folder"])
+	dirInfo, err := database.GetStorage().Stat(folder)
+	if err != nil || !dirInfo.IsDir || isHiddenPath(folder) {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaManager(ownerID, folder)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	httpResponse, httpStatusCode = checkMediaQuota(ownerID, length)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	private, _ := strconv.ParseBool(metadata["private"])
+	upload = model.MediaUpload{
+		ID:        uuid.NewString(),
+		ExpiresAt: time.Now().Add(configMedia.UploadExpiry),
+		OwnerID:   ownerID,
+		Folder:    folder,
+		Name:      name,
+		Private:   private,
+		Length:    length,
+	}
+
+	err = os.MkdirAll(configMedia.UploadDir, 0o750)
+	if err == nil {
+		var f *os.File
+		f, err = os.OpenFile(service.UploadFile(upload.ID), os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0o600)
+		if err == nil {
+			err = f.Close()
+		}
+	}
+	if err != nil {
+		log.WithError(err).Error("error code: 1442")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if err := database.GetDB().Create(&upload).Error; err != nil {
+		log.WithError(err).Error("error code: 1443")
+		if err := os.Remove(service.UploadFile(upload.ID)); err != nil {
+			log.WithError(err).Error("error code: 1442")
+		}
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpStatusCode = http.StatusCreated
+	return
+}
+
+// lockOwnUpload - load the upload of the owner and take its
+// lock, one request at a time may change an upload
+//
+// The lock is only taken for an upload the owner has, the
+// caller must call service.UnlockUpload when the status is 200
+func lockOwnUpload(ownerID uint, id string) (upload model.MediaUpload, httpResponse model.HTTPResponse, httpStatusCode int) {
+	upload, httpResponse, httpStatusCode = loadUpload(ownerID, id)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if !service.LockUpload(upload.ID) {
+		httpResponse.Message = "upload is locked by another request"
+		httpStatusCode = http.StatusLocked
+		return
+	}
+
+	// the previous request may have moved or finished it
+	upload, httpResponse, httpStatusCode = loadUpload(ownerID, id)
+	if httpStatusCode != http.StatusOK {
+		service.UnlockUpload(id)
+	}
+	return
+}
+
+// loadUpload - unfinished upload of the owner with the offset
+// to resume from
+func loadUpload(ownerID uint, id string) (upload model.MediaUpload, httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := database.GetDB()
+
+	if err := db.Where("id = ? AND owner_id = ?", id, ownerID).First(&upload).Error; err != nil {
+		httpResponse.Message = "upload not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	if upload.ExpiresAt.Before(time.Now()) {
+		httpResponse.Message = "upload expired"
+		httpStatusCode = http.StatusGone
+		return
+	}
+
+	// what reached the disk survives a restart of the server
+	info, err := os.Stat(service.UploadFile(upload.ID))
+	if err != nil {
+		if err := service.RemoveUpload(upload); err != nil {
+			log.WithError(err).Error("error code: 1444")
+		}
+		httpResponse.Message = "upload not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+	upload.Offset = info.Size()
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetMediaUpload handles jobs for controller.GetMediaUpload
+func GetMediaUpload(ownerID uint, id string) (upload model.MediaUpload, httpResponse model.HTTPResponse, httpStatusCode int) {
+	return loadUpload(ownerID, id)
+}
+
+// WriteMediaUpload handles jobs for controller.PatchMediaUpload
+//
+// The chunk is appended at the given offset. Once all bytes
+// have arrived, the file is checked and stored like any
+// other upload and the upload itself is dropped.
+func WriteMediaUpload(ownerID uint, id string, offset int64, body io.Reader) (upload model.MediaUpload, httpResponse model.HTTPResponse, httpStatusCode int) {
+	upload, httpResponse, httpStatusCode = lockOwnUpload(ownerID, id)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	defer service.UnlockUpload(upload.ID)
+
+	if offset != upload.Offset {
+		httpResponse.Message = "Upload-Offset does not match"
+		httpStatusCode = http.StatusConflict
+		return
+	}
+
+	f, err := os.OpenFile(service.UploadFile(upload.ID), os.O_WRONLY|os.O_APPEND, 0o600)
+	if err != nil {
+		log.WithError(err).Error("error code: 1445")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	// the bytes received before a connection breaks are kept
+	n, copyErr := io.Copy(f, io.LimitReader(body, upload.Length-upload.Offset+1))
+	if upload.Offset+n > upload.Length {
+		err = f.Truncate(upload.Offset)
+		n = 0
+		copyErr = errors.New("more data than announced")
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	upload.Offset += n
+	if err != nil {
+		log.WithError(err).Error("error code: 1445")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	// progress keeps the upload alive
+	upload.ExpiresAt = time.Now().Add(config.GetConfig().Media.UploadExpiry)
+	if err := database.GetDB().Model(&upload).Update("expires_at", upload.ExpiresAt).Error; err != nil {
+		log.WithError(err).Error("error code: 1443")
+	}
+
+	if copyErr != nil {
+		if upload.Offset == offset {
+			httpResponse.Message = "chunk exceeds Upload-Length"
+			httpStatusCode = http.StatusRequestEntityTooLarge
+			return
+		}
+		httpResponse.Message = "chunk incomplete, resume from Upload-Offset"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	if upload.Offset < upload.Length {
+		httpStatusCode = http.StatusNoContent
+		return
+	}
+
+	return completeUpload(upload)
+}
+
+// completeUpload - store a complete upload in the media
+// library, the upload is dropped either way as its data
+// can no longer change
+func completeUpload(upload model.MediaUpload) (model.MediaUpload, model.HTTPResponse, int) {
+	defer func() {
+		if err := service.RemoveUpload(upload); err != nil {
+			log.WithError(err).Error("error code: 1444")
+		}
+	}()
+
+	f, err := os.Open(service.UploadFile(upload.ID))
+	if err != nil {
+		log.WithError(err).Error("error code: 1445")
+		return upload, model.HTTPResponse{Message: "internal server error"}, http.StatusInternalServerError
+	}
+	defer f.Close()
+
+	// the target folder may have changed meanwhile
+	httpResponse, httpStatusCode := checkMediaManager(upload.OwnerID, upload.Folder)
+	if httpStatusCode != http.StatusOK {
+		return upload, httpResponse, httpStatusCode
+	}
+
+	entry, httpResponse, httpStatusCode := saveMedia(upload.OwnerID, upload.Folder, upload.Name, upload.Private, f)
+	if httpStatusCode != http.StatusCreated {
+		return upload, httpResponse, httpStatusCode
+	}
+	upload.Media = &entry
+
+	return upload, httpResponse, http.StatusNoContent
+}
+
+// DeleteMediaUpload handles jobs for controller.DeleteMediaUpload
+func DeleteMediaUpload(ownerID uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	upload, httpResponse, httpStatusCode := lockOwnUpload(ownerID, id)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	defer service.UnlockUpload(upload.ID)
+
+	if err := service.RemoveUpload(upload); err != nil {
+		log.WithError(err).Error("error code: 1444")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpStatusCode = http.StatusNoContent
+	return
+}
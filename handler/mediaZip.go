@@ -0,0 +1,345 @@
+package handler
+
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io"
+	"io/fs"
+	"math"
+	"mime/multipart"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// ImportMedia handles jobs for controller.ImportMedia
+//
+// The whole archive is checked before anything is extracted:
+// entries escaping the target folder, too many entries, too
+// much data and suspicious compression ratios reject it.
+// Single files which cannot be stored are skipped and
+// reported instead.
+func ImportMedia(ownerID uint, folder string, fileHeader *multipart.FileHeader) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	configMedia := config.GetConfig().Media
+
+	folder = mediaPath(folder)
+
+	dirInfo, err := database.GetStorage().Stat(folder)
+	if err != nil || !dirInfo.IsDir || isHiddenPath(folder) {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	httpResponse, httpStatusCode = checkMediaManager(ownerID, folder)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if fileHeader.Size > configMedia.ZipMaxSize {
+		httpResponse.Message = "archive too large"
+		httpStatusCode = http.StatusRequestEntityTooLarge
+		return
+	}
+
+	src, err := fileHeader.Open()
+	if err != nil {
+		log.WithError(err).Error("error code: 1434")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	defer src.Close()
+
+	archive, err := zip.NewReader(src, fileHeader.Size)
+	if err != nil {
+		httpResponse.Message = "invalid ZIP archive"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	report := model.MediaImport{Folder: folder, Created: []string{}}
+	folders := []string{}
+	files := map[string]*zip.File{}
+	var total uint64
+
+	for _, f := range archive.File {
+		p, err := lib.ZipEntryPath(f.Name)
+		if err != nil {
+			httpResponse.Message = "invalid entry in archive: " + f.Name
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+
+		// metadata of the archiving tools, i.e. __MACOSX or .DS_Store
+		if isHiddenPath(p) || strings.HasPrefix(p+"/", "__MACOSX/") {
+			continue
+		}
+
+		if f.FileInfo().IsDir() {
+			folders = append(folders, p)
+			continue
+		}
+
+		if len(files) == configMedia.ZipMaxFiles {
+			httpResponse.Message = "too many files in archive"
+			httpStatusCode = http.StatusRequestEntityTooLarge
+			return
+		}
+
+		// the reader of archive/zip refuses to produce more than
+		// the declared size, so the declared sizes can be trusted
+		if f.UncompressedSize64 > 0 && f.UncompressedSize64 > f.CompressedSize64*uint64(configMedia.ZipMaxRatio) {
+			httpResponse.Message = "suspicious compression ratio: " + f.Name
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+		total += f.UncompressedSize64
+		if total > uint64(configMedia.ZipMaxExtractSize) {
+			httpResponse.Message = "archive expands too much"
+			httpStatusCode = http.StatusRequestEntityTooLarge
+			return
+		}
+
+		if _, ok := files[p]; ok {
+			report.Skipped = append(report.Skipped, model.MediaImportSkip{Name: f.Name, Reason: "duplicate entry"})
+			continue
+		}
+		files[p] = f
+		folders = append(folders, path.Dir(p))
+	}
+
+	httpResponse, httpStatusCode = checkMediaQuota(ownerID, int64(total))
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	// parents before their children
+	sort.Strings(folders)
+	failed := map[string]string{}
+	for _, p := range folders {
+		if p == "." {
+			continue
+		}
+		if reason := importFolder(ownerID, folder, p, failed); reason != "" {
+			report.Skipped = append(report.Skipped, model.MediaImportSkip{Name: p + "/", Reason: reason})
+		}
+	}
+
+	paths := make([]string, 0, len(files))
+	for p := range files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+
+	for _, p := range paths {
+		f := files[p]
+
+		if reason, ok := failed[path.Dir(p)]; ok {
+			report.Skipped = append(report.Skipped, model.MediaImportSkip{Name: f.Name, Reason: reason})
+			continue
+		}
+		if f.UncompressedSize64 > uint64(configMedia.MaxUploadSize) {
+			report.Skipped = append(report.Skipped, model.MediaImportSkip{Name: f.Name, Reason: "file too large"})
+			continue
+		}
+
+		data, err := readZipFile(f)
+		if err != nil {
+			report.Skipped = append(report.Skipped, model.MediaImportSkip{Name: f.Name, Reason: "corrupt entry"})
+			continue
+		}
+
+		entry, resp, statusCode := saveMedia(ownerID, mediaPath(folder+"/"+path.Dir(p)), path.Base(p), false, bytes.NewReader(data))
+		if statusCode != http.StatusCreated {
+			reason, _ := resp.Message.(string)
+			report.Skipped = append(report.Skipped, model.MediaImportSkip{Name: f.Name, Reason: reason})
+			continue
+		}
+		report.Created = append(report.Created, entry.Path)
+	}
+
+	httpResponse.Message = report
+	httpStatusCode = http.StatusCreated
+	if len(report.Created) == 0 {
+		httpStatusCode = http.StatusOK
+	}
+	return
+}
+
+// importFolder - create a folder of an archive with its
+// stored metadata unless it exists, folders which cannot be
+// created are remembered with the reason
+func importFolder(ownerID uint, root, p string, failed map[string]string) string {
+	if reason, ok := failed[p]; ok {
+		return reason
+	}
+	if parent := path.Dir(p); parent != "." {
+		if reason := importFolder(ownerID, root, parent, failed); reason != "" {
+			failed[p] = reason
+			return reason
+		}
+	}
+
+	s := database.GetStorage()
+	folderPath := mediaPath(root + "/" + p)
+
+	info, err := s.Stat(folderPath)
+	if err == nil {
+		if !info.IsDir {
+			failed[p] = "a file with this name exists"
+		}
+		return failed[p]
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		log.WithError(err).Error("error code: 1435")
+		failed[p] = "internal server error"
+		return failed[p]
+	}
+
+	if err := s.Mkdir(folderPath); err != nil {
+		log.WithError(err).Error("error code: 1435")
+		failed[p] = "internal server error"
+		return failed[p]
+	}
+
+	entry := model.Media{
+		OwnerID:  ownerID,
+		Name:     path.Base(folderPath),
+		Folder:   mediaPath(path.Dir(folderPath)),
+		Path:     folderPath,
+		IsFolder: true,
+	}
+	if err := database.GetDB().Create(&entry).Error; err != nil {
+		log.WithError(err).Error("error code: 1436")
+	}
+
+	return ""
+}
+
+// readZipFile - content of an entry of an archive
+func readZipFile(f *zip.File) ([]byte, error) {
+	rc, err := f.Open()
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	return io.ReadAll(rc)
+}
+
+// ExportMedia handles jobs for controller.ExportMedia, the
+// tree is written with WriteMediaZip
+//
+// Private entries are left out unless the caller may change them
+func ExportMedia(userID uint, p string) (folder model.Media, httpResponse model.HTTPResponse, httpStatusCode int) {
+	p = mediaPath(p)
+	if isHiddenPath(p) {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	info, err := database.GetStorage().Stat(p)
+	if err != nil || !info.IsDir {
+		httpResponse.Message = "folder not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	private, err := service.IsPrivateMedia(database.GetDB(), p)
+	if err != nil {
+		log.WithError(err).Error("error code: 1495")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if private {
+		httpResponse, httpStatusCode = checkMediaManager(userID, p)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	// the whole tree in one page
+	listing := mediaListing{sortBy: "name", limit: math.MaxInt, depth: math.MaxInt, userID: userID}
+	folder, err = GetFileInfo(p, listing)
+	if err != nil {
+		log.WithError(err).Error("error code: 1437")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if folder.Name == "" || folder.Name == "." {
+		folder.Name = "media"
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// WriteMediaZip - stream a folder returned by ExportMedia as
+// a ZIP archive, the response has already started when an
+// error happens, so it is only logged
+func WriteMediaZip(w io.Writer, folder model.Media) {
+	archive := zip.NewWriter(w)
+
+	if err := writeZipEntries(archive, folder.Name, folder.Children); err != nil {
+		log.WithError(err).Error("error code: 1438")
+		return
+	}
+	if err := archive.Close(); err != nil {
+		log.WithError(err).Error("error code: 1438")
+	}
+}
+
+// writeZipEntries - add a folder of the tree to an archive
+func writeZipEntries(archive *zip.Writer, prefix string, entries []model.Media) error {
+	s := database.GetStorage()
+
+	for _, entry := range entries {
+		name := prefix + "/" + entry.Name
+
+		if entry.IsFolder {
+			if _, err := archive.CreateHeader(&zip.FileHeader{Name: name + "/", Modified: entry.ModTime}); err != nil {
+				return err
+			}
+			if err := writeZipEntries(archive, name, entry.Children); err != nil {
+				return err
+			}
+			continue
+		}
+
+		header := &zip.FileHeader{Name: name, Modified: entry.ModTime, Method: zip.Deflate}
+		// compressed formats do not shrink any more
+		if strings.HasPrefix(entry.MimeType, "image/") && entry.MimeType != "image/svg+xml" {
+			header.Method = zip.Store
+		}
+
+		zw, err := archive.CreateHeader(header)
+		if err != nil {
+			return err
+		}
+		file, err := s.Open(entry.Path)
+		if err != nil {
+			return err
+		}
+		_, err = io.Copy(zw, file)
+		file.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
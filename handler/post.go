@@ -2,56 +2,323 @@ package handler
 
 import (
 	"net/http"
+	"strconv"
+	"strings"
 	"time"
 
 	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
 
 	gdatabase "github.com/tinkerbaj/gintemp/database"
 
 	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+	"github.com/tinkerbaj/gintemp/service"
 )
 
 // GetPosts handles jobs for controller.GetPosts
-func GetPosts() (httpResponse model.HTTPResponse, httpStatusCode int) {
-	db := gdatabase.GetDB()
-	posts := []model.Post{}
+//
+// Pages are either addressed by offset or by the cursor of the
+// previous page, an empty page is no error
+//
+// Everybody sees the published posts, authors also their own
+// posts in every other state
+func GetPosts(userID uint, filter model.PostFilter) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	listing, err := newPostListing(filter)
+	if err != nil {
+		httpResponse.Message = err.Error()
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	filter = listing.filter
 
-	if err := db.Find(&posts).Error; err != nil {
+	db := visiblePosts(gdatabase.GetDB().Model(&model.Post{}), userID)
+	if status := strings.ToLower(strings.TrimSpace(filter.Status)); status != "" {
+		if !model.IsPostStatus(status) {
+			httpResponse.Message = "unknown status"
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+		db = db.Where("status = ?", status)
+	}
+	if filter.Author != 0 {
+		db = db.Where("user_id = ?", filter.Author)
+	}
+	if slug := strings.TrimSpace(filter.Category); slug != "" {
+		categories, err := categorySubtree(gdatabase.GetDB(), slug)
+		if err != nil {
+			log.WithError(err).Error("error code: 1204")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		// an unknown category matches no post
+		db = db.Where("id IN (?)", gdatabase.GetDB().Table("post_categories").Select("post_id").Where("category_id IN ?", append(categories, 0)))
+	}
+	if slug := strings.TrimSpace(filter.Tag); slug != "" {
+		db = db.Where("id IN (?)", gdatabase.GetDB().Table("post_tags").Select("post_tags.post_id").
+			Joins("JOIN tags ON tags.id = post_tags.tag_id").Where("tags.slug = ?", slug))
+	}
+	if !listing.from.IsZero() {
+		db = db.Where("created_at >= ?", listing.from)
+	}
+	if !listing.to.IsZero() {
+		db = db.Where("created_at < ?", listing.to)
+	}
+	if title := strings.TrimSpace(filter.Title); title != "" {
+		db = db.Where("LOWER(title) LIKE ?", "%"+strings.ToLower(title)+"%")
+	}
+
+	page := model.PostPage{Items: []model.Post{}}
+	page.Meta.Limit = filter.Limit
+	page.Meta.Offset = filter.Offset
+
+	if err := db.Session(&gorm.Session{}).Count(&page.Meta.Total).Error; err != nil {
 		log.WithError(err).Error("error code: 1201")
 		httpResponse.Message = "internal server error"
 		httpStatusCode = http.StatusInternalServerError
 		return
 	}
 
-	if len(posts) == 0 {
-		httpResponse.Message = "no article found"
-		httpStatusCode = http.StatusNotFound
+	// keyset pagination, the ID breaks ties
+	column, op, order := listing.column, ">", " ASC"
+	if listing.desc {
+		op, order = "<", " DESC"
+	}
+	if after := listing.after; after != nil {
+		t := time.Unix(0, after.Time)
+		db = db.Where(column+" "+op+" ? OR ("+column+" = ? AND id "+op+" ?)", t, t, after.ID)
+	}
+
+	posts := []model.Post{}
+	if err := db.Preload("Categories").Preload("Tags").Order(column + order).Order("id" + order).Offset(filter.Offset).Limit(filter.Limit + 1).Find(&posts).Error; err != nil {
+		log.WithError(err).Error("error code: 1201")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	page.Items = posts
+	if len(posts) > filter.Limit {
+		page.Items = posts[:filter.Limit]
+		next, err := listing.cursor(page.Items[len(page.Items)-1])
+		if err != nil {
+			log.WithError(err).Error("error code: 1202")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		page.Meta.NextCursor = next
+	}
+
+	items := make([]*model.Post, 0, len(page.Items))
+	for i := range page.Items {
+		items = append(items, &page.Items[i])
+	}
+	if err := withPostMedia(gdatabase.GetDB(), items); err != nil {
+		log.WithError(err).Error("error code: 1206")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
 		return
 	}
 
-	httpResponse.Message = posts
+	httpResponse.Message = page
 	httpStatusCode = http.StatusOK
 	return
 }
 
+// visiblePosts - posts the user may read: the published ones
+// and every post of its own
+func visiblePosts(db *gorm.DB, userID uint) *gorm.DB {
+	if userID == 0 {
+		return db.Where("status = ?", model.PostPublished)
+	}
+
+	return db.Where("(status = ? OR user_id = ?)", model.PostPublished, userID)
+}
+
 // GetPost handles jobs for controller.GetPost
-func GetPost(id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
-	db := gdatabase.GetDB()
+//
+// A post is found by its ID or slug, the Markdown body comes
+// with its sanitized HTML. Each read of a published post counts
+// as a view.
+func GetPost(userID uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := visiblePosts(gdatabase.GetDB(), userID)
 	post := model.Post{}
 
-	if err := db.Where("post_id = ?", id).First(&post).Error; err != nil {
+	if _, err := strconv.ParseUint(id, 10, 64); err == nil {
+		db = db.Where("id = ?", id)
+	} else {
+		db = db.Where("slug = ?", id)
+	}
+	if err := db.Preload("Categories").Preload("Tags").First(&post).Error; err != nil {
 		httpResponse.Message = "article not found"
 		httpStatusCode = http.StatusNotFound
 		return
 	}
 
+	bodyHTML, err := postBodyHTML(gdatabase.GetDB(), post)
+	if err != nil {
+		log.WithError(err).Error("error code: 1203")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	post.BodyHTML = bodyHTML
+
+	if err := withPostMedia(gdatabase.GetDB(), []*model.Post{&post}); err != nil {
+		log.WithError(err).Error("error code: 1205")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	if post.Status == model.PostPublished {
+		// a lost view is no reason to fail the request
+		if err := service.CountPostView(post.ID); err != nil {
+			log.WithError(err).Error("error code: 1207")
+		}
+	}
+	stats, err := postStats(gdatabase.GetDB(), post.ID, userID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1208")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	post.Stats = &stats
+
 	httpResponse.Message = post
 	httpStatusCode = http.StatusOK
 	return
 }
 
+// setPostState - check the requested status and publication
+// time and apply them to the post
+//
+// Scheduled posts need a publication time, those which are
+// already due are published right away. A published post
+// keeps the time it was first published.
+func setPostState(post *model.Post, req model.Post) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	status := strings.ToLower(strings.TrimSpace(req.Status))
+	if status == "" {
+		status = post.Status
+	}
+	if status == "" {
+		status = model.PostDraft
+	}
+	if !model.IsPostStatus(status) {
+		httpResponse.Message = "status must be draft, scheduled, published or archived"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	publishAt := post.PublishAt
+	if req.PublishAt != nil {
+		publishAt = req.PublishAt
+	}
+
+	now := time.Now()
+	switch status {
+	case model.PostScheduled:
+		if publishAt == nil {
+			httpResponse.Message = "publishAt is required for scheduled posts"
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+		if !publishAt.After(now) {
+			status = model.PostPublished
+		}
+	case model.PostPublished:
+		if publishAt == nil || publishAt.After(now) {
+			publishAt = &now
+		}
+	}
+
+	post.Status = status
+	post.PublishAt = publishAt
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// setPostSlug - give the post the requested slug, or one made
+// of its title when it has none yet
+//
+// A generated slug gets a number when it is taken already,
+// a requested one must be free.
+func setPostSlug(db *gorm.DB, post *model.Post, req *string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	taken := func(slug string) (bool, error) {
+		// GET /posts/search would hide the post
+		if slug == "search" {
+			return true, nil
+		}
+		var count int64
+		// deleted posts keep their slug
+		err := db.Unscoped().Model(&model.Post{}).Where("slug = ? AND id <> ?", slug, post.ID).Count(&count).Error
+		return count > 0, err
+	}
+
+	if req != nil {
+		slug := lib.Slugify(*req)
+		if slug == "" {
+			httpResponse.Message = "invalid slug"
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+
+		exists, err := taken(slug)
+		if err != nil {
+			log.WithError(err).Error("error code: 1212")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if exists {
+			httpResponse.Message = "slug already in use"
+			httpStatusCode = http.StatusConflict
+			return
+		}
+
+		post.Slug = &slug
+		httpStatusCode = http.StatusOK
+		return
+	}
+
+	// links to a post keep working when its title changes
+	if post.Slug != nil {
+		httpStatusCode = http.StatusOK
+		return
+	}
+
+	base := lib.Slugify(post.Title)
+	if base == "" {
+		base = "post"
+	}
+	slug := base
+	for i := 2; ; i++ {
+		exists, err := taken(slug)
+		if err != nil {
+			log.WithError(err).Error("error code: 1212")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if !exists {
+			break
+		}
+		slug = base + "-" + strconv.Itoa(i)
+	}
+
+	post.Slug = &slug
+	httpStatusCode = http.StatusOK
+	return
+}
+
 // CreatePost handles jobs for controller.CreatePost
-func CreatePost(userIDAuth uint64, post model.Post) (httpResponse model.HTTPResponse, httpStatusCode int) {
+//
+// New posts are drafts unless another status is given
+func CreatePost(userIDAuth uint, post model.Post) (httpResponse model.HTTPResponse, httpStatusCode int) {
 	db := gdatabase.GetDB()
 	user := model.User{}
 	postFinal := model.Post{}
@@ -68,6 +335,15 @@ func CreatePost(userIDAuth uint64, post model.Post) (httpResponse model.HTTPResp
 	postFinal.Body = post.Body
 	postFinal.UserID = user.ID
 
+	httpResponse, httpStatusCode = setPostState(&postFinal, post)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	httpResponse, httpStatusCode = setPostSlug(db, &postFinal, post.Slug)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
 	tx := db.Begin()
 	if err := tx.Create(&postFinal).Error; err != nil {
 		tx.Rollback()
@@ -76,15 +352,38 @@ func CreatePost(userIDAuth uint64, post model.Post) (httpResponse model.HTTPResp
 		httpStatusCode = http.StatusInternalServerError
 		return
 	}
+	if err := recordPostRevision(tx, postFinal, user.ID, postFinal.CreatedAt, nil); err != nil {
+		tx.Rollback()
+		log.WithError(err).Error("error code: 1213")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	httpResponse, httpStatusCode = setPostTaxonomy(tx, &postFinal, post.Categories, post.Tags)
+	if httpStatusCode != http.StatusOK {
+		tx.Rollback()
+		return
+	}
+	httpResponse, httpStatusCode = setPostMedia(tx, &postFinal, post.Cover, post.Attachments)
+	if httpStatusCode != http.StatusOK {
+		tx.Rollback()
+		return
+	}
 	tx.Commit()
 
+	if postFinal.Status == model.PostScheduled {
+		service.WakePostScheduler()
+	}
+
 	httpResponse.Message = postFinal
 	httpStatusCode = http.StatusCreated
 	return
 }
 
 // UpdatePost handles jobs for controller.UpdatePost
-func UpdatePost(userIDAuth uint64, id string, post model.Post) (httpResponse model.HTTPResponse, httpStatusCode int) {
+//
+// Every change of the title or body is kept as a revision
+func UpdatePost(userIDAuth uint, id string, post model.Post) (httpResponse model.HTTPResponse, httpStatusCode int) {
 	db := gdatabase.GetDB()
 	user := model.User{}
 	postFinal := model.Post{}
@@ -97,17 +396,27 @@ func UpdatePost(userIDAuth uint64, id string, post model.Post) (httpResponse mod
 	}
 
 	// does the post exist + does the user have right to modify this post
-	if err := db.Where("post_id = ?", id).Where("id_user = ?", user.ID).First(&postFinal).Error; err != nil {
+	if err := db.Where("id = ?", id).Where("user_id = ?", user.ID).First(&postFinal).Error; err != nil {
 		httpResponse.Message = "user may not have access to perform this task"
 		httpStatusCode = http.StatusForbidden
 		return
 	}
 
+	previous := postFinal
 	// user must not be able to manipulate all fields
 	postFinal.UpdatedAt = time.Now()
 	postFinal.Title = post.Title
 	postFinal.Body = post.Body
 
+	httpResponse, httpStatusCode = setPostState(&postFinal, post)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	httpResponse, httpStatusCode = setPostSlug(db, &postFinal, post.Slug)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
 	tx := db.Begin()
 	if err := tx.Save(&postFinal).Error; err != nil {
 		tx.Rollback()
@@ -116,15 +425,46 @@ func UpdatePost(userIDAuth uint64, id string, post model.Post) (httpResponse mod
 		httpStatusCode = http.StatusInternalServerError
 		return
 	}
+	// a change of the state alone is no new revision
+	if postFinal.Title != previous.Title || postFinal.Body != previous.Body {
+		if err := keepFirstPostRevision(tx, previous); err != nil {
+			tx.Rollback()
+			log.WithError(err).Error("error code: 1222")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if err := recordPostRevision(tx, postFinal, user.ID, postFinal.UpdatedAt, nil); err != nil {
+			tx.Rollback()
+			log.WithError(err).Error("error code: 1222")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+	}
+	httpResponse, httpStatusCode = setPostTaxonomy(tx, &postFinal, post.Categories, post.Tags)
+	if httpStatusCode != http.StatusOK {
+		tx.Rollback()
+		return
+	}
+	httpResponse, httpStatusCode = setPostMedia(tx, &postFinal, post.Cover, post.Attachments)
+	if httpStatusCode != http.StatusOK {
+		tx.Rollback()
+		return
+	}
 	tx.Commit()
 
+	if postFinal.Status == model.PostScheduled {
+		service.WakePostScheduler()
+	}
+
 	httpResponse.Message = postFinal
 	httpStatusCode = http.StatusOK
 	return
 }
 
 // DeletePost handles jobs for controller.DeletePost
-func DeletePost(userIDAuth uint64, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+func DeletePost(userIDAuth uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
 	db := gdatabase.GetDB()
 	user := model.User{}
 	post := model.Post{}
@@ -137,7 +477,7 @@ func DeletePost(userIDAuth uint64, id string) (httpResponse model.HTTPResponse,
 	}
 
 	// does the post exist + does the user have right to delete this post
-	if err := db.Where("post_id = ?", id).Where("id_user = ?", user.ID).First(&post).Error; err != nil {
+	if err := db.Where("id = ?", id).Where("user_id = ?", user.ID).First(&post).Error; err != nil {
 		httpResponse.Message = "user may not have access to perform this task"
 		httpStatusCode = http.StatusForbidden
 		return
@@ -0,0 +1,153 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// GetFeed handles jobs for controller.GetFeed
+//
+// The latest published posts, optionally of one author or
+// with one tag, newest first. baseURL is the public URL of
+// the API, feedURL the URL the feed is fetched from.
+func GetFeed(baseURL, feedURL string, filter model.FeedFilter) (feed lib.Feed, httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+	configure := config.GetConfig()
+
+	feed.Title = configure.Post.FeedTitle
+	feed.Description = "Latest articles"
+	feed.HomeURL = baseURL
+	feed.FeedURL = feedURL
+
+	query := visiblePosts(db, 0)
+
+	if author := strings.TrimSpace(filter.Author); author != "" {
+		user := model.User{}
+		userQuery := db.Where("username = ?", author)
+		if _, err := strconv.ParseUint(author, 10, 64); err == nil {
+			userQuery = db.Where("id = ?", author)
+		}
+		if err := userQuery.First(&user).Error; err != nil {
+			httpResponse.Message = "author not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		query = query.Where("user_id = ?", user.ID)
+		feed.Title += " - " + feedAuthorName(user)
+		feed.Description = "Latest articles by " + feedAuthorName(user)
+	}
+
+	if slug := strings.TrimSpace(filter.Tag); slug != "" {
+		tag := model.Tag{}
+		if err := db.Where("slug = ?", slug).First(&tag).Error; err != nil {
+			httpResponse.Message = "tag not found"
+			httpStatusCode = http.StatusNotFound
+			return
+		}
+		query = query.Where("id IN (?)", db.Table("post_tags").Select("post_id").Where("tag_id = ?", tag.ID))
+		feed.Title += " - #" + tag.Name
+		feed.Description += " tagged " + tag.Name
+	}
+
+	// posts written before publishing was scheduled have no
+	// publish date
+	posts := []model.Post{}
+	err := query.Preload("Tags").Order("COALESCE(publish_at, created_at) DESC").Order("id DESC").
+		Limit(configure.Post.FeedLimit).Find(&posts).Error
+	if err != nil {
+		log.WithError(err).Error("error code: 1701")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	userIDs := []uint{}
+	for _, post := range posts {
+		userIDs = append(userIDs, post.UserID)
+	}
+	users := []model.User{}
+	if len(userIDs) > 0 {
+		if err := db.Where("id IN ?", userIDs).Find(&users).Error; err != nil {
+			log.WithError(err).Error("error code: 1702")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+	}
+	authors := map[uint]lib.FeedAuthor{}
+	for _, user := range users {
+		authors[user.ID] = lib.FeedAuthor{
+			Name: feedAuthorName(user),
+			URL:  baseURL + "/api/v1/posts?author=" + strconv.FormatUint(uint64(user.ID), 10),
+		}
+	}
+
+	feed.Items = []lib.FeedItem{}
+	for _, post := range posts {
+		bodyHTML, err := postBodyHTML(db, post)
+		if err != nil {
+			log.WithError(err).Error("error code: 1703")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+
+		published := post.CreatedAt
+		if post.PublishAt != nil {
+			published = *post.PublishAt
+		}
+		if post.UpdatedAt.After(feed.Updated) {
+			feed.Updated = post.UpdatedAt
+		}
+
+		id := strconv.FormatUint(uint64(post.ID), 10)
+		if post.Slug != nil {
+			id = *post.Slug
+		}
+
+		item := lib.FeedItem{
+			ID:          baseURL + "/api/v1/posts/" + strconv.FormatUint(uint64(post.ID), 10),
+			URL:         baseURL + "/api/v1/posts/" + id,
+			Title:       post.Title,
+			ContentHTML: bodyHTML,
+			Published:   published,
+			Updated:     post.UpdatedAt,
+			Author:      authors[post.UserID],
+		}
+		if item.Author.Name == "" {
+			item.Author.Name = "user #" + strconv.FormatUint(uint64(post.UserID), 10)
+		}
+		for _, tag := range post.Tags {
+			item.Tags = append(item.Tags, tag.Name)
+		}
+
+		feed.Items = append(feed.Items, item)
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// feedAuthorName - full name of the user, the username when
+// no name is given
+func feedAuthorName(user model.User) string {
+	if name := strings.TrimSpace(user.FirstName + " " + user.LastName); name != "" {
+		return name
+	}
+	if name := strings.TrimSpace(user.Name); name != "" {
+		return name
+	}
+	if user.Username != "" {
+		return user.Username
+	}
+	return "user #" + strconv.FormatUint(uint64(user.ID), 10)
+}
@@ -0,0 +1,70 @@
+package handler
+
+import (
+	"sync"
+
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+var (
+	postMarkdownOnce sync.Once
+	postMarkdown     *lib.MarkdownRenderer
+)
+
+// postRenderer - Markdown renderer with the configured HTML
+// allow-list
+func postRenderer() *lib.MarkdownRenderer {
+	postMarkdownOnce.Do(func() {
+		postMarkdown = lib.NewMarkdownRenderer(config.GetConfig().Post.HTMLAllowList)
+	})
+
+	return postMarkdown
+}
+
+// renderRevision - cache the HTML of the body in the revision
+func renderRevision(revision *model.PostRevision) error {
+	r := postRenderer()
+
+	bodyHTML, err := r.Render(revision.Body)
+	if err != nil {
+		return err
+	}
+	revision.BodyHTML = bodyHTML
+	revision.HTMLKey = r.Key
+
+	return nil
+}
+
+// postBodyHTML - HTML of the body of the post, cached in its
+// latest revision
+//
+// The cache is renewed when the allow-list has changed, posts
+// without revisions are rendered each time
+func postBodyHTML(db *gorm.DB, post model.Post) (string, error) {
+	revision := model.PostRevision{}
+	err := db.Where("post_id = ?", post.ID).Order("number DESC").Limit(1).Find(&revision).Error
+	if err != nil {
+		return "", err
+	}
+
+	if revision.ID == 0 || revision.Body != post.Body {
+		return postRenderer().Render(post.Body)
+	}
+	if revision.HTMLKey == postRenderer().Key {
+		return revision.BodyHTML, nil
+	}
+
+	if err := renderRevision(&revision); err != nil {
+		return "", err
+	}
+	err = db.Model(&revision).UpdateColumns(map[string]interface{}{
+		"body_html": revision.BodyHTML,
+		"html_key":  revision.HTMLKey,
+	}).Error
+
+	return revision.BodyHTML, err
+}
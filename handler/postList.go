@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// postListLimit - page size of the post list unless asked otherwise
+const postListLimit int = 20
+
+// postListMaxLimit - largest page size a client may ask for
+const postListMaxLimit int = 100
+
+// postListing - validated filters, sort and paging options
+// of the post list
+type postListing struct {
+	filter model.PostFilter
+	from   time.Time
+	to     time.Time
+	column string // created_at or updated_at
+	desc   bool
+	after  *postCursor
+}
+
+// postCursor - position of the last post of a page
+type postCursor struct {
+	SortBy string `json:"s"`
+	Desc   bool   `json:"d,omitempty"`
+	Time   int64  `json:"t"` // unix nano
+	ID     uint   `json:"i"`
+}
+
+// newPostListing - check the list options of a request and
+// fill in the defaults
+func newPostListing(filter model.PostFilter) (postListing, error) {
+	listing := postListing{filter: filter, column: "created_at", desc: true}
+	var err error
+
+	switch strings.ToLower(strings.TrimSpace(filter.Sort)) {
+	case "", "created":
+	case "updated":
+		listing.column = "updated_at"
+	default:
+		return listing, errors.New("sort must be created or updated")
+	}
+
+	switch strings.ToLower(strings.TrimSpace(filter.Order)) {
+	case "", "desc":
+	case "asc":
+		listing.desc = false
+	default:
+		return listing, errors.New("order must be asc or desc")
+	}
+
+	if filter.From != "" {
+		if listing.from, _, err = parsePostDate(filter.From); err != nil {
+			return listing, errors.New("from must be a date or an RFC 3339 time")
+		}
+	}
+	if filter.To != "" {
+		var day bool
+		if listing.to, day, err = parsePostDate(filter.To); err != nil {
+			return listing, errors.New("to must be a date or an RFC 3339 time")
+		}
+		if day {
+			listing.to = listing.to.AddDate(0, 0, 1)
+		}
+	}
+
+	if filter.Limit < 0 || filter.Offset < 0 {
+		return listing, errors.New("limit and offset must not be negative")
+	}
+	if listing.filter.Limit == 0 {
+		listing.filter.Limit = postListLimit
+	}
+	if listing.filter.Limit > postListMaxLimit {
+		listing.filter.Limit = postListMaxLimit
+	}
+
+	if filter.Cursor != "" {
+		if filter.Offset > 0 {
+			return listing, errors.New("cursor and offset cannot be combined")
+		}
+		after := postCursor{}
+		if err := lib.DecodeCursor(filter.Cursor, &after); err != nil {
+			return listing, err
+		}
+		// a cursor is only valid for the order it was issued for
+		if after.SortBy != listing.column || after.Desc != listing.desc {
+			return listing, lib.ErrInvalidCursor
+		}
+		listing.after = &after
+	}
+
+	return listing, nil
+}
+
+// parsePostDate - RFC 3339 time or a date, day is true for
+// a date
+func parsePostDate(s string) (t time.Time, day bool, err error) {
+	s = strings.TrimSpace(s)
+	if t, err = time.Parse(time.RFC3339, s); err == nil {
+		return t, false, nil
+	}
+
+	t, err = time.Parse(time.DateOnly, s)
+	return t, true, err
+}
+
+// cursor - cursor pointing at a post
+func (l postListing) cursor(post model.Post) (string, error) {
+	t := post.CreatedAt
+	if l.column == "updated_at" {
+		t = post.UpdatedAt
+	}
+
+	return lib.EncodeCursor(postCursor{
+		SortBy: l.column,
+		Desc:   l.desc,
+		Time:   t.UnixNano(),
+		ID:     post.ID,
+	})
+}
@@ -0,0 +1,262 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// postMaxAttachments - largest gallery of a post
+const postMaxAttachments int = 50
+
+// postMediaEntry - media entry of an attachment, found by its
+// ID or path
+//
+// Only public files the author may change can be linked with
+// a post, others are reported as unknown
+func postMediaEntry(tx *gorm.DB, authorID uint, attachment model.PostAttachment) (entry model.Media, httpResponse model.HTTPResponse, httpStatusCode int) {
+	name := attachment.Path
+	query := tx.Where("path = ?", mediaPath(attachment.Path))
+	if attachment.MediaID != 0 {
+		name = strconv.FormatUint(uint64(attachment.MediaID), 10)
+		query = tx.Where("id = ?", attachment.MediaID)
+	}
+
+	if err := query.First(&entry).Error; err != nil {
+		if !errors.Is(err, gorm.ErrRecordNotFound) {
+			log.WithError(err).Error("error code: 1751")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		entry.Path = ""
+	}
+	// deleted entries wait in the hidden trash
+	if entry.Path != "" && !isHiddenPath(entry.Path) {
+		allowed, err := service.CanManageMedia(tx, authorID, entry.Path)
+		if err != nil {
+			log.WithError(err).Error("error code: 1752")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if !allowed {
+			entry.Path = ""
+		}
+	}
+	if entry.Path == "" || isHiddenPath(entry.Path) {
+		httpResponse.Message = "unknown media " + name
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	if entry.IsFolder {
+		httpResponse.Message = "folder " + entry.Path + " can not be attached"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	private, err := service.IsPrivateMedia(tx, entry.Path)
+	if err != nil {
+		log.WithError(err).Error("error code: 1752")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if private {
+		httpResponse.Message = "private media " + entry.Path + " can not be attached"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// setPostMedia - link the post with the given cover image and
+// gallery, nil keeps the current ones
+//
+// An empty cover removes it, the same file is shown once in
+// the gallery
+func setPostMedia(tx *gorm.DB, post *model.Post, cover *model.PostAttachment, attachments []model.PostAttachment) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	internalError := func(err error) (model.HTTPResponse, int) {
+		log.WithError(err).Error("error code: 1753")
+		return model.HTTPResponse{Message: "internal server error"}, http.StatusInternalServerError
+	}
+
+	if cover != nil {
+		if err := tx.Where("post_id = ? AND position = 0", post.ID).Delete(&model.PostMedia{}).Error; err != nil {
+			return internalError(err)
+		}
+
+		if cover.MediaID != 0 || strings.TrimSpace(cover.Path) != "" {
+			entry, resp, code := postMediaEntry(tx, post.UserID, *cover)
+			if code != http.StatusOK {
+				return resp, code
+			}
+			if err := tx.Create(&model.PostMedia{PostID: post.ID, Position: 0, MediaID: entry.ID}).Error; err != nil {
+				return internalError(err)
+			}
+		}
+	}
+
+	if attachments != nil {
+		if len(attachments) > postMaxAttachments {
+			return model.HTTPResponse{Message: "a post must not have more than " + strconv.Itoa(postMaxAttachments) + " attachments"}, http.StatusBadRequest
+		}
+
+		if err := tx.Where("post_id = ? AND position > 0", post.ID).Delete(&model.PostMedia{}).Error; err != nil {
+			return internalError(err)
+		}
+
+		linked := []model.PostMedia{}
+		seen := map[uint]bool{}
+		for _, attachment := range attachments {
+			entry, resp, code := postMediaEntry(tx, post.UserID, attachment)
+			if code != http.StatusOK {
+				return resp, code
+			}
+			if seen[entry.ID] {
+				continue
+			}
+			seen[entry.ID] = true
+			linked = append(linked, model.PostMedia{PostID: post.ID, Position: len(linked) + 1, MediaID: entry.ID})
+		}
+		if len(linked) > 0 {
+			if err := tx.Create(&linked).Error; err != nil {
+				return internalError(err)
+			}
+		}
+	}
+
+	if err := withPostMedia(tx, []*model.Post{post}); err != nil {
+		return internalError(err)
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// withPostMedia - fill in the cover and the attachments of the
+// posts
+//
+// Files which have been deleted or made private since are left
+// out, restored files show up again
+func withPostMedia(db *gorm.DB, posts []*model.Post) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	postIDs := make([]uint, 0, len(posts))
+	for _, post := range posts {
+		postIDs = append(postIDs, post.ID)
+	}
+
+	links := []model.PostMedia{}
+	if err := db.Where("post_id IN ?", postIDs).Find(&links).Error; err != nil {
+		return err
+	}
+	if len(links) == 0 {
+		return nil
+	}
+	sort.Slice(links, func(i, j int) bool {
+		return links[i].Position < links[j].Position
+	})
+
+	mediaIDs := make([]uint, 0, len(links))
+	for _, link := range links {
+		mediaIDs = append(mediaIDs, link.MediaID)
+	}
+	entries := []model.Media{}
+	if err := db.Where("id IN ?", mediaIDs).Find(&entries).Error; err != nil {
+		return err
+	}
+
+	paths := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		paths = append(paths, entry.Path)
+	}
+	private, err := service.PrivateMedia(db, paths)
+	if err != nil {
+		return err
+	}
+
+	attachments := map[uint]model.PostAttachment{}
+	for _, entry := range entries {
+		if isHiddenPath(entry.Path) || private[entry.Path] {
+			continue
+		}
+		attachment := model.PostAttachment{
+			MediaID:  entry.ID,
+			Path:     entry.Path,
+			Name:     entry.Name,
+			MimeType: entry.MimeType,
+			Size:     entry.Size,
+			Width:    entry.Width,
+			Height:   entry.Height,
+			AltText:  entry.AltText,
+			URL:      service.DownloadURL(entry.Path),
+			Variants: entry.Variants,
+		}
+		service.SetVariantURLs(attachment.Path, attachment.Variants)
+		attachments[entry.ID] = attachment
+	}
+
+	byID := map[uint]*model.Post{}
+	for _, post := range posts {
+		post.Cover = nil
+		post.Attachments = nil
+		byID[post.ID] = post
+	}
+	for _, link := range links {
+		attachment, ok := attachments[link.MediaID]
+		post := byID[link.PostID]
+		if !ok || post == nil {
+			continue
+		}
+		if link.Position == 0 {
+			cover := attachment
+			post.Cover = &cover
+			continue
+		}
+		post.Attachments = append(post.Attachments, attachment)
+	}
+
+	return nil
+}
+
+// postMediaUsers - IDs of the posts which show the file or
+// anything inside the folder
+func postMediaUsers(db *gorm.DB, p string) ([]uint, error) {
+	postIDs := []uint{}
+
+	entries := []model.Media{}
+	if err := db.Select("id", "path").Where("path = ? OR path LIKE ?", p, p+"/%").Find(&entries).Error; err != nil {
+		return nil, err
+	}
+	mediaIDs := []uint{}
+	for _, entry := range entries {
+		// LIKE treats _ and % as wildcards
+		if entry.Path == p || strings.HasPrefix(entry.Path, p+"/") {
+			mediaIDs = append(mediaIDs, entry.ID)
+		}
+	}
+	if len(mediaIDs) == 0 {
+		return postIDs, nil
+	}
+
+	// deleted posts do not count
+	err := db.Model(&model.PostMedia{}).Distinct("post_id").
+		Where("media_id IN ?", mediaIDs).
+		Where("post_id IN (?)", db.Model(&model.Post{}).Select("id")).
+		Order("post_id").Pluck("post_id", &postIDs).Error
+
+	return postIDs, err
+}
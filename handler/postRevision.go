@@ -0,0 +1,195 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// recordPostRevision - save the current title and body of the
+// post as its next revision together with the HTML of the body
+//
+// The row of the post stays locked until tx ends, concurrent
+// changes of the post take the numbers one after another
+func recordPostRevision(tx *gorm.DB, post model.Post, userID uint, createdAt time.Time, restoredFrom *int) error {
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Select("id").
+		Where("id = ?", post.ID).Take(&model.Post{}).Error
+	if err != nil {
+		return err
+	}
+
+	var last int
+	err = tx.Model(&model.PostRevision{}).Where("post_id = ?", post.ID).
+		Select("COALESCE(MAX(number), 0)").Scan(&last).Error
+	if err != nil {
+		return err
+	}
+
+	revision := model.PostRevision{
+		CreatedAt:    createdAt,
+		PostID:       post.ID,
+		Number:       last + 1,
+		UserID:       userID,
+		Title:        post.Title,
+		Body:         post.Body,
+		RestoredFrom: restoredFrom,
+	}
+	if err := renderRevision(&revision); err != nil {
+		return err
+	}
+
+	return tx.Create(&revision).Error
+}
+
+// keepFirstPostRevision - posts written before revisions were
+// recorded get their current content as the first revision
+func keepFirstPostRevision(tx *gorm.DB, post model.Post) error {
+	var count int64
+	if err := tx.Model(&model.PostRevision{}).Where("post_id = ?", post.ID).Count(&count).Error; err != nil {
+		return err
+	}
+	if count > 0 {
+		return nil
+	}
+
+	return recordPostRevision(tx, post, post.UserID, post.UpdatedAt, nil)
+}
+
+// authorPost - post of the user, the revisions are only shown
+// to the author
+func authorPost(db *gorm.DB, userIDAuth uint, id string) (post model.Post, httpResponse model.HTTPResponse, httpStatusCode int) {
+	if err := db.Where("id = ?", id).Where("user_id = ?", userIDAuth).First(&post).Error; err != nil {
+		httpResponse.Message = "user may not have access to perform this task"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetPostRevisions handles jobs for controller.GetPostRevisions
+//
+// Newest revision first
+func GetPostRevisions(userIDAuth uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	post, httpResponse, httpStatusCode := authorPost(db, userIDAuth, id)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	revisions := []model.PostRevision{}
+	if err := db.Where("post_id = ?", post.ID).Order("number DESC").Find(&revisions).Error; err != nil {
+		log.WithError(err).Error("error code: 1261")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = revisions
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// postRevision - revision of a post by its number
+func postRevision(db *gorm.DB, postID uint, number string) (revision model.PostRevision, httpResponse model.HTTPResponse, httpStatusCode int) {
+	n, err := strconv.Atoi(number)
+	if err != nil || n < 1 {
+		httpResponse.Message = "invalid revision number"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	if err := db.Where("post_id = ? AND number = ?", postID, n).First(&revision).Error; err != nil {
+		httpResponse.Message = "revision #" + number + " not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// DiffPostRevisions handles jobs for controller.DiffPostRevisions
+//
+// Line by line changes of the title and body needed to turn
+// revision from into revision to
+func DiffPostRevisions(userIDAuth uint, id, from, to string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	post, httpResponse, httpStatusCode := authorPost(db, userIDAuth, id)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	oldRevision, httpResponse, httpStatusCode := postRevision(db, post.ID, from)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	newRevision, httpResponse, httpStatusCode := postRevision(db, post.ID, to)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	httpResponse.Message = model.PostRevisionDiff{
+		From:  oldRevision.Number,
+		To:    newRevision.Number,
+		Title: lib.DiffLines(oldRevision.Title, newRevision.Title),
+		Body:  lib.DiffLines(oldRevision.Body, newRevision.Body),
+	}
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// RestorePostRevision handles jobs for controller.RestorePostRevision
+//
+// The post gets the title and body of the old revision back,
+// which is recorded as a new revision
+func RestorePostRevision(userIDAuth uint, id, number string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	post, httpResponse, httpStatusCode := authorPost(db, userIDAuth, id)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	revision, httpResponse, httpStatusCode := postRevision(db, post.ID, number)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	post.Title = revision.Title
+	post.Body = revision.Body
+	post.UpdatedAt = time.Now()
+
+	tx := db.Begin()
+	if err := tx.Save(&post).Error; err != nil {
+		tx.Rollback()
+		log.WithError(err).Error("error code: 1262")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if err := recordPostRevision(tx, post, userIDAuth, post.UpdatedAt, &revision.Number); err != nil {
+		tx.Rollback()
+		log.WithError(err).Error("error code: 1263")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	tx.Commit()
+
+	httpResponse.Message = post
+	httpStatusCode = http.StatusOK
+	return
+}
@@ -0,0 +1,290 @@
+package handler
+
+import (
+	"html"
+	"net/http"
+	"strings"
+	"time"
+	"unicode"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// postSearchMaxTerms - words of a query which are searched for
+const postSearchMaxTerms int = 10
+
+// postSnippetWords - length of a snippet of the body
+const postSnippetWords int = 24
+
+// Markers of the matches in the text returned by the database,
+// private use characters which do not turn up in posts
+const (
+	markStart string = "\uE000"
+	markStop  string = "\uE001"
+)
+
+// postSearchRow - post found by the database
+type postSearchRow struct {
+	ID          uint
+	Title       string
+	Body        string
+	Slug        *string
+	UserID      uint
+	PublishAt   *time.Time
+	Score       float64
+	TitleMarked string
+	BodyMarked  string
+}
+
+// SearchPosts handles jobs for controller.SearchPosts
+//
+// Uses the full-text index of the database: FULLTEXT on
+// MySQL, tsvector on PostgreSQL and FTS5 on SQLite. Other
+// engines fall back to LIKE, newest post first.
+func SearchPosts(userID uint, search model.PostSearch) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	terms := searchTerms(search.Q)
+	if len(terms) == 0 {
+		httpResponse.Message = "q must contain a word to search for"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	if search.Limit < 0 || search.Offset < 0 {
+		httpResponse.Message = "limit and offset must not be negative"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	if search.Limit == 0 {
+		search.Limit = postListLimit
+	}
+	if search.Limit > postListMaxLimit {
+		search.Limit = postListMaxLimit
+	}
+
+	db := gdatabase.GetDB()
+	query := visiblePosts(db.Model(&model.Post{}), userID)
+	markInGo := false
+
+	switch db.Dialector.Name() {
+	case "mysql":
+		boolean := make([]string, len(terms))
+		for i, term := range terms {
+			boolean[i] = "+" + term + "*"
+		}
+		query = query.Where("MATCH(title, body) AGAINST(? IN BOOLEAN MODE)", strings.Join(boolean, " "))
+		markInGo = true
+
+	case "postgres":
+		prefixes := make([]string, len(terms))
+		for i, term := range terms {
+			prefixes[i] = term + ":*"
+		}
+		query = query.Where("search_vector @@ to_tsquery('simple', ?)", strings.Join(prefixes, " & "))
+
+	case "sqlite":
+		phrases := make([]string, len(terms))
+		for i, term := range terms {
+			phrases[i] = `"` + term + `"*`
+		}
+		query = query.Joins("JOIN "+model.PostSearchTable+" ON "+model.PostSearchTable+".rowid = posts.id").
+			Where(model.PostSearchTable+" MATCH ?", strings.Join(phrases, " AND "))
+
+	default:
+		for _, term := range terms {
+			like := "%" + escapeLike(term) + "%"
+			query = query.Where(`(LOWER(title) LIKE ? ESCAPE '\' OR LOWER(body) LIKE ? ESCAPE '\')`, like, like)
+		}
+		markInGo = true
+	}
+
+	page := model.PostSearchPage{Items: []model.PostSearchHit{}}
+	page.Meta.Limit = search.Limit
+	page.Meta.Offset = search.Offset
+
+	if err := query.Session(&gorm.Session{}).Count(&page.Meta.Total).Error; err != nil {
+		log.WithError(err).Error("error code: 1271")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	switch {
+	case db.Dialector.Name() == "mysql":
+		query = query.Select("id, title, body, slug, user_id, publish_at, MATCH(title, body) AGAINST(? IN NATURAL LANGUAGE MODE) AS score", strings.Join(terms, " ")).
+			Order("score DESC")
+
+	case db.Dialector.Name() == "postgres":
+		prefixes := make([]string, len(terms))
+		for i, term := range terms {
+			prefixes[i] = term + ":*"
+		}
+		tsQuery := strings.Join(prefixes, " & ")
+		options := `StartSel="` + markStart + `", StopSel="` + markStop + `"`
+		query = query.Select(`id, title, slug, user_id, publish_at,
+			ts_rank(search_vector, to_tsquery('simple', ?)) AS score,
+			ts_headline('simple', title, to_tsquery('simple', ?), ?) AS title_marked,
+			ts_headline('simple', body, to_tsquery('simple', ?), ?) AS body_marked`,
+			tsQuery, tsQuery, options+", HighlightAll=true", tsQuery, options+", MaxWords=35, MinWords=15").
+			Order("score DESC")
+
+	case !markInGo:
+		// bm25 is better the lower it is, matches in the title count more
+		query = query.Select(`posts.id, posts.title, posts.slug, posts.user_id, posts.publish_at,
+			-bm25(`+model.PostSearchTable+`, 10.0, 1.0) AS score,
+			highlight(`+model.PostSearchTable+`, 0, ?, ?) AS title_marked,
+			snippet(`+model.PostSearchTable+`, 1, ?, ?, '…', ?) AS body_marked`,
+			markStart, markStop, markStart, markStop, postSnippetWords).
+			Order("score DESC")
+
+	default:
+		query = query.Select("id, title, body, slug, user_id, publish_at").Order("created_at DESC")
+	}
+
+	rows := []postSearchRow{}
+	if err := query.Order("id DESC").Offset(search.Offset).Limit(search.Limit).Scan(&rows).Error; err != nil {
+		log.WithError(err).Error("error code: 1272")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	for _, row := range rows {
+		if markInGo {
+			row.TitleMarked = markTerms(row.Title, terms, 0)
+			row.BodyMarked = markTerms(row.Body, terms, postSnippetWords)
+		}
+		page.Items = append(page.Items, model.PostSearchHit{
+			ID:             row.ID,
+			Title:          row.Title,
+			Slug:           row.Slug,
+			UserID:         row.UserID,
+			PublishAt:      row.PublishAt,
+			Score:          row.Score,
+			TitleHighlight: markedHTML(row.TitleMarked),
+			Snippet:        markedHTML(row.BodyMarked),
+		})
+	}
+
+	httpResponse.Message = page
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// searchTerms - lower-cased words of a query, everything else
+// is dropped so that no engine sees its query syntax
+func searchTerms(q string) []string {
+	words := strings.FieldsFunc(strings.ToLower(q), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+
+	terms := []string{}
+	seen := map[string]bool{}
+	for _, word := range words {
+		if seen[word] {
+			continue
+		}
+		seen[word] = true
+		terms = append(terms, word)
+		if len(terms) == postSearchMaxTerms {
+			break
+		}
+	}
+
+	return terms
+}
+
+// escapeLike - escape the wildcards of a LIKE pattern
+func escapeLike(s string) string {
+	return strings.NewReplacer(`\`, `\\`, "%", `\%`, "_", `\_`).Replace(s)
+}
+
+// markTerms - mark the words starting with one of the terms,
+// a snippet of the given number of words around the first
+// match is cut out unless words is 0
+func markTerms(text string, terms []string, words int) string {
+	text = strings.NewReplacer(markStart, "", markStop, "").Replace(text)
+	fields := strings.Fields(text)
+
+	matches := func(field string) bool {
+		for _, word := range searchTerms(field) {
+			for _, term := range terms {
+				if strings.HasPrefix(word, term) {
+					return true
+				}
+			}
+		}
+		return false
+	}
+
+	start, end := 0, len(fields)
+	if words > 0 && len(fields) > words {
+		first := 0
+		for i, field := range fields {
+			if matches(field) {
+				first = i
+				break
+			}
+		}
+		start = first - words/4
+		if start < 0 {
+			start = 0
+		}
+		end = start + words
+		if end > len(fields) {
+			end, start = len(fields), len(fields)-words
+		}
+	}
+
+	marked := make([]string, 0, end-start)
+	for _, field := range fields[start:end] {
+		if matches(field) {
+			field = markStart + field + markStop
+		}
+		marked = append(marked, field)
+	}
+
+	snippet := strings.Join(marked, " ")
+	if start > 0 {
+		snippet = "…" + snippet
+	}
+	if end < len(fields) {
+		snippet += "…"
+	}
+
+	return snippet
+}
+
+// markedHTML - escape the text and turn the markers into
+// <mark> tags, stray markers are dropped
+func markedHTML(s string) string {
+	b := strings.Builder{}
+	open := false
+
+	for {
+		i := strings.IndexAny(s, markStart+markStop)
+		if i < 0 {
+			b.WriteString(html.EscapeString(s))
+			break
+		}
+		b.WriteString(html.EscapeString(s[:i]))
+
+		marker := s[i : i+len(markStart)]
+		if marker == markStart && !open {
+			b.WriteString("<mark>")
+			open = true
+		} else if marker == markStop && open {
+			b.WriteString("</mark>")
+			open = false
+		}
+		s = s[i+len(marker):]
+	}
+	if open {
+		b.WriteString("</mark>")
+	}
+
+	return b.String()
+}
@@ -0,0 +1,135 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// postStats - views and reactions of the post, MyReaction is
+// the reaction of the user
+func postStats(db *gorm.DB, postID, userID uint) (stats model.PostStats, err error) {
+	stats.Reactions = map[string]int64{}
+	for _, kind := range model.ReactionKinds {
+		stats.Reactions[kind] = 0
+	}
+
+	views := model.PostView{}
+	if err = db.Where("post_id = ?", postID).Limit(1).Find(&views).Error; err != nil {
+		return
+	}
+	buffered, err := service.BufferedPostViews(postID)
+	if err != nil {
+		return
+	}
+	stats.Views = views.Views + buffered
+
+	counts := []struct {
+		Kind  string
+		Total int64
+	}{}
+	err = db.Model(&model.PostReaction{}).Select("kind, COUNT(*) AS total").
+		Where("post_id = ?", postID).Group("kind").Scan(&counts).Error
+	if err != nil {
+		return
+	}
+	for _, count := range counts {
+		stats.Reactions[count.Kind] = count.Total
+	}
+
+	if userID != 0 {
+		mine := model.PostReaction{}
+		if err = db.Where("post_id = ? AND user_id = ?", postID, userID).Limit(1).Find(&mine).Error; err != nil {
+			return
+		}
+		stats.MyReaction = mine.Kind
+	}
+
+	return
+}
+
+// SetPostReaction handles jobs for controller.SetPostReaction
+//
+// A user has one reaction per post, a new one replaces it
+func SetPostReaction(userIDAuth uint, id string, req model.PostReactionRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	kind := strings.ToLower(strings.TrimSpace(req.Kind))
+	if !model.IsReactionKind(kind) {
+		httpResponse.Message = "kind must be one of " + strings.Join(model.ReactionKinds, ", ")
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	post := model.Post{}
+	if err := visiblePosts(db, userIDAuth).Where("id = ?", id).First(&post).Error; err != nil {
+		httpResponse.Message = "article not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	reaction := model.PostReaction{PostID: post.ID, UserID: userIDAuth, Kind: kind}
+	err := db.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "post_id"}, {Name: "user_id"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{"kind": kind, "updated_at": time.Now()}),
+	}).Create(&reaction).Error
+	if err != nil {
+		log.WithError(err).Error("error code: 1811")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	stats, err := postStats(db, post.ID, userIDAuth)
+	if err != nil {
+		log.WithError(err).Error("error code: 1812")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = stats
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// DeletePostReaction handles jobs for controller.DeletePostReaction
+func DeletePostReaction(userIDAuth uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	post := model.Post{}
+	if err := visiblePosts(db, userIDAuth).Where("id = ?", id).First(&post).Error; err != nil {
+		httpResponse.Message = "article not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	err := db.Where("post_id = ? AND user_id = ?", post.ID, userIDAuth).Delete(&model.PostReaction{}).Error
+	if err != nil {
+		log.WithError(err).Error("error code: 1821")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	stats, err := postStats(db, post.ID, userIDAuth)
+	if err != nil {
+		log.WithError(err).Error("error code: 1822")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = stats
+	httpStatusCode = http.StatusOK
+	return
+}
@@ -0,0 +1,162 @@
+package handler
+
+import (
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// sitemapRow - columns of a public page in the sitemap
+type sitemapRow struct {
+	ID        uint
+	Slug      *string
+	UpdatedAt time.Time
+}
+
+// sitemapSource - public pages of one kind
+type sitemapSource struct {
+	// rows - query of the public rows
+	rows func(db *gorm.DB) *gorm.DB
+	// loc - path of the page of a row
+	loc func(row sitemapRow) string
+}
+
+// sitemapKinds - sitemaps in the order of the index
+var sitemapKinds = []string{"posts", "users", "shops", "categories"}
+
+var sitemapSources = map[string]sitemapSource{
+	"posts": {
+		rows: func(db *gorm.DB) *gorm.DB {
+			return visiblePosts(db.Model(&model.Post{}), 0).Select("id", "slug", "updated_at")
+		},
+		loc: func(row sitemapRow) string {
+			if row.Slug != nil {
+				return "/api/v1/posts/" + *row.Slug
+			}
+			return "/api/v1/posts/" + strconv.FormatUint(uint64(row.ID), 10)
+		},
+	},
+	"users": {
+		rows: func(db *gorm.DB) *gorm.DB {
+			return db.Model(&model.User{}).Select("id", "updated_at").Where("is_shop = ? AND is_deleted = ?", false, false)
+		},
+		loc: func(row sitemapRow) string {
+			return "/api/v1/users/" + strconv.FormatUint(uint64(row.ID), 10)
+		},
+	},
+	"shops": {
+		rows: func(db *gorm.DB) *gorm.DB {
+			return db.Model(&model.User{}).Select("id", "updated_at").Where("is_shop = ? AND is_deleted = ?", true, false)
+		},
+		loc: func(row sitemapRow) string {
+			return "/api/v1/users/" + strconv.FormatUint(uint64(row.ID), 10)
+		},
+	},
+	"categories": {
+		rows: func(db *gorm.DB) *gorm.DB {
+			return db.Model(&model.Category{}).Select("id", "slug", "updated_at")
+		},
+		loc: func(row sitemapRow) string {
+			return "/api/v1/posts?category=" + url.QueryEscape(*row.Slug)
+		},
+	},
+}
+
+// sitemapChunk - rows of the n-th sitemap of the kind,
+// counting from 1
+func sitemapChunk(db *gorm.DB, source sitemapSource, n int) *gorm.DB {
+	return source.rows(db).Order("id").Offset((n - 1) * lib.SitemapMaxURLs).Limit(lib.SitemapMaxURLs)
+}
+
+// GetSitemapIndex handles jobs for controller.GetSitemapIndex
+//
+// One sitemap per SitemapMaxURLs pages of each kind, lastmod
+// is the latest change of the pages in it. baseURL is the
+// configured public URL of the API, never the Host header of
+// the request.
+func GetSitemapIndex(baseURL string) (sitemaps []lib.SitemapURL, httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+	sitemaps = []lib.SitemapURL{}
+
+	for _, kind := range sitemapKinds {
+		source := sitemapSources[kind]
+
+		var count int64
+		if err := source.rows(db).Count(&count).Error; err != nil {
+			log.WithError(err).Error("error code: 1721")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+
+		for n := 1; int64(n-1)*int64(lib.SitemapMaxURLs) < count; n++ {
+			latest := sitemapRow{}
+			err := db.Table("(?) AS chunk", sitemapChunk(db, source, n)).Select("updated_at").
+				Order("updated_at DESC").Limit(1).Scan(&latest).Error
+			if err != nil {
+				log.WithError(err).Error("error code: 1722")
+				httpResponse.Message = "internal server error"
+				httpStatusCode = http.StatusInternalServerError
+				return
+			}
+
+			sitemaps = append(sitemaps, lib.SitemapURL{
+				Loc:     baseURL + "/sitemaps/" + kind + "-" + strconv.Itoa(n) + ".xml",
+				LastMod: latest.UpdatedAt,
+			})
+		}
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetSitemap handles jobs for controller.GetSitemap
+//
+// name is one of the sitemaps of the index, i.e. posts-1.xml
+func GetSitemap(baseURL, name string) (urls []lib.SitemapURL, httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	kind, number, _ := strings.Cut(strings.TrimSuffix(name, ".xml"), "-")
+	source, ok := sitemapSources[kind]
+	n, err := strconv.Atoi(number)
+	if !ok || err != nil || n < 1 || !strings.HasSuffix(name, ".xml") {
+		httpResponse.Message = "sitemap not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	rows := []sitemapRow{}
+	if err := sitemapChunk(db, source, n).Scan(&rows).Error; err != nil {
+		log.WithError(err).Error("error code: 1731")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if len(rows) == 0 {
+		httpResponse.Message = "sitemap not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	urls = make([]lib.SitemapURL, 0, len(rows))
+	for _, row := range rows {
+		urls = append(urls, lib.SitemapURL{
+			Loc:     baseURL + source.loc(row),
+			LastMod: row.UpdatedAt,
+		})
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
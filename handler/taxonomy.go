@@ -0,0 +1,591 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	gdatabase "github.com/tinkerbaj/gintemp/database"
+
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+	"github.com/tinkerbaj/gintemp/service"
+)
+
+// postMaxTags - most tags a post may have
+const postMaxTags int = 20
+
+// tagCloudLimit - tags in the tag cloud unless asked otherwise
+const tagCloudLimit int = 50
+
+// tagCloudMaxLimit - most tags a client may ask for
+const tagCloudMaxLimit int = 500
+
+// checkAdmin - only admins may change the taxonomy
+func checkAdmin(userID uint) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	admin, err := service.IsAdmin(userID)
+	if err != nil {
+		log.WithError(err).Error("error code: 1600")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+	if !admin {
+		httpResponse.Message = "user may not have access to perform this task"
+		httpStatusCode = http.StatusForbidden
+		return
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// taxonomyName - trimmed name of a category or tag
+func taxonomyName(name string, maxLength int) (string, model.HTTPResponse, int) {
+	name = strings.TrimSpace(name)
+	if name == "" {
+		return "", model.HTTPResponse{Message: "name must not be empty"}, http.StatusBadRequest
+	}
+	if utf8.RuneCountInString(name) > maxLength {
+		return "", model.HTTPResponse{Message: "name must not be longer than " + strconv.Itoa(maxLength) + " characters"}, http.StatusBadRequest
+	}
+
+	return name, model.HTTPResponse{}, http.StatusOK
+}
+
+// taxonomySlug - requested slug or one made of the name, unique
+// within the table of the entry
+//
+// A generated slug gets a number when it is taken already,
+// a requested one must be free.
+func taxonomySlug(db *gorm.DB, entry interface{}, id uint, name string, req *string) (slug string, httpResponse model.HTTPResponse, httpStatusCode int) {
+	taken := func(slug string) (bool, error) {
+		var count int64
+		err := db.Model(entry).Where("slug = ? AND id <> ?", slug, id).Count(&count).Error
+		return count > 0, err
+	}
+
+	base := lib.Slugify(name)
+	if req != nil {
+		base = lib.Slugify(*req)
+		if base == "" {
+			httpResponse.Message = "invalid slug"
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+	}
+	if base == "" {
+		httpResponse.Message = "name must contain a letter or digit"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+
+	slug = base
+	for i := 2; ; i++ {
+		exists, err := taken(slug)
+		if err != nil {
+			log.WithError(err).Error("error code: 1601")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		if !exists {
+			break
+		}
+		if req != nil {
+			httpResponse.Message = "slug already in use"
+			httpStatusCode = http.StatusConflict
+			return
+		}
+		slug = base + "-" + strconv.Itoa(i)
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetCategories handles jobs for controller.GetCategories
+//
+// All categories as a tree with the number of published posts
+func GetCategories() (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	categories := []model.Category{}
+	if err := db.Order("name").Order("id").Find(&categories).Error; err != nil {
+		log.WithError(err).Error("error code: 1602")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	counts := []struct {
+		CategoryID uint
+		PostCount  int64
+	}{}
+	err := db.Table("post_categories").
+		Select("post_categories.category_id, COUNT(*) AS post_count").
+		Joins("JOIN posts ON posts.id = post_categories.post_id").
+		Where("posts.status = ? AND posts.deleted_at IS NULL", model.PostPublished).
+		Group("post_categories.category_id").
+		Scan(&counts).Error
+	if err != nil {
+		log.WithError(err).Error("error code: 1603")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	postCount := map[uint]int64{}
+	for _, count := range counts {
+		postCount[count.CategoryID] = count.PostCount
+	}
+
+	children := map[uint][]int{}
+	roots := []int{}
+	for i, category := range categories {
+		if category.ParentID == nil {
+			roots = append(roots, i)
+			continue
+		}
+		children[*category.ParentID] = append(children[*category.ParentID], i)
+	}
+
+	var build func(i int) model.Category
+	build = func(i int) model.Category {
+		category := categories[i]
+		count := postCount[category.ID]
+		category.PostCount = &count
+		for _, child := range children[category.ID] {
+			category.Children = append(category.Children, build(child))
+		}
+		return category
+	}
+
+	tree := []model.Category{}
+	for _, i := range roots {
+		tree = append(tree, build(i))
+	}
+
+	httpResponse.Message = tree
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// categorySubtree - IDs of the category with the given slug and
+// of all categories below it, none for an unknown slug
+func categorySubtree(db *gorm.DB, slug string) ([]uint, error) {
+	categories := []model.Category{}
+	if err := db.Select("id", "parent_id", "slug").Find(&categories).Error; err != nil {
+		return nil, err
+	}
+
+	children := map[uint][]uint{}
+	var root uint
+	for _, category := range categories {
+		if category.Slug == slug {
+			root = category.ID
+		}
+		if category.ParentID != nil {
+			children[*category.ParentID] = append(children[*category.ParentID], category.ID)
+		}
+	}
+	if root == 0 {
+		return nil, nil
+	}
+
+	ids := []uint{root}
+	for i := 0; i < len(ids); i++ {
+		ids = append(ids, children[ids[i]]...)
+	}
+
+	return ids, nil
+}
+
+// checkCategoryParent - the parent must exist and must not be
+// the category itself or one below it
+func checkCategoryParent(db *gorm.DB, id uint, parentID *uint) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	for next := parentID; next != nil; {
+		if *next == id {
+			httpResponse.Message = "category cannot be moved below itself"
+			httpStatusCode = http.StatusBadRequest
+			return
+		}
+
+		parent := model.Category{}
+		if err := db.Select("id", "parent_id").Where("id = ?", *next).First(&parent).Error; err != nil {
+			if errors.Is(err, gorm.ErrRecordNotFound) {
+				httpResponse.Message = "parent category not found"
+				httpStatusCode = http.StatusBadRequest
+				return
+			}
+			log.WithError(err).Error("error code: 1604")
+			httpResponse.Message = "internal server error"
+			httpStatusCode = http.StatusInternalServerError
+			return
+		}
+		next = parent.ParentID
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// CreateCategory handles jobs for controller.CreateCategory
+func CreateCategory(userIDAuth uint, req model.CategoryRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	httpResponse, httpStatusCode = checkAdmin(userIDAuth)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	category := model.Category{ParentID: req.ParentID, Description: strings.TrimSpace(req.Description)}
+
+	category.Name, httpResponse, httpStatusCode = taxonomyName(req.Name, 100)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	httpResponse, httpStatusCode = checkCategoryParent(db, 0, req.ParentID)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	category.Slug, httpResponse, httpStatusCode = taxonomySlug(db, &model.Category{}, 0, category.Name, req.Slug)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if err := db.Create(&category).Error; err != nil {
+		log.WithError(err).Error("error code: 1611")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = category
+	httpStatusCode = http.StatusCreated
+	return
+}
+
+// UpdateCategory handles jobs for controller.UpdateCategory
+//
+// The slug is kept unless another one is given
+func UpdateCategory(userIDAuth uint, id string, req model.CategoryRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	httpResponse, httpStatusCode = checkAdmin(userIDAuth)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	category := model.Category{}
+	if err := db.Where("id = ?", id).First(&category).Error; err != nil {
+		httpResponse.Message = "category not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	category.Name, httpResponse, httpStatusCode = taxonomyName(req.Name, 100)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	httpResponse, httpStatusCode = checkCategoryParent(db, category.ID, req.ParentID)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	if req.Slug != nil {
+		category.Slug, httpResponse, httpStatusCode = taxonomySlug(db, &model.Category{}, category.ID, category.Name, req.Slug)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+	category.ParentID = req.ParentID
+	category.Description = strings.TrimSpace(req.Description)
+
+	if err := db.Save(&category).Error; err != nil {
+		log.WithError(err).Error("error code: 1621")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = category
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// DeleteCategory handles jobs for controller.DeleteCategory
+//
+// Sub-categories move up to the parent, the posts stay
+func DeleteCategory(userIDAuth uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	httpResponse, httpStatusCode = checkAdmin(userIDAuth)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	category := model.Category{}
+	if err := db.Where("id = ?", id).First(&category).Error; err != nil {
+		httpResponse.Message = "category not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&model.Category{}).Where("parent_id = ?", category.ID).Update("parent_id", category.ParentID).Error; err != nil {
+			return err
+		}
+		if err := tx.Exec("DELETE FROM post_categories WHERE category_id = ?", category.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&category).Error
+	})
+	if err != nil {
+		log.WithError(err).Error("error code: 1631")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = "category ID# " + id + " deleted!"
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// GetTags handles jobs for controller.GetTags
+//
+// Tag cloud: the tags of published posts, most used first
+func GetTags(limit int) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	if limit < 0 {
+		httpResponse.Message = "limit must not be negative"
+		httpStatusCode = http.StatusBadRequest
+		return
+	}
+	if limit == 0 {
+		limit = tagCloudLimit
+	}
+	if limit > tagCloudMaxLimit {
+		limit = tagCloudMaxLimit
+	}
+
+	tags := []model.TagCount{}
+	err := gdatabase.GetDB().Table("tags").
+		Select("tags.id, tags.name, tags.slug, COUNT(posts.id) AS post_count").
+		Joins("JOIN post_tags ON post_tags.tag_id = tags.id").
+		Joins("JOIN posts ON posts.id = post_tags.post_id").
+		Where("posts.status = ? AND posts.deleted_at IS NULL", model.PostPublished).
+		Group("tags.id, tags.name, tags.slug").
+		Order("post_count DESC").Order("tags.name").
+		Limit(limit).
+		Scan(&tags).Error
+	if err != nil {
+		log.WithError(err).Error("error code: 1641")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = tags
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// CreateTag handles jobs for controller.CreateTag
+func CreateTag(userIDAuth uint, req model.TagRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	httpResponse, httpStatusCode = checkAdmin(userIDAuth)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	tag := model.Tag{}
+	tag.Name, httpResponse, httpStatusCode = taxonomyName(req.Name, 64)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	tag.Slug, httpResponse, httpStatusCode = taxonomySlug(db, &model.Tag{}, 0, tag.Name, req.Slug)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	if err := db.Create(&tag).Error; err != nil {
+		log.WithError(err).Error("error code: 1651")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = model.TagCount{ID: tag.ID, Name: tag.Name, Slug: tag.Slug}
+	httpStatusCode = http.StatusCreated
+	return
+}
+
+// UpdateTag handles jobs for controller.UpdateTag
+//
+// The slug is kept unless another one is given
+func UpdateTag(userIDAuth uint, id string, req model.TagRequest) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	httpResponse, httpStatusCode = checkAdmin(userIDAuth)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	tag := model.Tag{}
+	if err := db.Where("id = ?", id).First(&tag).Error; err != nil {
+		httpResponse.Message = "tag not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	tag.Name, httpResponse, httpStatusCode = taxonomyName(req.Name, 64)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+	if req.Slug != nil {
+		tag.Slug, httpResponse, httpStatusCode = taxonomySlug(db, &model.Tag{}, tag.ID, tag.Name, req.Slug)
+		if httpStatusCode != http.StatusOK {
+			return
+		}
+	}
+
+	if err := db.Save(&tag).Error; err != nil {
+		log.WithError(err).Error("error code: 1661")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = model.TagCount{ID: tag.ID, Name: tag.Name, Slug: tag.Slug}
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// DeleteTag handles jobs for controller.DeleteTag
+//
+// The tag is removed from all posts
+func DeleteTag(userIDAuth uint, id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	db := gdatabase.GetDB()
+
+	httpResponse, httpStatusCode = checkAdmin(userIDAuth)
+	if httpStatusCode != http.StatusOK {
+		return
+	}
+
+	tag := model.Tag{}
+	if err := db.Where("id = ?", id).First(&tag).Error; err != nil {
+		httpResponse.Message = "tag not found"
+		httpStatusCode = http.StatusNotFound
+		return
+	}
+
+	err := db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Exec("DELETE FROM post_tags WHERE tag_id = ?", tag.ID).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		log.WithError(err).Error("error code: 1671")
+		httpResponse.Message = "internal server error"
+		httpStatusCode = http.StatusInternalServerError
+		return
+	}
+
+	httpResponse.Message = "tag ID# " + id + " deleted!"
+	httpStatusCode = http.StatusOK
+	return
+}
+
+// setPostTaxonomy - link the post with the given categories and
+// tags, nil keeps the current ones
+//
+// Categories are found by ID or slug and must exist, tags by
+// name and are created when missing
+func setPostTaxonomy(tx *gorm.DB, post *model.Post, categories []model.Category, tags []model.Tag) (httpResponse model.HTTPResponse, httpStatusCode int) {
+	internalError := func(err error) (model.HTTPResponse, int) {
+		log.WithError(err).Error("error code: 1681")
+		return model.HTTPResponse{Message: "internal server error"}, http.StatusInternalServerError
+	}
+
+	if categories == nil {
+		if err := tx.Model(post).Association("Categories").Find(&post.Categories); err != nil {
+			return internalError(err)
+		}
+	} else {
+		linked := []model.Category{}
+		seen := map[uint]bool{}
+		for _, category := range categories {
+			query := tx.Where("slug = ?", strings.TrimSpace(category.Slug))
+			name := category.Slug
+			if category.ID != 0 {
+				query = tx.Where("id = ?", category.ID)
+				name = strconv.FormatUint(uint64(category.ID), 10)
+			}
+
+			found := model.Category{}
+			if err := query.First(&found).Error; err != nil {
+				if errors.Is(err, gorm.ErrRecordNotFound) {
+					return model.HTTPResponse{Message: "unknown category " + name}, http.StatusBadRequest
+				}
+				return internalError(err)
+			}
+			if !seen[found.ID] {
+				seen[found.ID] = true
+				linked = append(linked, found)
+			}
+		}
+
+		if err := tx.Model(post).Association("Categories").Replace(linked); err != nil {
+			return internalError(err)
+		}
+		post.Categories = linked
+	}
+
+	if tags == nil {
+		if err := tx.Model(post).Association("Tags").Find(&post.Tags); err != nil {
+			return internalError(err)
+		}
+	} else {
+		linked := []model.Tag{}
+		seen := map[string]bool{}
+		for _, tag := range tags {
+			name, resp, code := taxonomyName(tag.Name, 64)
+			if code != http.StatusOK {
+				resp.Message = "tag " + resp.Message.(string)
+				return resp, code
+			}
+			slug := lib.Slugify(name)
+			if slug == "" {
+				return model.HTTPResponse{Message: "tag must contain a letter or digit"}, http.StatusBadRequest
+			}
+			if seen[slug] {
+				continue
+			}
+			seen[slug] = true
+
+			found := model.Tag{}
+			if err := tx.Where(model.Tag{Slug: slug}).Attrs(model.Tag{Name: name}).FirstOrCreate(&found).Error; err != nil {
+				return internalError(err)
+			}
+			linked = append(linked, found)
+		}
+		if len(linked) > postMaxTags {
+			return model.HTTPResponse{Message: "a post must not have more than " + strconv.Itoa(postMaxTags) + " tags"}, http.StatusBadRequest
+		}
+
+		if err := tx.Model(post).Association("Tags").Replace(linked); err != nil {
+			return internalError(err)
+		}
+		post.Tags = linked
+	}
+
+	httpStatusCode = http.StatusOK
+	return
+}
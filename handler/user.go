@@ -45,7 +45,7 @@ func GetUsers() (httpResponse model.HTTPResponse, httpStatusCode int) {
 	}
 
 	for j, user := range users {
-		db.Model(&posts).Where("id_user = ?", user.ID).Find(&posts)
+		visiblePosts(db.Model(&posts), 0).Where("user_id = ?", user.ID).Find(&posts)
 		users[j].Posts = posts
 
 		db.Model(&hobbies).Joins("JOIN user_hobbies ON user_hobbies.hobby_hobby_id=hobbies.hobby_id").
@@ -73,7 +73,7 @@ func GetUser(id string) (httpResponse model.HTTPResponse, httpStatusCode int) {
 		return
 	}
 
-	db.Model(&posts).Where("id_user = ?", user.ID).Find(&posts)
+	visiblePosts(db.Model(&posts), 0).Where("user_id = ?", user.ID).Find(&posts)
 	user.Posts = posts
 
 	db.Model(&hobbies).Joins("JOIN user_hobbies ON user_hobbies.hobby_hobby_id=hobbies.hobby_id").
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+)
+
+// ErrInvalidCursor - the cursor was not issued by EncodeCursor
+var ErrInvalidCursor = errors.New("invalid cursor")
+
+// EncodeCursor - opaque, URL-safe cursor holding the
+// position of the last item of a page
+func EncodeCursor(v interface{}) (string, error) {
+	b, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// DecodeCursor - read a cursor created by EncodeCursor into v
+func DecodeCursor(cursor string, v interface{}) error {
+	b, err := base64.RawURLEncoding.DecodeString(cursor)
+	if err != nil {
+		return ErrInvalidCursor
+	}
+	if err := json.Unmarshal(b, v); err != nil {
+		return ErrInvalidCursor
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package lib_test
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestCursor(t *testing.T) {
+	type position struct {
+		Name string
+		ID   uint
+	}
+
+	in := position{Name: "jar & comb.jpg", ID: 42}
+	cursor, err := lib.EncodeCursor(in)
+	if err != nil {
+		t.Fatalf("EncodeCursor failed: %v", err)
+	}
+
+	out := position{}
+	if err := lib.DecodeCursor(cursor, &out); err != nil {
+		t.Fatalf("DecodeCursor failed: %v", err)
+	}
+	if out != in {
+		t.Errorf("got %+v, want %+v", out, in)
+	}
+
+	for _, invalid := range []string{"%%%", "bm90IGpzb24"} {
+		if err := lib.DecodeCursor(invalid, &out); !errors.Is(err, lib.ErrInvalidCursor) {
+			t.Errorf("DecodeCursor(%q) = %v, want ErrInvalidCursor", invalid, err)
+		}
+	}
+}
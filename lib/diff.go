@@ -0,0 +1,121 @@
+package lib
+
+import "strings"
+
+// Diff operations
+const (
+	DiffEqual  string = "="
+	DiffInsert string = "+"
+	DiffDelete string = "-"
+)
+
+// DiffLine - one line of a diff
+type DiffLine struct {
+	Op   string `json:"op"` // =, + or -
+	Text string `json:"text"`
+}
+
+// maxDiffEdits - texts further apart than this many changed
+// lines are diffed as a whole, which bounds the memory use
+const maxDiffEdits int = 2000
+
+// DiffLines - shortest line-by-line edit script turning a into
+// b (Myers' algorithm), deletions come before insertions
+func DiffLines(a, b string) []DiffLine {
+	x, y := splitLines(a), splitLines(b)
+	n, m := len(x), len(y)
+	max := n + m
+	offset := max + 1
+
+	// v[offset+k] - furthest line of a reached on diagonal k,
+	// the part of v used by each step is kept for the way back
+	v := make([]int, 2*max+3)
+	trace := [][]int{}
+
+	d := 0
+search:
+	for ; d <= max; d++ {
+		if d > maxDiffEdits {
+			return replaceLines(x, y)
+		}
+		trace = append(trace, append([]int(nil), v[offset-d-1:offset+d+2]...))
+		for k := -d; k <= d; k += 2 {
+			var i int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				i = v[offset+k+1] // down: insertion
+			} else {
+				i = v[offset+k-1] + 1 // right: deletion
+			}
+			j := i - k
+			for i < n && j < m && x[i] == y[j] {
+				i++
+				j++
+			}
+			v[offset+k] = i
+			if i >= n && j >= m {
+				break search
+			}
+		}
+	}
+
+	// walk back from the end to the start
+	lines := []DiffLine{}
+	i, j := n, m
+	for ; d > 0; d-- {
+		at := func(k int) int { return trace[d][k+d+1] }
+		k := i - j
+
+		prevK := k - 1
+		if k == -d || (k != d && at(k-1) < at(k+1)) {
+			prevK = k + 1
+		}
+		prevI := at(prevK)
+		prevJ := prevI - prevK
+
+		for i > prevI && j > prevJ {
+			i--
+			j--
+			lines = append(lines, DiffLine{Op: DiffEqual, Text: x[i]})
+		}
+		if i == prevI {
+			j--
+			lines = append(lines, DiffLine{Op: DiffInsert, Text: y[j]})
+		} else {
+			i--
+			lines = append(lines, DiffLine{Op: DiffDelete, Text: x[i]})
+		}
+	}
+	for i > 0 {
+		i--
+		lines = append(lines, DiffLine{Op: DiffEqual, Text: x[i]})
+	}
+
+	for l, r := 0, len(lines)-1; l < r; l, r = l+1, r-1 {
+		lines[l], lines[r] = lines[r], lines[l]
+	}
+
+	return lines
+}
+
+// replaceLines - edit script removing every line of x and
+// adding every line of y
+func replaceLines(x, y []string) []DiffLine {
+	lines := make([]DiffLine, 0, len(x)+len(y))
+	for _, line := range x {
+		lines = append(lines, DiffLine{Op: DiffDelete, Text: line})
+	}
+	for _, line := range y {
+		lines = append(lines, DiffLine{Op: DiffInsert, Text: line})
+	}
+
+	return lines
+}
+
+// splitLines - lines of a text, an empty text has none
+func splitLines(s string) []string {
+	if s == "" {
+		return nil
+	}
+
+	return strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+}
@@ -0,0 +1,71 @@
+package lib_test
+
+import (
+	"math/rand"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestDiffLines(t *testing.T) {
+	testCases := []struct {
+		a, b string
+		want []lib.DiffLine
+	}{
+		{"", "", []lib.DiffLine{}},
+		{"a", "a", []lib.DiffLine{{Op: "=", Text: "a"}}},
+		{"", "a\nb", []lib.DiffLine{{Op: "+", Text: "a"}, {Op: "+", Text: "b"}}},
+		{"a\nb", "", []lib.DiffLine{{Op: "-", Text: "a"}, {Op: "-", Text: "b"}}},
+		{
+			"a\nb\nc", "a\nx\nc",
+			[]lib.DiffLine{{Op: "=", Text: "a"}, {Op: "-", Text: "b"}, {Op: "+", Text: "x"}, {Op: "=", Text: "c"}},
+		},
+		{
+			"a\r\nb", "b\nc",
+			[]lib.DiffLine{{Op: "-", Text: "a"}, {Op: "=", Text: "b"}, {Op: "+", Text: "c"}},
+		},
+	}
+
+	for _, tc := range testCases {
+		got := lib.DiffLines(tc.a, tc.b)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("lib.DiffLines(%q, %q) = %v, want %v", tc.a, tc.b, got, tc.want)
+		}
+	}
+}
+
+func TestDiffLinesApply(t *testing.T) {
+	// applying the edit script to a must give b
+	words := []string{"a", "b", "c", "d"}
+	random := rand.New(rand.NewSource(1))
+	text := func(n int) string {
+		lines := make([]string, n)
+		for i := range lines {
+			lines[i] = words[random.Intn(len(words))]
+		}
+		return strings.Join(lines, "\n")
+	}
+
+	for i := 0; i < 200; i++ {
+		a, b := text(random.Intn(30)), text(random.Intn(30))
+
+		oldLines, newLines := []string{}, []string{}
+		for _, line := range lib.DiffLines(a, b) {
+			if line.Op != lib.DiffInsert {
+				oldLines = append(oldLines, line.Text)
+			}
+			if line.Op != lib.DiffDelete {
+				newLines = append(newLines, line.Text)
+			}
+		}
+
+		if got := strings.Join(oldLines, "\n"); got != a {
+			t.Fatalf("old side %q, want %q", got, a)
+		}
+		if got := strings.Join(newLines, "\n"); got != b {
+			t.Fatalf("new side %q, want %q", got, b)
+		}
+	}
+}
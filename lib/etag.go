@@ -0,0 +1,17 @@
+package lib
+
+import (
+	"strconv"
+	"time"
+)
+
+// ETag - entity tag of a stored file, strong when the content
+// checksum is known, otherwise weak and derived from the size
+// and the modification time
+func ETag(checksum string, size int64, modTime time.Time) string {
+	if checksum != "" {
+		return `"` + checksum + `"`
+	}
+
+	return `W/"` + strconv.FormatInt(size, 16) + "-" + strconv.FormatInt(modTime.UnixNano(), 16) + `"`
+}
@@ -0,0 +1,28 @@
+package lib_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestETag(t *testing.T) {
+	modTime := time.Unix(0, 255)
+
+	testCases := []struct {
+		checksum string
+		size     int64
+		want     string
+	}{
+		{"abc123", 10, `"abc123"`},
+		{"", 16, `W/"10-ff"`},
+	}
+
+	for _, tc := range testCases {
+		got := lib.ETag(tc.checksum, tc.size, modTime)
+		if got != tc.want {
+			t.Errorf("lib.ETag(%q, %d) = %s, want %s", tc.checksum, tc.size, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,286 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"time"
+)
+
+// Feed formats
+const (
+	FeedRSS  string = "rss"  // RSS 2.0
+	FeedAtom string = "atom" // Atom 1.0
+	FeedJSON string = "json" // JSON Feed 1.1
+)
+
+// Feed - channel of entries which can be written as RSS, Atom
+// or JSON Feed
+type Feed struct {
+	Title       string
+	Description string
+	HomeURL     string // site the feed belongs to
+	FeedURL     string // URL the feed is fetched from
+	Updated     time.Time
+	Items       []FeedItem // newest first
+}
+
+// FeedItem - one entry of a feed
+type FeedItem struct {
+	ID          string // permanent, unique
+	URL         string
+	Title       string
+	ContentHTML string
+	Published   time.Time
+	Updated     time.Time
+	Author      FeedAuthor
+	Tags        []string
+}
+
+// FeedAuthor - writer of an entry, no email address is
+// published
+type FeedAuthor struct {
+	Name string
+	URL  string
+}
+
+// IsFeedFormat returns true for the known feed formats
+func IsFeedFormat(format string) bool {
+	switch format {
+	case FeedRSS, FeedAtom, FeedJSON:
+		return true
+	}
+	return false
+}
+
+// FeedContentType - media type of the feed format
+func FeedContentType(format string) string {
+	switch format {
+	case FeedRSS:
+		return "application/rss+xml; charset=utf-8"
+	case FeedAtom:
+		return "application/atom+xml; charset=utf-8"
+	}
+	return "application/feed+json; charset=utf-8"
+}
+
+// Encode - the feed in the given format
+func (f Feed) Encode(format string) ([]byte, error) {
+	switch format {
+	case FeedRSS:
+		return f.RSS()
+	case FeedAtom:
+		return f.Atom()
+	}
+	return f.JSON()
+}
+
+type rssFeed struct {
+	XMLName xml.Name   `xml:"rss"`
+	Version string     `xml:"version,attr"`
+	XMLNSDC string     `xml:"xmlns:dc,attr"`
+	XMLNSA  string     `xml:"xmlns:atom,attr"`
+	Channel rssChannel `xml:"channel"`
+}
+
+type rssChannel struct {
+	Title         string    `xml:"title"`
+	Link          string    `xml:"link"`
+	Description   string    `xml:"description"`
+	Self          atomLink  `xml:"atom:link"`
+	LastBuildDate string    `xml:"lastBuildDate,omitempty"`
+	Items         []rssItem `xml:"item"`
+}
+
+type rssItem struct {
+	Title       string   `xml:"title"`
+	Link        string   `xml:"link"`
+	GUID        rssGUID  `xml:"guid"`
+	PubDate     string   `xml:"pubDate"`
+	Creator     string   `xml:"dc:creator,omitempty"`
+	Categories  []string `xml:"category"`
+	Description string   `xml:"description"`
+}
+
+type rssGUID struct {
+	IsPermaLink bool   `xml:"isPermaLink,attr"`
+	Value       string `xml:",chardata"`
+}
+
+// RSS - the feed as RSS 2.0, authors are named with dc:creator
+// as <author> requires an email address
+func (f Feed) RSS() ([]byte, error) {
+	feed := rssFeed{
+		Version: "2.0",
+		XMLNSDC: "http://purl.org/dc/elements/1.1/",
+		XMLNSA:  "http://www.w3.org/2005/Atom",
+		Channel: rssChannel{
+			Title:       f.Title,
+			Link:        f.HomeURL,
+			Description: f.Description,
+			Self:        atomLink{Href: f.FeedURL, Rel: "self", Type: "application/rss+xml"},
+			Items:       []rssItem{},
+		},
+	}
+	if !f.Updated.IsZero() {
+		feed.Channel.LastBuildDate = f.Updated.UTC().Format(time.RFC1123Z)
+	}
+	for _, item := range f.Items {
+		feed.Channel.Items = append(feed.Channel.Items, rssItem{
+			Title:       item.Title,
+			Link:        item.URL,
+			GUID:        rssGUID{IsPermaLink: item.ID == item.URL, Value: item.ID},
+			PubDate:     item.Published.UTC().Format(time.RFC1123Z),
+			Creator:     item.Author.Name,
+			Categories:  item.Tags,
+			Description: item.ContentHTML,
+		})
+	}
+
+	return encodeXML(feed)
+}
+
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	Summary string      `xml:"subtitle,omitempty"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Links   []atomLink  `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+	Type string `xml:"type,attr,omitempty"`
+}
+
+type atomEntry struct {
+	Title      string         `xml:"title"`
+	ID         string         `xml:"id"`
+	Link       atomLink       `xml:"link"`
+	Published  string         `xml:"published"`
+	Updated    string         `xml:"updated"`
+	Author     atomAuthor     `xml:"author"`
+	Categories []atomCategory `xml:"category"`
+	Content    atomContent    `xml:"content"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+	URI  string `xml:"uri,omitempty"`
+}
+
+type atomCategory struct {
+	Term string `xml:"term,attr"`
+}
+
+type atomContent struct {
+	Type  string `xml:"type,attr"`
+	Value string `xml:",chardata"`
+}
+
+// Atom - the feed as Atom 1.0
+func (f Feed) Atom() ([]byte, error) {
+	feed := atomFeed{
+		Title:   f.Title,
+		Summary: f.Description,
+		ID:      f.FeedURL,
+		Updated: f.Updated.UTC().Format(time.RFC3339),
+		Links: []atomLink{
+			{Href: f.FeedURL, Rel: "self", Type: "application/atom+xml"},
+			{Href: f.HomeURL, Rel: "alternate"},
+		},
+		Entries: []atomEntry{},
+	}
+	for _, item := range f.Items {
+		entry := atomEntry{
+			Title:     item.Title,
+			ID:        item.ID,
+			Link:      atomLink{Href: item.URL, Rel: "alternate"},
+			Published: item.Published.UTC().Format(time.RFC3339),
+			Updated:   item.Updated.UTC().Format(time.RFC3339),
+			Author:    atomAuthor{Name: item.Author.Name, URI: item.Author.URL},
+			Content:   atomContent{Type: "html", Value: item.ContentHTML},
+		}
+		for _, tag := range item.Tags {
+			entry.Categories = append(entry.Categories, atomCategory{Term: tag})
+		}
+		feed.Entries = append(feed.Entries, entry)
+	}
+
+	return encodeXML(feed)
+}
+
+type jsonFeed struct {
+	Version     string         `json:"version"`
+	Title       string         `json:"title"`
+	HomePageURL string         `json:"home_page_url,omitempty"`
+	FeedURL     string         `json:"feed_url,omitempty"`
+	Description string         `json:"description,omitempty"`
+	Items       []jsonFeedItem `json:"items"`
+}
+
+type jsonFeedItem struct {
+	ID            string           `json:"id"`
+	URL           string           `json:"url,omitempty"`
+	Title         string           `json:"title,omitempty"`
+	ContentHTML   string           `json:"content_html"`
+	DatePublished string           `json:"date_published,omitempty"`
+	DateModified  string           `json:"date_modified,omitempty"`
+	Authors       []jsonFeedAuthor `json:"authors,omitempty"`
+	Tags          []string         `json:"tags,omitempty"`
+}
+
+type jsonFeedAuthor struct {
+	Name string `json:"name,omitempty"`
+	URL  string `json:"url,omitempty"`
+}
+
+// JSON - the feed as JSON Feed 1.1
+func (f Feed) JSON() ([]byte, error) {
+	feed := jsonFeed{
+		Version:     "https://jsonfeed.org/version/1.1",
+		Title:       f.Title,
+		HomePageURL: f.HomeURL,
+		FeedURL:     f.FeedURL,
+		Description: f.Description,
+		Items:       []jsonFeedItem{},
+	}
+	for _, item := range f.Items {
+		feed.Items = append(feed.Items, jsonFeedItem{
+			ID:            item.ID,
+			URL:           item.URL,
+			Title:         item.Title,
+			ContentHTML:   item.ContentHTML,
+			DatePublished: item.Published.UTC().Format(time.RFC3339),
+			DateModified:  item.Updated.UTC().Format(time.RFC3339),
+			Authors:       []jsonFeedAuthor{{Name: item.Author.Name, URL: item.Author.URL}},
+			Tags:          item.Tags,
+		})
+	}
+
+	buf := bytes.Buffer{}
+	enc := json.NewEncoder(&buf)
+	enc.SetEscapeHTML(false)
+	if err := enc.Encode(feed); err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// encodeXML - document with the XML declaration
+func encodeXML(v interface{}) ([]byte, error) {
+	buf := bytes.Buffer{}
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := enc.Encode(v); err != nil {
+		return nil, err
+	}
+	buf.WriteString("\n")
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,117 @@
+package lib_test
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func testFeed() lib.Feed {
+	published := time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)
+
+	return lib.Feed{
+		Title:   "blog",
+		HomeURL: "https://example.com",
+		FeedURL: "https://example.com/api/v1/feeds/rss",
+		Updated: published.Add(time.Hour),
+		Items: []lib.FeedItem{{
+			ID:          "https://example.com/api/v1/posts/hello",
+			URL:         "https://example.com/api/v1/posts/hello",
+			Title:       "Hello & welcome",
+			ContentHTML: "<p>hi</p>",
+			Published:   published,
+			Updated:     published.Add(time.Hour),
+			Author:      lib.FeedAuthor{Name: "Jane Doe", URL: "https://example.com/api/v1/posts?author=1"},
+			Tags:        []string{"go"},
+		}},
+	}
+}
+
+func TestFeedRSS(t *testing.T) {
+	out, err := testFeed().RSS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := struct {
+		Version string `xml:"version,attr"`
+		Channel struct {
+			Items []struct {
+				Title       string `xml:"title"`
+				PubDate     string `xml:"pubDate"`
+				Creator     string `xml:"http://purl.org/dc/elements/1.1/ creator"`
+				Description string `xml:"description"`
+			} `xml:"item"`
+		} `xml:"channel"`
+	}{}
+	if err := xml.Unmarshal(out, &feed); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+	if feed.Version != "2.0" || len(feed.Channel.Items) != 1 {
+		t.Fatalf("unexpected feed:\n%s", out)
+	}
+	item := feed.Channel.Items[0]
+	if item.Title != "Hello & welcome" || item.Creator != "Jane Doe" || item.Description != "<p>hi</p>" {
+		t.Errorf("unexpected item: %+v", item)
+	}
+	if item.PubDate != "Wed, 01 May 2024 10:00:00 +0000" {
+		t.Errorf("pubDate = %s", item.PubDate)
+	}
+}
+
+func TestFeedAtom(t *testing.T) {
+	out, err := testFeed().Atom()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed := struct {
+		XMLName xml.Name `xml:"http://www.w3.org/2005/Atom feed"`
+		Updated string   `xml:"updated"`
+		Entries []struct {
+			Author struct {
+				Name string `xml:"name"`
+			} `xml:"author"`
+			Content string `xml:"content"`
+		} `xml:"entry"`
+	}{}
+	if err := xml.Unmarshal(out, &feed); err != nil {
+		t.Fatalf("invalid XML: %v\n%s", err, out)
+	}
+	if feed.Updated != "2024-05-01T11:00:00Z" || len(feed.Entries) != 1 {
+		t.Fatalf("unexpected feed:\n%s", out)
+	}
+	if feed.Entries[0].Author.Name != "Jane Doe" || feed.Entries[0].Content != "<p>hi</p>" {
+		t.Errorf("unexpected entry: %+v", feed.Entries[0])
+	}
+}
+
+func TestFeedJSON(t *testing.T) {
+	out, err := testFeed().JSON()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(out), `\u003c`) {
+		t.Errorf("HTML must not be escaped: %s", out)
+	}
+
+	feed := map[string]interface{}{}
+	if err := json.Unmarshal(out, &feed); err != nil {
+		t.Fatal(err)
+	}
+	if feed["version"] != "https://jsonfeed.org/version/1.1" {
+		t.Errorf("version = %v", feed["version"])
+	}
+	items, _ := feed["items"].([]interface{})
+	if len(items) != 1 {
+		t.Fatalf("unexpected feed: %s", out)
+	}
+	item := items[0].(map[string]interface{})
+	if item["content_html"] != "<p>hi</p>" || item["date_published"] != "2024-05-01T10:00:00Z" {
+		t.Errorf("unexpected item: %v", item)
+	}
+}
@@ -0,0 +1,363 @@
+package lib
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"image"
+	"strings"
+	"time"
+)
+
+// ErrInvalidImage - the structure of an image is broken
+var ErrInvalidImage = errors.New("invalid image")
+
+// ImageMeta - metadata of an image which is kept when the
+// EXIF and XMP data is stripped
+type ImageMeta struct {
+	Orientation int        // EXIF orientation, 1 to 8, 0 when unknown
+	CapturedAt  *time.Time // when the picture was taken, nil when unknown
+}
+
+// exifHeader - start of an APP1 segment of a JPEG with EXIF data
+const exifHeader string = "Exif\x00\x00"
+
+// ReadImageMeta - read the orientation and capture date from
+// the EXIF data of a JPEG, PNG or WebP image
+//
+// Images without (valid) EXIF data return an empty ImageMeta
+func ReadImageMeta(data []byte, mimeType string) ImageMeta {
+	meta := ImageMeta{}
+
+	tiff := findExif(data, mimeType)
+	if tiff == nil {
+		return meta
+	}
+
+	var order binary.ByteOrder
+	switch {
+	case bytes.HasPrefix(tiff, []byte("II*\x00")):
+		order = binary.LittleEndian
+	case bytes.HasPrefix(tiff, []byte("MM\x00*")):
+		order = binary.BigEndian
+	default:
+		return meta
+	}
+
+	ifd0 := readIFD(tiff, order, order.Uint32(tiff[4:8]))
+
+	if v, ok := ifd0[0x0112]; ok { // Orientation, SHORT
+		if o := int(order.Uint16(v.value[:2])); o >= 1 && o <= 8 {
+			meta.Orientation = o
+		}
+	}
+
+	// DateTimeOriginal of the EXIF IFD, DateTime of IFD0 otherwise
+	date := ""
+	if v, ok := ifd0[0x8769]; ok {
+		exif := readIFD(tiff, order, order.Uint32(v.value[:4]))
+		if v, ok := exif[0x9003]; ok {
+			date = v.ascii(tiff, order)
+		}
+	}
+	if date == "" {
+		if v, ok := ifd0[0x0132]; ok {
+			date = v.ascii(tiff, order)
+		}
+	}
+	if t, err := time.Parse("2006:01:02 15:04:05", date); err == nil {
+		meta.CapturedAt = &t
+	}
+
+	return meta
+}
+
+// ifdEntry - one tag of an image file directory
+type ifdEntry struct {
+	kind  uint16
+	count uint32
+	value []byte // the 4 bytes holding the value or its offset
+}
+
+// ascii - value of an ASCII tag
+func (e ifdEntry) ascii(tiff []byte, order binary.ByteOrder) string {
+	if e.kind != 2 {
+		return ""
+	}
+
+	b := e.value
+	if e.count > 4 {
+		offset := order.Uint32(e.value)
+		if uint64(offset)+uint64(e.count) > uint64(len(tiff)) {
+			return ""
+		}
+		b = tiff[offset : offset+e.count]
+	} else {
+		b = b[:e.count]
+	}
+
+	return strings.TrimSpace(strings.TrimRight(string(b), "\x00"))
+}
+
+// readIFD - tags of the image file directory at the offset,
+// broken directories are read as far as they go
+func readIFD(tiff []byte, order binary.ByteOrder, offset uint32) map[uint16]ifdEntry {
+	entries := map[uint16]ifdEntry{}
+
+	if uint64(offset)+2 > uint64(len(tiff)) {
+		return entries
+	}
+	n := int(order.Uint16(tiff[offset:]))
+	p := int(offset) + 2
+
+	for i := 0; i < n && p+12 <= len(tiff); i++ {
+		entries[order.Uint16(tiff[p:])] = ifdEntry{
+			kind:  order.Uint16(tiff[p+2:]),
+			count: order.Uint32(tiff[p+4:]),
+			value: tiff[p+8 : p+12],
+		}
+		p += 12
+	}
+
+	return entries
+}
+
+// findExif - the TIFF structure of the EXIF data of an image
+func findExif(data []byte, mimeType string) []byte {
+	var tiff []byte
+
+	switch mimeType {
+	case "image/jpeg":
+		_ = jpegSegments(data, func(marker byte, segment []byte) bool {
+			if marker == 0xE1 && bytes.HasPrefix(segment[4:], []byte(exifHeader)) {
+				tiff = segment[4+len(exifHeader):]
+				return false
+			}
+			return true
+		})
+	case "image/png":
+		_ = pngChunks(data, func(kind string, chunk []byte) bool {
+			if kind == "eXIf" {
+				tiff = chunk[8 : len(chunk)-4]
+				return false
+			}
+			return true
+		})
+	case "image/webp":
+		_ = webpChunks(data, func(kind string, chunk []byte) bool {
+			if kind == "EXIF" {
+				tiff = bytes.TrimPrefix(chunk[8:8+binary.LittleEndian.Uint32(chunk[4:8])], []byte(exifHeader))
+				return false
+			}
+			return true
+		})
+	}
+
+	if len(tiff) < 8 {
+		return nil
+	}
+	return tiff
+}
+
+// StripImageMeta - remove EXIF, XMP, IPTC and comments from a
+// JPEG, PNG or WebP image without touching the picture itself
+//
+// Color profiles are kept. Other types are returned unchanged.
+func StripImageMeta(data []byte, mimeType string) ([]byte, error) {
+	out := bytes.Buffer{}
+	out.Grow(len(data))
+
+	switch mimeType {
+	case "image/jpeg":
+		out.Write(data[:2])
+		err := jpegSegments(data, func(marker byte, segment []byte) bool {
+			switch marker {
+			case 0xE1, 0xED, 0xFE: // EXIF and XMP, IPTC, comment
+			default:
+				out.Write(segment)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "image/png":
+		out.Write(data[:8])
+		err := pngChunks(data, func(kind string, chunk []byte) bool {
+			switch kind {
+			case "eXIf", "tEXt", "zTXt", "iTXt", "tIME": // XMP is kept in iTXt
+			default:
+				out.Write(chunk)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+
+	case "image/webp":
+		out.Write(data[:12])
+		err := webpChunks(data, func(kind string, chunk []byte) bool {
+			switch kind {
+			case "EXIF", "XMP ":
+			case "VP8X":
+				if len(chunk) < 18 {
+					out.Write(chunk)
+					break
+				}
+				// the flags announce EXIF and XMP chunks
+				out.Write(chunk[:8])
+				out.WriteByte(chunk[8] &^ 0x0C)
+				out.Write(chunk[9:])
+			default:
+				out.Write(chunk)
+			}
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		b := out.Bytes()
+		binary.LittleEndian.PutUint32(b[4:8], uint32(len(b)-8))
+
+	default:
+		return data, nil
+	}
+
+	return out.Bytes(), nil
+}
+
+// jpegSegments - call fn with the marker and the whole segment
+// (marker, length and payload) of every segment in front of
+// the image data, the image data is passed as one segment
+// with marker 0xDA, fn returns false to stop
+func jpegSegments(data []byte, fn func(marker byte, segment []byte) bool) error {
+	if len(data) < 4 || data[0] != 0xFF || data[1] != 0xD8 {
+		return ErrInvalidImage
+	}
+
+	p := 2
+	for {
+		// markers may be padded with 0xFF
+		for p+1 < len(data) && data[p] == 0xFF && data[p+1] == 0xFF {
+			p++
+		}
+		if p+2 > len(data) || data[p] != 0xFF {
+			return ErrInvalidImage
+		}
+
+		marker := data[p+1]
+		if marker == 0xDA || marker == 0xD9 {
+			// start of scan or end of image: the rest is data
+			fn(marker, data[p:])
+			return nil
+		}
+
+		if p+4 > len(data) {
+			return ErrInvalidImage
+		}
+		end := p + 2 + int(binary.BigEndian.Uint16(data[p+2:]))
+		if end > len(data) || end < p+4 {
+			return ErrInvalidImage
+		}
+		if !fn(marker, data[p:end]) {
+			return nil
+		}
+		p = end
+	}
+}
+
+// pngChunks - call fn with the type and the whole chunk
+// (length, type, data and crc) of every chunk of a PNG image,
+// fn returns false to stop
+func pngChunks(data []byte, fn func(kind string, chunk []byte) bool) error {
+	if !bytes.HasPrefix(data, []byte("\x89PNG\r\n\x1a\n")) {
+		return ErrInvalidImage
+	}
+
+	p := 8
+	for p < len(data) {
+		if p+12 > len(data) {
+			return ErrInvalidImage
+		}
+		end := uint64(p) + 12 + uint64(binary.BigEndian.Uint32(data[p:]))
+		if end > uint64(len(data)) {
+			return ErrInvalidImage
+		}
+		if !fn(string(data[p+4:p+8]), data[p:end]) {
+			return nil
+		}
+		p = int(end)
+	}
+
+	return nil
+}
+
+// webpChunks - call fn with the FourCC and the whole chunk
+// (FourCC, size, payload and padding) of every chunk of a
+// WebP image, fn returns false to stop
+func webpChunks(data []byte, fn func(kind string, chunk []byte) bool) error {
+	if len(data) < 12 || string(data[:4]) != "RIFF" || string(data[8:12]) != "WEBP" {
+		return ErrInvalidImage
+	}
+
+	p := 12
+	for p < len(data) {
+		if p+8 > len(data) {
+			return ErrInvalidImage
+		}
+		size := uint64(binary.LittleEndian.Uint32(data[p+4:]))
+		end := uint64(p) + 8 + size + size%2
+		if end > uint64(len(data)) {
+			return ErrInvalidImage
+		}
+		if !fn(string(data[p:p+4]), data[p:end]) {
+			return nil
+		}
+		p = int(end)
+	}
+
+	return nil
+}
+
+// OrientImage - turn an image the way its EXIF orientation
+// says it should be displayed
+func OrientImage(img image.Image, orientation int) image.Image {
+	if orientation < 2 || orientation > 8 {
+		return img
+	}
+
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	dw, dh := w, h
+	if orientation >= 5 {
+		dw, dh = h, w
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, dw, dh))
+	for y := 0; y < dh; y++ {
+		for x := 0; x < dw; x++ {
+			sx, sy := x, y
+			switch orientation {
+			case 2: // mirrored
+				sx = w - 1 - x
+			case 3: // upside down
+				sx, sy = w-1-x, h-1-y
+			case 4: // upside down, mirrored
+				sy = h - 1 - y
+			case 5: // transposed
+				sx, sy = y, x
+			case 6: // rotate clockwise
+				sx, sy = y, h-1-x
+			case 7: // transversed
+				sx, sy = w-1-y, h-1-x
+			case 8: // rotate counterclockwise
+				sx, sy = w-1-y, x
+			}
+			dst.Set(x, y, img.At(b.Min.X+sx, b.Min.Y+sy))
+		}
+	}
+
+	return dst
+}
@@ -0,0 +1,191 @@
+package lib_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"hash/crc32"
+	"image"
+	"image/color"
+	"image/jpeg"
+	"image/png"
+	"testing"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// testExif - little endian TIFF with an orientation, an EXIF
+// IFD with DateTimeOriginal and a GPS IFD pointer
+func testExif(orientation uint16) []byte {
+	b := &bytes.Buffer{}
+	le := binary.LittleEndian
+	b.WriteString("II*\x00")
+	binary.Write(b, le, uint32(8))
+
+	// IFD0 at 8: 3 entries, next IFD
+	binary.Write(b, le, uint16(3))
+	binary.Write(b, le, []uint16{0x0112, 3})
+	binary.Write(b, le, uint32(1))
+	binary.Write(b, le, []uint16{orientation, 0})
+	binary.Write(b, le, []uint16{0x8769, 4})
+	binary.Write(b, le, uint32(1))
+	binary.Write(b, le, uint32(50)) // EXIF IFD
+	binary.Write(b, le, []uint16{0x8825, 4})
+	binary.Write(b, le, uint32(1))
+	binary.Write(b, le, uint32(80)) // GPS IFD
+	binary.Write(b, le, uint32(0))
+
+	// EXIF IFD at 50: DateTimeOriginal
+	binary.Write(b, le, uint16(1))
+	binary.Write(b, le, []uint16{0x9003, 2})
+	binary.Write(b, le, uint32(20))
+	binary.Write(b, le, uint32(100))
+	binary.Write(b, le, uint32(0))
+
+	// GPS IFD at 80, empty, DateTimeOriginal at 100
+	b.Write(make([]byte, 100-b.Len()))
+	b.WriteString("2023:06:15 12:34:56\x00")
+
+	return b.Bytes()
+}
+
+// testJPEG - JPEG with EXIF, XMP and a comment
+func testJPEG(t *testing.T, orientation uint16) []byte {
+	img := image.NewRGBA(image.Rect(0, 0, 4, 2))
+	buf := bytes.Buffer{}
+	if err := jpeg.Encode(&buf, img, nil); err != nil {
+		t.Fatal(err)
+	}
+	plain := buf.Bytes()
+
+	segment := func(marker byte, payload []byte) []byte {
+		s := []byte{0xFF, marker, 0, 0}
+		binary.BigEndian.PutUint16(s[2:], uint16(len(payload)+2))
+		return append(s, payload...)
+	}
+
+	out := append([]byte{}, plain[:2]...)
+	out = append(out, segment(0xE1, append([]byte("Exif\x00\x00"), testExif(orientation)...))...)
+	out = append(out, segment(0xE1, []byte("http://ns.adobe.com/xap/1.0/\x00<x:xmpmeta/>"))...)
+	out = append(out, segment(0xFE, []byte("secret comment"))...)
+	return append(out, plain[2:]...)
+}
+
+// testPNG - PNG with eXIf and tEXt chunks
+func testPNG(t *testing.T) []byte {
+	buf := bytes.Buffer{}
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 4, 2))); err != nil {
+		t.Fatal(err)
+	}
+	plain := buf.Bytes()
+
+	chunk := func(kind string, data []byte) []byte {
+		c := make([]byte, 4, 12+len(data))
+		binary.BigEndian.PutUint32(c, uint32(len(data)))
+		c = append(append(c, kind...), data...)
+		return binary.BigEndian.AppendUint32(c, crc32.ChecksumIEEE(c[4:]))
+	}
+
+	// after the IHDR chunk
+	out := append([]byte{}, plain[:33]...)
+	out = append(out, chunk("eXIf", testExif(8))...)
+	out = append(out, chunk("tEXt", []byte("Comment\x00secret comment"))...)
+	return append(out, plain[33:]...)
+}
+
+func TestReadImageMeta(t *testing.T) {
+	want := time.Date(2023, 6, 15, 12, 34, 56, 0, time.UTC)
+
+	meta := lib.ReadImageMeta(testJPEG(t, 6), "image/jpeg")
+	if meta.Orientation != 6 {
+		t.Errorf("expected orientation 6, got %d", meta.Orientation)
+	}
+	if meta.CapturedAt == nil || !meta.CapturedAt.Equal(want) {
+		t.Errorf("expected capture date %v, got %v", want, meta.CapturedAt)
+	}
+
+	meta = lib.ReadImageMeta(testPNG(t), "image/png")
+	if meta.Orientation != 8 || meta.CapturedAt == nil {
+		t.Errorf("unexpected PNG metadata: %+v", meta)
+	}
+
+	meta = lib.ReadImageMeta([]byte("not an image"), "image/jpeg")
+	if meta.Orientation != 0 || meta.CapturedAt != nil {
+		t.Errorf("expected no metadata, got %+v", meta)
+	}
+}
+
+func TestStripImageMeta(t *testing.T) {
+	testCases := []struct {
+		mimeType string
+		data     []byte
+	}{
+		{"image/jpeg", testJPEG(t, 1)},
+		{"image/png", testPNG(t)},
+	}
+
+	for _, tc := range testCases {
+		stripped, err := lib.StripImageMeta(tc.data, tc.mimeType)
+		if err != nil {
+			t.Fatalf("%s: %v", tc.mimeType, err)
+		}
+
+		for _, leak := range []string{"Exif", "2023:06:15", "xmpmeta", "secret comment"} {
+			if bytes.Contains(stripped, []byte(leak)) {
+				t.Errorf("%s: %q was not stripped", tc.mimeType, leak)
+			}
+		}
+		if meta := lib.ReadImageMeta(stripped, tc.mimeType); meta.Orientation != 0 || meta.CapturedAt != nil {
+			t.Errorf("%s: metadata left: %+v", tc.mimeType, meta)
+		}
+
+		img, _, err := image.Decode(bytes.NewReader(stripped))
+		if err != nil {
+			t.Fatalf("%s: stripped image does not decode: %v", tc.mimeType, err)
+		}
+		if b := img.Bounds(); b.Dx() != 4 || b.Dy() != 2 {
+			t.Errorf("%s: unexpected size %v", tc.mimeType, b)
+		}
+	}
+
+	if _, err := lib.StripImageMeta([]byte{0xFF, 0xD8, 0xFF, 0xE1, 0xFF}, "image/jpeg"); err == nil {
+		t.Error("expected an error for a truncated JPEG")
+	}
+
+	pdf := []byte("%PDF-1.4")
+	if out, err := lib.StripImageMeta(pdf, "application/pdf"); err != nil || !bytes.Equal(out, pdf) {
+		t.Error("expected other types to be returned unchanged")
+	}
+}
+
+func TestOrientImage(t *testing.T) {
+	// 2x1: red, blue
+	red := color.RGBA{R: 255, A: 255}
+	blue := color.RGBA{B: 255, A: 255}
+	img := image.NewRGBA(image.Rect(0, 0, 2, 1))
+	img.Set(0, 0, red)
+	img.Set(1, 0, blue)
+
+	testCases := []struct {
+		orientation int
+		w, h        int
+		first       color.RGBA // top left after orientation
+	}{
+		{1, 2, 1, red},
+		{2, 2, 1, blue},
+		{3, 2, 1, blue},
+		{6, 1, 2, red},
+		{8, 1, 2, blue},
+	}
+
+	for _, tc := range testCases {
+		got := lib.OrientImage(img, tc.orientation)
+		if b := got.Bounds(); b.Dx() != tc.w || b.Dy() != tc.h {
+			t.Errorf("orientation %d: expected %dx%d, got %v", tc.orientation, tc.w, tc.h, b)
+			continue
+		}
+		if c := color.RGBAModel.Convert(got.At(0, 0)); c != tc.first {
+			t.Errorf("orientation %d: expected %v at the top left, got %v", tc.orientation, tc.first, c)
+		}
+	}
+}
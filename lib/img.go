@@ -2,11 +2,21 @@ package lib
 
 import (
 	"bytes"
+	"errors"
 	"image"
+	"image/jpeg"
 	"image/png"
+	"io"
 	"os"
 
+	// register decoders for the formats accepted by the media library
+	_ "image/gif"
+
+	_ "golang.org/x/image/webp"
+
+	"github.com/HugoSmits86/nativewebp"
 	"github.com/google/uuid"
+	"golang.org/x/image/draw"
 )
 
 // ByteToPNG - generate PNG from bytes and save on the disk
@@ -28,3 +38,67 @@ func ByteToPNG(imgByte []byte, path string) (string, error) {
 
 	return newImg, nil
 }
+
+// ErrTooManyPixels - the image is larger than allowed
+var ErrTooManyPixels = errors.New("image has too many pixels")
+
+// CheckImagePixels - read the dimensions from the header of an
+// image and refuse it when it has more than maxPixels pixels
+//
+// A small file may declare a huge picture, it must be checked
+// before anything decodes it
+func CheckImagePixels(r io.Reader, maxPixels int64) (image.Config, error) {
+	cfg, _, err := image.DecodeConfig(r)
+	if err != nil {
+		return cfg, err
+	}
+	if int64(cfg.Width)*int64(cfg.Height) > maxPixels {
+		return cfg, ErrTooManyPixels
+	}
+
+	return cfg, nil
+}
+
+// ResizeToFit - scale an image down so that it fits into a
+// box of maxSize x maxSize while keeping the aspect ratio,
+// smaller images are returned unchanged
+func ResizeToFit(img image.Image, maxSize int) image.Image {
+	b := img.Bounds()
+	w, h := b.Dx(), b.Dy()
+	if maxSize <= 0 || (w <= maxSize && h <= maxSize) {
+		return img
+	}
+
+	if w >= h {
+		h = h * maxSize / w
+		w = maxSize
+	} else {
+		w = w * maxSize / h
+		h = maxSize
+	}
+	if w < 1 {
+		w = 1
+	}
+	if h < 1 {
+		h = 1
+	}
+
+	dst := image.NewRGBA(image.Rect(0, 0, w, h))
+	draw.CatmullRom.Scale(dst, dst.Bounds(), img, b, draw.Over, nil)
+
+	return dst
+}
+
+// EncodeImage - encode an image as jpeg, png or webp (lossless)
+func EncodeImage(w io.Writer, img image.Image, format string) error {
+	switch format {
+	case "jpeg", "jpg":
+		return jpeg.Encode(w, img, &jpeg.Options{Quality: 85})
+	case "png":
+		return png.Encode(w, img)
+	case "webp":
+		return nativewebp.Encode(w, img, nil)
+	}
+
+	return errors.New("unsupported image format: " + format)
+}
@@ -2,6 +2,9 @@ package lib_test
 
 import (
 	"bytes"
+	"encoding/binary"
+	"errors"
+	"hash/crc32"
 	"image"
 	"image/png"
 	"os"
@@ -51,3 +54,86 @@ func TestByteToPNG(t *testing.T) {
 		t.Fatalf("generated file is not a valid PNG image: %v", err)
 	}
 }
+
+func TestResizeToFit(t *testing.T) {
+	testCases := []struct {
+		width, height int
+		maxSize       int
+		wantW, wantH  int
+	}{
+		{1200, 800, 300, 300, 200},
+		{800, 1200, 300, 200, 300},
+		{100, 50, 300, 100, 50},
+		{1000, 1, 100, 100, 1},
+		{640, 480, 0, 640, 480},
+	}
+
+	for _, tc := range testCases {
+		img := image.NewRGBA(image.Rect(0, 0, tc.width, tc.height))
+		got := lib.ResizeToFit(img, tc.maxSize).Bounds()
+		if got.Dx() != tc.wantW || got.Dy() != tc.wantH {
+			t.Errorf("ResizeToFit(%dx%d, %d) = %dx%d, want %dx%d",
+				tc.width, tc.height, tc.maxSize, got.Dx(), got.Dy(), tc.wantW, tc.wantH)
+		}
+	}
+}
+
+func TestEncodeImage(t *testing.T) {
+	img := image.NewRGBA(image.Rect(0, 0, 20, 10))
+
+	for _, format := range []string{"jpeg", "png", "webp"} {
+		var buf bytes.Buffer
+		if err := lib.EncodeImage(&buf, img, format); err != nil {
+			t.Errorf("EncodeImage(%s) failed: %v", format, err)
+			continue
+		}
+
+		decoded, gotFormat, err := image.Decode(&buf)
+		if err != nil {
+			t.Errorf("failed to decode %s output: %v", format, err)
+			continue
+		}
+		if gotFormat != format {
+			t.Errorf("decoded format = %s, want %s", gotFormat, format)
+		}
+		if decoded.Bounds().Dx() != 20 || decoded.Bounds().Dy() != 10 {
+			t.Errorf("%s: unexpected size %v", format, decoded.Bounds())
+		}
+	}
+
+	if err := lib.EncodeImage(&bytes.Buffer{}, img, "bmp"); err == nil {
+		t.Error("expected error for an unsupported format")
+	}
+}
+
+func TestCheckImagePixels(t *testing.T) {
+	var buf bytes.Buffer
+	if err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 20, 10))); err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	cfg, err := lib.CheckImagePixels(bytes.NewReader(buf.Bytes()), 200)
+	if err != nil || cfg.Width != 20 || cfg.Height != 10 {
+		t.Errorf("unexpected result: %+v (err: %v)", cfg, err)
+	}
+	if _, err := lib.CheckImagePixels(bytes.NewReader(buf.Bytes()), 199); !errors.Is(err, lib.ErrTooManyPixels) {
+		t.Errorf("expected ErrTooManyPixels, got %v", err)
+	}
+
+	// a few bytes declaring 30000 x 30000 pixels
+	ihdr := make([]byte, 17)
+	copy(ihdr, "IHDR")
+	binary.BigEndian.PutUint32(ihdr[4:], 30000)
+	binary.BigEndian.PutUint32(ihdr[8:], 30000)
+	ihdr[12], ihdr[13] = 8, 6 // 8 bit RGBA
+	bomb := []byte("\x89PNG\r\n\x1a\n\x00\x00\x00\x0d")
+	bomb = append(bomb, ihdr...)
+	bomb = binary.BigEndian.AppendUint32(bomb, crc32.ChecksumIEEE(ihdr))
+
+	if _, err := lib.CheckImagePixels(bytes.NewReader(bomb), 50_000_000); !errors.Is(err, lib.ErrTooManyPixels) {
+		t.Errorf("expected ErrTooManyPixels, got %v", err)
+	}
+	if _, err := lib.CheckImagePixels(bytes.NewReader([]byte("no image")), 50_000_000); err == nil {
+		t.Error("expected error for a file which is no image")
+	}
+}
@@ -0,0 +1,79 @@
+package lib
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"sort"
+	"strings"
+
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"github.com/yuin/goldmark/renderer/html"
+)
+
+// markdownVersion - bump when the rendering changes so that
+// cached HTML is rendered again
+const markdownVersion string = "1"
+
+// MarkdownRenderer - turns Markdown into HTML keeping only the
+// allowed elements and attributes
+type MarkdownRenderer struct {
+	// Key - identifies the allow-list and rendering, HTML
+	// cached under another key is outdated
+	Key string
+
+	markdown goldmark.Markdown
+	policy   *bluemonday.Policy
+}
+
+// NewMarkdownRenderer - renderer for the given allow-list of
+// elements and their attributes
+//
+// Raw HTML in the Markdown is passed on to the sanitizer, links
+// may only point at http, https, mailto or relative URLs and
+// get rel="nofollow"
+func NewMarkdownRenderer(allow map[string][]string) *MarkdownRenderer {
+	policy := bluemonday.NewPolicy()
+	policy.AllowStandardURLs()
+	policy.RequireNoFollowOnLinks(true)
+
+	elements := make([]string, 0, len(allow))
+	for element := range allow {
+		elements = append(elements, element)
+	}
+	sort.Strings(elements)
+
+	hash := sha256.New()
+	hash.Write([]byte(markdownVersion + "\n"))
+	for _, element := range elements {
+		attrs := append([]string(nil), allow[element]...)
+		sort.Strings(attrs)
+		hash.Write([]byte(element + "[" + strings.Join(attrs, " ") + "]\n"))
+
+		policy.AllowElements(element)
+		if len(attrs) > 0 {
+			policy.AllowAttrs(attrs...).OnElements(element)
+		}
+	}
+
+	return &MarkdownRenderer{
+		Key: hex.EncodeToString(hash.Sum(nil))[:32],
+		markdown: goldmark.New(
+			goldmark.WithExtensions(extension.GFM),
+			goldmark.WithRendererOptions(html.WithUnsafe()),
+		),
+		policy: policy,
+	}
+}
+
+// Render - sanitized HTML of the Markdown source
+func (r *MarkdownRenderer) Render(src string) (string, error) {
+	buf := bytes.Buffer{}
+	if err := r.markdown.Convert([]byte(src), &buf); err != nil {
+		return "", err
+	}
+
+	return r.policy.Sanitize(buf.String()), nil
+}
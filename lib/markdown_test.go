@@ -0,0 +1,53 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestMarkdownRenderer(t *testing.T) {
+	r := lib.NewMarkdownRenderer(map[string][]string{
+		"p":      {},
+		"em":     {},
+		"strong": {},
+		"a":      {"href", "title"},
+		"img":    {"src", "alt"},
+	})
+
+	testCases := []struct {
+		src  string
+		want string
+	}{
+		{"*hi* **there**", "<p><em>hi</em> <strong>there</strong></p>\n"},
+		{"[link](https://example.com)", `<p><a href="https://example.com" rel="nofollow">link</a></p>` + "\n"},
+		{"[x](javascript:alert(1))", "<p>x</p>\n"},
+		{`<script>alert(1)</script>`, ""},
+		{`<img src="a.png" alt="a" onerror="alert(1)">`, `<img src="a.png" alt="a">`},
+		{"# title", "title\n"},
+	}
+
+	for _, tc := range testCases {
+		got, err := r.Render(tc.src)
+		if err != nil {
+			t.Errorf("Render(%q) error: %v", tc.src, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("Render(%q) = %q, want %q", tc.src, got, tc.want)
+		}
+	}
+}
+
+func TestMarkdownRendererKey(t *testing.T) {
+	a := lib.NewMarkdownRenderer(map[string][]string{"p": {}, "a": {"href", "title"}})
+	b := lib.NewMarkdownRenderer(map[string][]string{"a": {"title", "href"}, "p": {}})
+	c := lib.NewMarkdownRenderer(map[string][]string{"p": {}, "a": {"href"}})
+
+	if a.Key != b.Key {
+		t.Errorf("same allow-list, different keys %q and %q", a.Key, b.Key)
+	}
+	if a.Key == c.Key {
+		t.Errorf("different allow-lists, same key %q", a.Key)
+	}
+}
@@ -107,23 +107,69 @@ func JWT() gin.HandlerFunc {
 		}
 
 		if claims, ok := token.Claims.(*JWTClaims); ok && token.Valid {
-			c.Set("userID", claims.UserID)
-			c.Set("email", claims.Email)
-			c.Set("role", claims.Role)
-			c.Set("scope", claims.Scope)
-			c.Set("tfa", claims.TwoFA)
-			c.Set("siteLan", claims.SiteLan)
-			c.Set("custom1", claims.Custom1)
-			c.Set("custom2", claims.Custom2)
-			c.Set("expAccess", claims.ExpiresAt.Unix()) // in UTC
-			c.Set("iatAccess", claims.IssuedAt.Unix())  // in UTC
-			c.Set("jtiAccess", claims.ID)
+			setAccessClaims(c, claims)
 		}
 
 		c.Next()
 	}
 }
 
+// OptionalJWT - validate the access token when the request
+// carries one
+//
+// Requests without a valid token pass anonymously, an expired
+// cookie must not lock anybody out of the public pages. So do
+// tokens still waiting for the OTP when 2-FA is in use, i.e.
+// keywordVerified is set, as TwoFA rejects them elsewhere
+func OptionalJWT(keywordOff, keywordVerified string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		accessJWT, err := c.Cookie("accessJWT")
+		if err != nil {
+			// Authorization: Bearer {access}
+			vals := strings.Split(c.Request.Header.Get("Authorization"), " ")
+			if len(vals) < 2 || !strings.Contains(vals[0], "Bearer") {
+				c.Next()
+				return
+			}
+			accessJWT = vals[1]
+		}
+
+		token, err := jwt.ParseWithClaims(accessJWT, &JWTClaims{}, ValidateAccessJWT)
+		if err != nil {
+			c.Next()
+			return
+		}
+		claims, ok := token.Claims.(*JWTClaims)
+		if !ok || !token.Valid {
+			c.Next()
+			return
+		}
+		if keywordVerified != "" && claims.TwoFA != "" && claims.TwoFA != keywordOff && claims.TwoFA != keywordVerified {
+			c.Next()
+			return
+		}
+		setAccessClaims(c, claims)
+
+		c.Next()
+	}
+}
+
+// setAccessClaims - claims of a valid access token for the
+// handlers
+func setAccessClaims(c *gin.Context, claims *JWTClaims) {
+	c.Set("userID", claims.UserID)
+	c.Set("email", claims.Email)
+	c.Set("role", claims.Role)
+	c.Set("scope", claims.Scope)
+	c.Set("tfa", claims.TwoFA)
+	c.Set("siteLan", claims.SiteLan)
+	c.Set("custom1", claims.Custom1)
+	c.Set("custom2", claims.Custom2)
+	c.Set("expAccess", claims.ExpiresAt.Unix()) // in UTC
+	c.Set("iatAccess", claims.IssuedAt.Unix())  // in UTC
+	c.Set("jtiAccess", claims.ID)
+}
+
 // RefreshJWT - validate refresh token
 func RefreshJWT() gin.HandlerFunc {
 	return func(c *gin.Context) {
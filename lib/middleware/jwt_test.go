@@ -249,6 +249,114 @@ func TestJWT(t *testing.T) {
 	}
 }
 
+func TestOptionalJWT(t *testing.T) {
+	// set JWT params
+	setParamsJWT()
+
+	// access token of a login with a verified OTP
+	verifiedClaims := customClaims
+	verifiedClaims.TwoFA = "verified"
+	accessJWT, _, err := middleware.GetJWT(verifiedClaims, "access")
+	if err != nil {
+		t.Errorf("error creating access JWT: %v", err)
+	}
+
+	// access token which expired a minute ago
+	middleware.JWTParams.AccessKeyTTL = -1
+	expiredAccessJWT, _, err := middleware.GetJWT(customClaims, "access")
+	if err != nil {
+		t.Errorf("error creating expired access JWT: %v", err)
+	}
+	setParamsJWT() // reset
+
+	// access token of a login still waiting for the OTP
+	pendingAccessJWT, _, err := middleware.GetJWT(customClaims, "access")
+	if err != nil {
+		t.Errorf("error creating access JWT: %v", err)
+	}
+
+	tests := []struct {
+		name           string
+		authorization  string
+		cookie         string
+		expectedStatus int
+		expectedUserID uint
+	}{
+		{
+			name:           "no authorization header",
+			authorization:  "",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid authorization header",
+			authorization:  "Bearer invalid",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "expired authorization header",
+			authorization:  "Bearer " + expiredAccessJWT,
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "invalid cookie",
+			cookie:         "invalid",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "valid cookie",
+			cookie:         accessJWT,
+			expectedStatus: http.StatusOK,
+			expectedUserID: customClaims.UserID,
+		},
+		{
+			name:           "valid authorization header",
+			authorization:  "Bearer " + accessJWT,
+			expectedStatus: http.StatusOK,
+			expectedUserID: customClaims.UserID,
+		},
+		{
+			name:           "2-fa not verified",
+			authorization:  "Bearer " + pendingAccessJWT,
+			expectedStatus: http.StatusOK,
+		},
+	}
+
+	gin.SetMode(gin.ReleaseMode)
+	router := gin.New()
+	router.Use(middleware.OptionalJWT("off", "verified"))
+
+	var userID uint
+	router.GET("/", func(c *gin.Context) {
+		userID = c.GetUint("userID")
+		c.Status(http.StatusOK)
+	})
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			userID = 0
+			req, err := http.NewRequest("GET", "/", nil)
+			if err != nil {
+				t.Errorf("failed to create an HTTP request: %v", err)
+				return
+			}
+			req.Header.Set("Authorization", test.authorization)
+			if test.cookie != "" {
+				req.AddCookie(&http.Cookie{Name: "accessJWT", Value: test.cookie})
+			}
+			res := httptest.NewRecorder()
+
+			router.ServeHTTP(res, req)
+
+			if res.Code != test.expectedStatus {
+				t.Errorf("expected status code %d, got %d", test.expectedStatus, res.Code)
+			}
+			if userID != test.expectedUserID {
+				t.Errorf("expected user ID %d, got %d", test.expectedUserID, userID)
+			}
+		})
+	}
+}
+
 func TestJWTAuthCookie(t *testing.T) {
 	// set JWT params
 	setParamsJWT()
@@ -0,0 +1,45 @@
+package lib
+
+import (
+	"io"
+	"strings"
+
+	"github.com/gabriel-vasile/mimetype"
+)
+
+// DetectMIME - detect the MIME type and the canonical
+// file extension from the content of the reader,
+// ignoring whatever extension the file name carries
+func DetectMIME(r io.Reader) (mimeType, ext string, err error) {
+	m, err := mimetype.DetectReader(r)
+	if err != nil {
+		return
+	}
+
+	mimeType = m.String()
+	// drop parameters, i.e. "; charset=utf-8"
+	if i := strings.Index(mimeType, ";"); i >= 0 {
+		mimeType = strings.TrimSpace(mimeType[:i])
+	}
+	ext = m.Extension()
+
+	return
+}
+
+// MIMEAllowed returns true if the MIME type matches one
+// of the allowed types. An allowed type may end with "/*"
+// to accept a whole family, i.e. "image/*"
+func MIMEAllowed(mimeType string, allowed []string) bool {
+	mimeType = strings.ToLower(mimeType)
+
+	for _, a := range allowed {
+		if a == "*/*" || a == mimeType {
+			return true
+		}
+		if strings.HasSuffix(a, "/*") && strings.HasPrefix(mimeType, strings.TrimSuffix(a, "*")) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,67 @@
+package lib_test
+
+import (
+	"bytes"
+	"image"
+	"image/png"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestDetectMIME(t *testing.T) {
+	var buf bytes.Buffer
+	err := png.Encode(&buf, image.NewRGBA(image.Rect(0, 0, 10, 10)))
+	if err != nil {
+		t.Fatalf("failed to encode test image: %v", err)
+	}
+
+	testCases := []struct {
+		name     string
+		input    []byte
+		wantType string
+		wantExt  string
+	}{
+		{"png", buf.Bytes(), "image/png", ".png"},
+		{"pdf", []byte("%PDF-1.4\n%âãÏÓ\n"), "application/pdf", ".pdf"},
+		{"plain text", []byte("hello world"), "text/plain", ".txt"},
+	}
+
+	for _, tc := range testCases {
+		gotType, gotExt, err := lib.DetectMIME(bytes.NewReader(tc.input))
+		if err != nil {
+			t.Errorf("%s: unexpected error: %v", tc.name, err)
+			continue
+		}
+		if gotType != tc.wantType || gotExt != tc.wantExt {
+			t.Errorf("%s: got (%q, %q), want (%q, %q)", tc.name, gotType, gotExt, tc.wantType, tc.wantExt)
+		}
+	}
+}
+
+func TestMIMEAllowed(t *testing.T) {
+	allowed := []string{"image/*", "application/pdf"}
+
+	testCases := []struct {
+		input string
+		want  bool
+	}{
+		{"image/png", true},
+		{"IMAGE/JPEG", true},
+		{"application/pdf", true},
+		{"application/zip", false},
+		{"imagex/png", false},
+		{"", false},
+	}
+
+	for _, tc := range testCases {
+		got := lib.MIMEAllowed(tc.input, allowed)
+		if got != tc.want {
+			t.Errorf("lib.MIMEAllowed(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+
+	if !lib.MIMEAllowed("video/mp4", []string{"*/*"}) {
+		t.Error("expected */* to allow everything")
+	}
+}
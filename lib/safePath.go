@@ -0,0 +1,27 @@
+package lib
+
+import (
+	"errors"
+	"path/filepath"
+	"strings"
+)
+
+// ErrPathOutsideRoot - the requested path escapes the root directory
+var ErrPathOutsideRoot = errors.New("path outside root directory")
+
+// SafeJoin - join a user-provided relative path to the root
+// directory and make sure the result stays inside the root
+func SafeJoin(root, rel string) (string, error) {
+	root = filepath.Clean(root)
+
+	// treat the user input as relative to the root even when
+	// it starts with a slash
+	rel = filepath.Clean(string(filepath.Separator) + filepath.FromSlash(rel))
+	path := filepath.Join(root, rel)
+
+	if path != root && !strings.HasPrefix(path, root+string(filepath.Separator)) {
+		return "", ErrPathOutsideRoot
+	}
+
+	return path, nil
+}
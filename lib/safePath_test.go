@@ -0,0 +1,37 @@
+package lib_test
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestSafeJoin(t *testing.T) {
+	root := filepath.Join("public", "media")
+
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{"", root},
+		{".", root},
+		{"/", root},
+		{"honey", filepath.Join(root, "honey")},
+		{"/honey/jar.png", filepath.Join(root, "honey", "jar.png")},
+		{"honey/../wax", filepath.Join(root, "wax")},
+		{"../../etc/passwd", filepath.Join(root, "etc", "passwd")},
+		{"honey/../../../secret", filepath.Join(root, "secret")},
+	}
+
+	for _, tc := range testCases {
+		got, err := lib.SafeJoin(root, tc.input)
+		if err != nil {
+			t.Errorf("lib.SafeJoin(%q) returned error: %v", tc.input, err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("lib.SafeJoin(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,34 @@
+package lib
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"strconv"
+	"time"
+)
+
+// SignPath - HMAC-SHA256 signature binding a path to an
+// expiry time (unix seconds), URL-safe
+func SignPath(key []byte, path string, expires int64) string {
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(path + "\n" + strconv.FormatInt(expires, 10)))
+
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// VerifyPathSignature returns true when the signature was made
+// for the path by one of the keys and has not expired yet
+func VerifyPathSignature(keys [][]byte, path string, expires int64, signature string, now time.Time) bool {
+	if signature == "" || now.Unix() > expires {
+		return false
+	}
+
+	for _, key := range keys {
+		if hmac.Equal([]byte(SignPath(key, path, expires)), []byte(signature)) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,42 @@
+package lib_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestPathSignature(t *testing.T) {
+	oldKey := []byte("old-signing-key")
+	newKey := []byte("new-signing-key")
+	now := time.Unix(1700000000, 0)
+	expires := now.Add(time.Minute).Unix()
+
+	signature := lib.SignPath(oldKey, "invoices/2024-01.pdf", expires)
+
+	testCases := []struct {
+		name      string
+		keys      [][]byte
+		path      string
+		expires   int64
+		signature string
+		now       time.Time
+		want      bool
+	}{
+		{"valid", [][]byte{oldKey}, "invoices/2024-01.pdf", expires, signature, now, true},
+		{"rotated key", [][]byte{newKey, oldKey}, "invoices/2024-01.pdf", expires, signature, now, true},
+		{"unknown key", [][]byte{newKey}, "invoices/2024-01.pdf", expires, signature, now, false},
+		{"other path", [][]byte{oldKey}, "invoices/2024-02.pdf", expires, signature, now, false},
+		{"extended expiry", [][]byte{oldKey}, "invoices/2024-01.pdf", expires + 60, signature, now, false},
+		{"expired", [][]byte{oldKey}, "invoices/2024-01.pdf", expires, signature, now.Add(2 * time.Minute), false},
+		{"no signature", [][]byte{oldKey}, "invoices/2024-01.pdf", expires, "", now, false},
+	}
+
+	for _, tc := range testCases {
+		got := lib.VerifyPathSignature(tc.keys, tc.path, tc.expires, tc.signature, tc.now)
+		if got != tc.want {
+			t.Errorf("%s: got %v, want %v", tc.name, got, tc.want)
+		}
+	}
+}
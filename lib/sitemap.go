@@ -0,0 +1,55 @@
+package lib
+
+import (
+	"encoding/xml"
+	"time"
+)
+
+// SitemapMaxURLs - most URLs in one sitemap file, larger
+// sitemaps are split and listed in a sitemap index
+const SitemapMaxURLs int = 50000
+
+// SitemapURL - location of a page or of a sitemap with the
+// time it last changed
+type SitemapURL struct {
+	Loc     string
+	LastMod time.Time // left out when zero
+}
+
+type sitemapLoc struct {
+	Loc     string `xml:"loc"`
+	LastMod string `xml:"lastmod,omitempty"`
+}
+
+type sitemapURLSet struct {
+	XMLName xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 urlset"`
+	URLs    []sitemapLoc `xml:"url"`
+}
+
+type sitemapIndex struct {
+	XMLName  xml.Name     `xml:"http://www.sitemaps.org/schemas/sitemap/0.9 sitemapindex"`
+	Sitemaps []sitemapLoc `xml:"sitemap"`
+}
+
+// sitemapLocs - locations with their W3C datetime
+func sitemapLocs(urls []SitemapURL) []sitemapLoc {
+	locs := make([]sitemapLoc, 0, len(urls))
+	for _, url := range urls {
+		loc := sitemapLoc{Loc: url.Loc}
+		if !url.LastMod.IsZero() {
+			loc.LastMod = url.LastMod.UTC().Format(time.RFC3339)
+		}
+		locs = append(locs, loc)
+	}
+	return locs
+}
+
+// Sitemap - urlset document of the pages
+func Sitemap(urls []SitemapURL) ([]byte, error) {
+	return encodeXML(sitemapURLSet{URLs: sitemapLocs(urls)})
+}
+
+// SitemapIndex - sitemapindex document of the sitemaps
+func SitemapIndex(sitemaps []SitemapURL) ([]byte, error) {
+	return encodeXML(sitemapIndex{Sitemaps: sitemapLocs(sitemaps)})
+}
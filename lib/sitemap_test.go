@@ -0,0 +1,53 @@
+package lib_test
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestSitemap(t *testing.T) {
+	out, err := lib.Sitemap([]lib.SitemapURL{
+		{Loc: "https://example.com/api/v1/posts?category=a&b", LastMod: time.Date(2024, 5, 1, 12, 0, 0, 0, time.FixedZone("", 7200))},
+		{Loc: "https://example.com/api/v1/users/1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `<?xml version="1.0" encoding="UTF-8"?>
+<urlset xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">
+  <url>
+    <loc>https://example.com/api/v1/posts?category=a&amp;b</loc>
+    <lastmod>2024-05-01T10:00:00Z</lastmod>
+  </url>
+  <url>
+    <loc>https://example.com/api/v1/users/1</loc>
+  </url>
+</urlset>
+`
+	if string(out) != want {
+		t.Errorf("lib.Sitemap() =\n%s\nwant\n%s", out, want)
+	}
+}
+
+func TestSitemapIndex(t *testing.T) {
+	out, err := lib.SitemapIndex([]lib.SitemapURL{
+		{Loc: "https://example.com/sitemaps/posts-1.xml", LastMod: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, part := range []string{
+		`<sitemapindex xmlns="http://www.sitemaps.org/schemas/sitemap/0.9">`,
+		`<loc>https://example.com/sitemaps/posts-1.xml</loc>`,
+		`<lastmod>2024-05-01T00:00:00Z</lastmod>`,
+	} {
+		if !strings.Contains(string(out), part) {
+			t.Errorf("lib.SitemapIndex() misses %s:\n%s", part, out)
+		}
+	}
+}
@@ -0,0 +1,41 @@
+package lib
+
+import (
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// MaxSlugLength - longest slug generated, longer ones are cut
+const MaxSlugLength int = 96
+
+// Slugify - lowercase, URL-safe form of a text: accents are
+// dropped, every run of other characters than a-z and 0-9
+// becomes one hyphen
+func Slugify(s string) string {
+	b := strings.Builder{}
+	hyphen := false
+
+	for _, r := range norm.NFD.String(strings.ToLower(s)) {
+		switch {
+		case unicode.Is(unicode.Mn, r):
+			// accent of the previous letter
+		case (r >= 'a' && r <= 'z') || (r >= '0' && r <= '9'):
+			if hyphen && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			hyphen = false
+			b.WriteRune(r)
+		default:
+			hyphen = true
+		}
+	}
+
+	slug := b.String()
+	if len(slug) > MaxSlugLength {
+		slug = strings.TrimRight(slug[:MaxSlugLength], "-")
+	}
+
+	return slug
+}
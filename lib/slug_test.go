@@ -0,0 +1,29 @@
+package lib_test
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestSlugify(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+	}{
+		{"", ""},
+		{"Hello World", "hello-world"},
+		{"  Acacia honey -- 2024! ", "acacia-honey-2024"},
+		{"Crème Brûlée & Miel", "creme-brulee-miel"},
+		{"日本語", ""},
+		{strings.Repeat("ab ", 50), strings.TrimRight(strings.Repeat("ab-", 32), "-")},
+	}
+
+	for _, tc := range testCases {
+		got := lib.Slugify(tc.input)
+		if got != tc.want {
+			t.Errorf("lib.Slugify(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
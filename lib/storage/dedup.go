@@ -0,0 +1,433 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strconv"
+	"strings"
+)
+
+// BlobDir - hidden folder at the root of a Dedup driver
+// keeping the content of all files
+const BlobDir string = ".blobs"
+
+// pointerPrefix - first bytes of a file which points at a blob
+const pointerPrefix string = "gintemp-blob sha256:"
+
+// maxPointerSize - files larger than this are never pointers
+const maxPointerSize int64 = 256
+
+// keyPath - secret of a Dedup driver signing its pointers,
+// created with the driver
+const keyPath string = BlobDir + "/key"
+
+// BlobIndex - reference counts of the blobs of a Dedup driver
+type BlobIndex interface {
+	// Acquire adds a reference to a blob
+	Acquire(digest string, size int64) error
+	// Release drops a reference to a blob and calls remove
+	// with the last one, no upload may acquire the blob until
+	// remove has returned. Blobs the index does not know are
+	// kept.
+	Release(digest string, remove func() error) error
+}
+
+// Linker - drivers which copy a file without reading it
+type Linker interface {
+	// Link makes dst a copy of the file src
+	Link(src, dst string) error
+}
+
+// Dedup - content-addressed driver on top of another driver
+//
+// The content of every file is kept once under BlobDir, keyed
+// by its SHA-256 digest. The file itself only holds a small
+// pointer to the blob, so the tree can be listed, renamed and
+// removed as usual. A blob is removed with the last file
+// pointing at it. Files written before deduplication was
+// enabled are read as they are.
+//
+// Pointers carry an HMAC of the blob, a file which only looks
+// like a pointer is a plain file and never reaches a blob.
+type Dedup struct {
+	s     Storage
+	index BlobIndex
+	key   []byte
+}
+
+// NewDedup - create a deduplicating driver, the secret for the
+// pointers is read from the storage or created on first use
+func NewDedup(s Storage, index BlobIndex) (*Dedup, error) {
+	key, err := dedupKey(s)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Dedup{s: s, index: index, key: key}, nil
+}
+
+// dedupKey - secret kept under BlobDir, out of reach of the
+// API consumers
+func dedupKey(s Storage) ([]byte, error) {
+	f, err := s.Open(keyPath)
+	if err == nil {
+		defer f.Close()
+		key, err := io.ReadAll(io.LimitReader(f, 1024))
+		if err == nil && len(key) < 32 {
+			err = errors.New("storage: dedup key too short")
+		}
+		return key, err
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return nil, err
+	}
+
+	key := make([]byte, 32)
+	if _, err := rand.Read(key); err != nil {
+		return nil, err
+	}
+	if err := MkdirAll(s, BlobDir); err != nil {
+		return nil, err
+	}
+	if _, err := s.Put(keyPath, bytes.NewReader(key), int64(len(key)), "application/octet-stream"); err != nil {
+		return nil, err
+	}
+
+	return key, nil
+}
+
+// blobPath - name of the blob with the given digest
+func blobPath(digest string) string {
+	return BlobDir + "/" + digest[:2] + "/" + digest
+}
+
+// pointer - content of a file pointing at a blob
+func (d *Dedup) pointer(digest string, size int64) string {
+	blob := digest + " " + strconv.FormatInt(size, 10)
+	return pointerPrefix + blob + " " + d.sign(blob) + "\n"
+}
+
+// sign - HMAC of the blob a pointer refers to
+func (d *Dedup) sign(blob string) string {
+	mac := hmac.New(sha256.New, d.key)
+	mac.Write([]byte(blob))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// readPointer - digest and size of the blob a file points at,
+// ok is false for files which keep their own content
+func (d *Dedup) readPointer(name string, info FileInfo) (digest string, size int64, ok bool, err error) {
+	if info.IsDir || info.Size > maxPointerSize || info.Size < int64(len(pointerPrefix)) {
+		return "", 0, false, nil
+	}
+
+	f, err := d.s.Open(name)
+	if err != nil {
+		return "", 0, false, err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxPointerSize))
+	if err != nil {
+		return "", 0, false, err
+	}
+
+	fields := strings.Fields(strings.TrimPrefix(string(content), pointerPrefix))
+	if !strings.HasPrefix(string(content), pointerPrefix) || len(fields) != 3 || len(fields[0]) != sha256.Size*2 {
+		return "", 0, false, nil
+	}
+	if _, err := hex.DecodeString(fields[0]); err != nil {
+		return "", 0, false, nil
+	}
+	// forged or written with another key
+	if !hmac.Equal([]byte(fields[2]), []byte(d.sign(fields[0]+" "+fields[1]))) {
+		return "", 0, false, nil
+	}
+	size, err = strconv.ParseInt(fields[1], 10, 64)
+	if err != nil {
+		return "", 0, false, nil
+	}
+
+	return fields[0], size, true, nil
+}
+
+// Stat returns information about a file or folder, the size
+// of a file is the size of its blob
+func (d *Dedup) Stat(name string) (FileInfo, error) {
+	info, err := d.s.Stat(name)
+	if err != nil {
+		return info, err
+	}
+
+	_, size, ok, err := d.readPointer(name, info)
+	if ok {
+		info.Size = size
+	}
+
+	return info, err
+}
+
+// List returns the direct children of a folder, the blobs
+// are not part of the tree
+func (d *Dedup) List(dir string) ([]FileInfo, error) {
+	dir, err := Clean(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	list, err := d.s.List(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(list))
+	for _, info := range list {
+		if dir == "." && info.Name == BlobDir {
+			continue
+		}
+		_, size, ok, err := d.readPointer(path.Join(dir, info.Name), info)
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			return nil, err
+		}
+		if ok {
+			info.Size = size
+		}
+		files = append(files, info)
+	}
+
+	return files, nil
+}
+
+// Open opens a file for reading, or the blob it points at
+func (d *Dedup) Open(name string) (File, error) {
+	info, err := d.s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+
+	digest, _, ok, err := d.readPointer(name, info)
+	if err != nil {
+		return nil, err
+	}
+	if ok {
+		return d.s.Open(blobPath(digest))
+	}
+
+	return d.s.Open(name)
+}
+
+// Put stores the content as a blob unless a blob with the
+// same content exists and writes a pointer to it
+func (d *Dedup) Put(name string, r io.Reader, size int64, contentType string) (int64, error) {
+	name, err := Clean(name)
+	if err != nil {
+		return 0, err
+	}
+	if name == "." || name == BlobDir || strings.HasPrefix(name, BlobDir+"/") {
+		return 0, ErrInvalid
+	}
+
+	// the digest is only known once everything is written
+	random := make([]byte, 16)
+	if _, err := rand.Read(random); err != nil {
+		return 0, err
+	}
+	tmp := BlobDir + "/tmp/" + hex.EncodeToString(random)
+	if err := MkdirAll(d.s, path.Dir(tmp)); err != nil {
+		return 0, err
+	}
+
+	hash := sha256.New()
+	n, err := d.s.Put(tmp, io.TeeReader(r, hash), size, contentType)
+	if err != nil {
+		d.s.RemoveAll(tmp)
+		return n, err
+	}
+	digest := hex.EncodeToString(hash.Sum(nil))
+
+	if err := d.index.Acquire(digest, n); err != nil {
+		d.s.RemoveAll(tmp)
+		return n, err
+	}
+	err = d.storeBlob(tmp, digest)
+	if err == nil {
+		err = d.link(name, digest, n)
+	}
+	if err != nil {
+		d.s.RemoveAll(tmp)
+		d.release(digest)
+		return n, err
+	}
+
+	return n, nil
+}
+
+// storeBlob - keep a written temporary file as the blob with
+// the given digest, or drop it when the blob already exists
+func (d *Dedup) storeBlob(tmp, digest string) error {
+	blob := blobPath(digest)
+
+	if _, err := d.s.Stat(blob); err == nil {
+		return d.s.RemoveAll(tmp)
+	}
+	if err := MkdirAll(d.s, path.Dir(blob)); err != nil {
+		return err
+	}
+	err := d.s.Rename(tmp, blob)
+	if errors.Is(err, fs.ErrExist) {
+		// stored by a concurrent upload
+		return d.s.RemoveAll(tmp)
+	}
+
+	return err
+}
+
+// link - point a file at a blob which has already been
+// acquired for it, the blob of a replaced file is released
+func (d *Dedup) link(name, digest string, size int64) error {
+	old := ""
+	if info, err := d.s.Stat(name); err == nil {
+		old, _, _, _ = d.readPointer(name, info)
+	}
+
+	content := d.pointer(digest, size)
+	if _, err := d.s.Put(name, strings.NewReader(content), int64(len(content)), "text/plain"); err != nil {
+		return err
+	}
+
+	if old != "" {
+		return d.release(old)
+	}
+	return nil
+}
+
+// Link makes dst point at the blob of src without copying
+// the content
+func (d *Dedup) Link(src, dst string) error {
+	info, err := d.s.Stat(src)
+	if err != nil {
+		return err
+	}
+	if info.IsDir {
+		return ErrInvalid
+	}
+
+	digest, size, ok, err := d.readPointer(src, info)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		// written before deduplication was enabled
+		f, err := d.s.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = d.Put(dst, f, info.Size, "")
+		return err
+	}
+
+	if err := d.index.Acquire(digest, size); err != nil {
+		return err
+	}
+	if err := d.link(dst, digest, size); err != nil {
+		d.release(digest)
+		return err
+	}
+
+	return nil
+}
+
+// Mkdir creates a folder
+func (d *Dedup) Mkdir(name string) error {
+	return d.s.Mkdir(name)
+}
+
+// Rename moves a file or folder, the blobs stay where they are
+func (d *Dedup) Rename(oldName, newName string) error {
+	return d.s.Rename(oldName, newName)
+}
+
+// RemoveAll removes a file or folder with everything in it
+// and the blobs nothing points at any more
+func (d *Dedup) RemoveAll(name string) error {
+	name, err := Clean(name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return ErrInvalid
+	}
+	if name == BlobDir || strings.HasPrefix(name, BlobDir+"/") {
+		// temporary files
+		return d.s.RemoveAll(name)
+	}
+
+	digests := []string{}
+	if err := d.collect(name, &digests); err != nil {
+		return err
+	}
+
+	if err := d.s.RemoveAll(name); err != nil {
+		return err
+	}
+
+	for _, digest := range digests {
+		if err := d.release(digest); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// collect - digests of the blobs the files of a tree point at
+func (d *Dedup) collect(name string, digests *[]string) error {
+	info, err := d.s.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	if !info.IsDir {
+		digest, _, ok, err := d.readPointer(name, info)
+		if ok {
+			*digests = append(*digests, digest)
+		}
+		return err
+	}
+
+	children, err := d.s.List(name)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if name == "." && child.Name == BlobDir {
+			continue
+		}
+		if err := d.collect(path.Join(name, child.Name), digests); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// release - drop a reference to a blob, removing the blob
+// with the last one
+func (d *Dedup) release(digest string) error {
+	return d.index.Release(digest, func() error {
+		err := d.s.RemoveAll(blobPath(digest))
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	})
+}
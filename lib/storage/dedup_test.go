@@ -0,0 +1,198 @@
+package storage_test
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib/storage"
+)
+
+// memIndex - in-memory reference counts
+type memIndex map[string]int64
+
+func (m memIndex) Acquire(digest string, size int64) error {
+	m[digest]++
+	return nil
+}
+
+func (m memIndex) Release(digest string, remove func() error) error {
+	if m[digest] == 0 {
+		return nil
+	}
+	m[digest]--
+	if m[digest] > 0 {
+		return nil
+	}
+	delete(m, digest)
+	return remove()
+}
+
+func TestDedup(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	local, err := storage.NewLocal(filepath.Join(tempDir, "media"))
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+	index := memIndex{}
+	s, err := storage.NewDedup(local, index)
+	if err != nil {
+		t.Fatalf("NewDedup failed: %v", err)
+	}
+
+	testDriver(t, s)
+	if err := s.RemoveAll("other.txt"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if len(index) != 0 {
+		t.Errorf("expected every blob to be released, got %v", index)
+	}
+
+	// the same content is stored once
+	content := "acacia honey, 500g"
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if _, err := s.Put(name, strings.NewReader(content), -1, "text/plain"); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+	}
+	if err := storage.Copy(s, "a.txt", "c.txt"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if len(index) != 1 {
+		t.Fatalf("expected one blob, got %v", index)
+	}
+	for _, refs := range index {
+		if refs != 3 {
+			t.Errorf("expected 3 references, got %d", refs)
+		}
+	}
+	blobs := countBlobs(t, local)
+	if blobs != 1 {
+		t.Errorf("expected one stored blob, got %d", blobs)
+	}
+
+	// the blob outlives all but the last file
+	for _, name := range []string{"a.txt", "b.txt"} {
+		if err := s.RemoveAll(name); err != nil {
+			t.Fatalf("RemoveAll failed: %v", err)
+		}
+	}
+	f, err := s.Open("c.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	got, err := io.ReadAll(f)
+	f.Close()
+	if err != nil || string(got) != content {
+		t.Errorf("read %q (err: %v), want %q", got, err, content)
+	}
+
+	// replacing the content releases the old blob
+	if _, err := s.Put("c.txt", strings.NewReader("wax"), -1, "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if len(index) != 1 || countBlobs(t, local) != 1 {
+		t.Errorf("expected the old blob to be removed, got %v", index)
+	}
+	if err := s.RemoveAll("c.txt"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if len(index) != 0 || countBlobs(t, local) != 0 {
+		t.Errorf("expected no blob to be left, got %v", index)
+	}
+
+	// a blob the index does not know is kept
+	if _, err := s.Put("d.txt", strings.NewReader(content), -1, "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	for digest := range index {
+		delete(index, digest)
+	}
+	if err := s.RemoveAll("d.txt"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if countBlobs(t, local) != 1 {
+		t.Error("expected an unknown blob to be kept")
+	}
+
+	// a file which only looks like a pointer is a plain file
+	if _, err := s.Put("e.txt", strings.NewReader(content), -1, "text/plain"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	refs := map[string]int64{}
+	for digest, n := range index {
+		refs[digest] = n
+	}
+	for digest := range refs {
+		forged := "gintemp-blob sha256:" + digest + " " + strconv.Itoa(len(content)) + " " + strings.Repeat("0", 64) + "\n"
+		if _, err := local.Put("forged.txt", strings.NewReader(forged), -1, ""); err != nil {
+			t.Fatalf("Put failed: %v", err)
+		}
+		f, err := s.Open("forged.txt")
+		if err != nil {
+			t.Fatalf("Open failed: %v", err)
+		}
+		got, err := io.ReadAll(f)
+		f.Close()
+		if err != nil || string(got) != forged {
+			t.Errorf("read %q (err: %v), want %q", got, err, forged)
+		}
+	}
+	if err := s.RemoveAll("forged.txt"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	for digest, n := range refs {
+		if index[digest] != n {
+			t.Errorf("expected %d references, got %d", n, index[digest])
+		}
+	}
+
+	// the key survives the driver
+	again, err := storage.NewDedup(local, index)
+	if err != nil {
+		t.Fatalf("NewDedup failed: %v", err)
+	}
+	if info, err := again.Stat("e.txt"); err != nil || info.Size != int64(len(content)) {
+		t.Errorf("unexpected file info: %+v (err: %v)", info, err)
+	}
+
+	// files written before deduplication are read as they are
+	if _, err := local.Put("old.txt", strings.NewReader(content), -1, ""); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if info, err := s.Stat("old.txt"); err != nil || info.Size != int64(len(content)) {
+		t.Errorf("unexpected file info: %+v (err: %v)", info, err)
+	}
+}
+
+// countBlobs - number of blobs kept by the underlying driver
+func countBlobs(t *testing.T, s storage.Storage) int {
+	t.Helper()
+
+	dirs, err := s.List(storage.BlobDir)
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+
+	n := 0
+	for _, dir := range dirs {
+		if dir.Name == "tmp" || !dir.IsDir {
+			continue
+		}
+		blobs, err := s.List(storage.BlobDir + "/" + dir.Name)
+		if err != nil {
+			t.Fatalf("List failed: %v", err)
+		}
+		n += len(blobs)
+	}
+
+	return n
+}
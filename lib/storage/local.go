@@ -0,0 +1,179 @@
+package storage
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// Local - driver for a folder on the local disk
+type Local struct {
+	root string
+}
+
+// NewLocal - create a local driver rooted at the given folder,
+// the folder is created when it does not exist
+func NewLocal(root string) (*Local, error) {
+	root = filepath.Clean(root)
+	if err := os.MkdirAll(root, os.ModePerm); err != nil {
+		return nil, err
+	}
+
+	return &Local{root: root}, nil
+}
+
+// path - absolute path of a name on the disk
+func (l *Local) path(name string) (string, error) {
+	name, err := Clean(name)
+	if err != nil {
+		return "", err
+	}
+
+	return lib.SafeJoin(l.root, name)
+}
+
+// Stat returns information about a file or folder
+func (l *Local) Stat(name string) (FileInfo, error) {
+	p, err := l.path(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	fi, err := os.Stat(p)
+	if err != nil {
+		return FileInfo{}, err
+	}
+
+	return fileInfo(fi), nil
+}
+
+// List returns the direct children of a folder sorted by name
+func (l *Local) List(dir string) ([]FileInfo, error) {
+	p, err := l.path(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	entries, err := os.ReadDir(p)
+	if err != nil {
+		return nil, err
+	}
+
+	files := make([]FileInfo, 0, len(entries))
+	for _, entry := range entries {
+		fi, err := entry.Info()
+		if err != nil {
+			// removed in the meantime
+			continue
+		}
+		files = append(files, fileInfo(fi))
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	return files, nil
+}
+
+// Open opens a file for reading
+func (l *Local) Open(name string) (File, error) {
+	p, err := l.path(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return os.Open(p)
+}
+
+// Put writes a file through a temporary file, so readers never
+// see a partially written file
+func (l *Local) Put(name string, r io.Reader, size int64, contentType string) (int64, error) {
+	p, err := l.path(name)
+	if err != nil {
+		return 0, err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(p), ".upload-*")
+	if err != nil {
+		return 0, err
+	}
+	defer os.Remove(tmp.Name())
+
+	n, err := io.Copy(tmp, r)
+	if err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Chmod(0o644); err != nil {
+		tmp.Close()
+		return n, err
+	}
+	if err := tmp.Close(); err != nil {
+		return n, err
+	}
+
+	return n, os.Rename(tmp.Name(), p)
+}
+
+// Mkdir creates a folder
+func (l *Local) Mkdir(name string) error {
+	p, err := l.path(name)
+	if err != nil {
+		return err
+	}
+
+	return os.Mkdir(p, os.ModePerm)
+}
+
+// Rename moves a file or folder, it never replaces an
+// existing target
+func (l *Local) Rename(oldName, newName string) error {
+	oldPath, err := l.path(oldName)
+	if err != nil {
+		return err
+	}
+	newPath, err := l.path(newName)
+	if err != nil {
+		return err
+	}
+	if oldPath == l.root || newPath == l.root {
+		return ErrInvalid
+	}
+
+	if _, err := os.Stat(newPath); err == nil {
+		return &os.LinkError{Op: "rename", Old: oldPath, New: newPath, Err: ErrExist}
+	}
+
+	return os.Rename(oldPath, newPath)
+}
+
+// RemoveAll removes a file or folder with everything in it
+func (l *Local) RemoveAll(name string) error {
+	p, err := l.path(name)
+	if err != nil {
+		return err
+	}
+	if p == l.root {
+		return ErrInvalid
+	}
+
+	if _, err := os.Lstat(p); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(p)
+}
+
+func fileInfo(fi os.FileInfo) FileInfo {
+	info := FileInfo{
+		Name:    fi.Name(),
+		ModTime: fi.ModTime(),
+		IsDir:   fi.IsDir(),
+	}
+	if !info.IsDir {
+		info.Size = fi.Size()
+	}
+
+	return info
+}
@@ -0,0 +1,32 @@
+package storage_test
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib/storage"
+)
+
+func TestLocal(t *testing.T) {
+	tempDir, err := os.MkdirTemp("", "test")
+	if err != nil {
+		t.Fatalf("failed to create temp dir: %v", err)
+	}
+	defer os.RemoveAll(tempDir)
+
+	s, err := storage.NewLocal(filepath.Join(tempDir, "media"))
+	if err != nil {
+		t.Fatalf("NewLocal failed: %v", err)
+	}
+
+	testDriver(t, s)
+
+	// nothing must be written outside the root
+	if _, err := s.Put("../escape.txt", strings.NewReader("x"), 1, ""); err == nil {
+		if _, err := os.Stat(filepath.Join(tempDir, "escape.txt")); err == nil {
+			t.Error("file written outside the root")
+		}
+	}
+}
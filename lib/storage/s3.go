@@ -0,0 +1,767 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// S3Config - settings for an S3-compatible object store
+type S3Config struct {
+	Endpoint  string // i.e. https://s3.eu-central-1.amazonaws.com or http://127.0.0.1:9000
+	Region    string
+	Bucket    string
+	AccessKey string
+	SecretKey string
+	Prefix    string // optional key prefix, i.e. "media/"
+	PathStyle bool   // address the bucket as endpoint/bucket instead of bucket.endpoint
+
+	// a request is cancelled when nothing has been sent or
+	// received for this long, DefaultS3Timeout when zero
+	//
+	// Long transfers are fine as long as data keeps flowing
+	Timeout time.Duration
+
+	// optional, http.DefaultClient is used when nil
+	HTTPClient *http.Client
+}
+
+// DefaultS3Timeout - longest stall of a request to S3
+const DefaultS3Timeout = 30 * time.Second
+
+// S3 - driver for an S3-compatible object store
+//
+// Folders are emulated with zero-byte marker objects
+// whose keys end with a slash, as most S3 consoles do
+type S3 struct {
+	conf     S3Config
+	endpoint *url.URL
+	client   *http.Client
+}
+
+// unsignedPayload - the payload is not part of the signature,
+// so uploads can be streamed
+const unsignedPayload = "UNSIGNED-PAYLOAD"
+
+// NewS3 - create an S3 driver
+func NewS3(conf S3Config) (*S3, error) {
+	if conf.Endpoint == "" || conf.Bucket == "" {
+		return nil, errors.New("storage: S3 endpoint and bucket are required")
+	}
+	if conf.Region == "" {
+		conf.Region = "us-east-1"
+	}
+	if conf.Timeout <= 0 {
+		conf.Timeout = DefaultS3Timeout
+	}
+	conf.Prefix = strings.Trim(conf.Prefix, "/")
+	if conf.Prefix != "" {
+		conf.Prefix += "/"
+	}
+
+	endpoint, err := url.Parse(strings.TrimRight(conf.Endpoint, "/"))
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, errors.New("storage: S3 endpoint must be an absolute URL")
+	}
+
+	client := conf.HTTPClient
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	return &S3{conf: conf, endpoint: endpoint, client: client}, nil
+}
+
+// key - object key of a name, the root maps to the prefix
+func (s *S3) key(name string) (string, error) {
+	name, err := Clean(name)
+	if err != nil {
+		return "", err
+	}
+	if name == "." {
+		return s.conf.Prefix, nil
+	}
+
+	return s.conf.Prefix + name, nil
+}
+
+// Stat returns information about a file or folder
+func (s *S3) Stat(name string) (FileInfo, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if key == s.conf.Prefix {
+		return FileInfo{Name: ".", IsDir: true}, nil
+	}
+
+	resp, err := s.do(http.MethodHead, key, nil, nil, -1, nil)
+	if err == nil {
+		resp.Body.Close()
+		return FileInfo{
+			Name:    path.Base(key),
+			Size:    resp.ContentLength,
+			ModTime: lastModified(resp.Header),
+		}, nil
+	}
+	if !errors.Is(err, fs.ErrNotExist) {
+		return FileInfo{}, err
+	}
+
+	// no object, but there may be a folder with this name
+	objects, prefixes, _, err := s.list(key+"/", "", "", 1)
+	if err != nil {
+		return FileInfo{}, err
+	}
+	if len(objects) == 0 && len(prefixes) == 0 {
+		return FileInfo{}, &fs.PathError{Op: "stat", Path: name, Err: fs.ErrNotExist}
+	}
+
+	info := FileInfo{Name: path.Base(key), IsDir: true}
+	if len(objects) > 0 && objects[0].Key == key+"/" {
+		info.ModTime = objects[0].LastModified
+	}
+
+	return info, nil
+}
+
+// List returns the direct children of a folder sorted by name
+func (s *S3) List(dir string) ([]FileInfo, error) {
+	info, err := s.Stat(dir)
+	if err != nil {
+		return nil, err
+	}
+	if !info.IsDir {
+		return nil, &fs.PathError{Op: "readdir", Path: dir, Err: errors.New("not a directory")}
+	}
+
+	prefix, err := s.dirKey(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	files := []FileInfo{}
+	token := ""
+	for {
+		objects, prefixes, next, err := s.list(prefix, "/", token, 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, p := range prefixes {
+			files = append(files, FileInfo{
+				Name:  path.Base(strings.TrimSuffix(p, "/")),
+				IsDir: true,
+			})
+		}
+		for _, o := range objects {
+			if o.Key == prefix {
+				// marker of the folder itself
+				continue
+			}
+			files = append(files, FileInfo{
+				Name:    path.Base(o.Key),
+				Size:    o.Size,
+				ModTime: o.LastModified,
+			})
+		}
+		if next == "" {
+			break
+		}
+		token = next
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	return files, nil
+}
+
+// Open opens a file for reading, the content is fetched
+// lazily with range requests so seeking is cheap
+func (s *S3) Open(name string) (File, error) {
+	info, err := s.Stat(name)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir {
+		return nil, &fs.PathError{Op: "open", Path: name, Err: errors.New("is a directory")}
+	}
+
+	key, err := s.key(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &s3File{s: s, key: key, size: info.Size}, nil
+}
+
+// Put writes a file, replacing an existing one
+func (s *S3) Put(name string, r io.Reader, size int64, contentType string) (int64, error) {
+	key, err := s.key(name)
+	if err != nil {
+		return 0, err
+	}
+	if key == s.conf.Prefix {
+		return 0, ErrInvalid
+	}
+
+	// S3 needs the length upfront
+	if size < 0 {
+		tmp, err := os.CreateTemp("", "s3-upload-*")
+		if err != nil {
+			return 0, err
+		}
+		defer os.Remove(tmp.Name())
+		defer tmp.Close()
+
+		if size, err = io.Copy(tmp, r); err != nil {
+			return 0, err
+		}
+		if _, err = tmp.Seek(0, io.SeekStart); err != nil {
+			return 0, err
+		}
+		r = tmp
+	}
+
+	header := http.Header{}
+	if contentType != "" {
+		header.Set("Content-Type", contentType)
+	}
+	resp, err := s.do(http.MethodPut, key, nil, header, size, r)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+
+	return size, nil
+}
+
+// Mkdir creates a folder marker
+func (s *S3) Mkdir(name string) error {
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	if key == s.conf.Prefix {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	parent, err := s.Stat(path.Dir(strings.TrimPrefix(key, s.conf.Prefix)))
+	if err != nil {
+		return err
+	}
+	if !parent.IsDir {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: errors.New("parent is not a directory")}
+	}
+	if _, err := s.Stat(name); err == nil {
+		return &fs.PathError{Op: "mkdir", Path: name, Err: fs.ErrExist}
+	}
+
+	resp, err := s.do(http.MethodPut, key+"/", nil, nil, 0, bytes.NewReader(nil))
+	if err != nil {
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// Rename copies every object to the new name and removes
+// the old ones afterwards, it never replaces an existing target
+func (s *S3) Rename(oldName, newName string) error {
+	info, err := s.Stat(oldName)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Stat(newName); err == nil {
+		return &fs.PathError{Op: "rename", Path: newName, Err: fs.ErrExist}
+	}
+
+	oldKey, err := s.key(oldName)
+	if err != nil {
+		return err
+	}
+	newKey, err := s.key(newName)
+	if err != nil {
+		return err
+	}
+	if oldKey == s.conf.Prefix || newKey == s.conf.Prefix || strings.HasPrefix(newKey, oldKey+"/") {
+		return ErrInvalid
+	}
+
+	if !info.IsDir {
+		if err := s.copy(oldKey, newKey); err != nil {
+			return err
+		}
+		return s.delete(oldKey)
+	}
+
+	keys, err := s.listAll(oldKey + "/")
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.copy(k, newKey+strings.TrimPrefix(k, oldKey)); err != nil {
+			return err
+		}
+	}
+	for _, k := range keys {
+		if err := s.delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveAll removes a file or folder with everything in it
+func (s *S3) RemoveAll(name string) error {
+	info, err := s.Stat(name)
+	if err != nil {
+		return err
+	}
+
+	key, err := s.key(name)
+	if err != nil {
+		return err
+	}
+	if key == s.conf.Prefix {
+		return ErrInvalid
+	}
+
+	if !info.IsDir {
+		return s.delete(key)
+	}
+
+	keys, err := s.listAll(key + "/")
+	if err != nil {
+		return err
+	}
+	for _, k := range keys {
+		if err := s.delete(k); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// dirKey - key prefix of the children of a folder
+func (s *S3) dirKey(dir string) (string, error) {
+	key, err := s.key(dir)
+	if err != nil {
+		return "", err
+	}
+	if key == s.conf.Prefix {
+		return key, nil
+	}
+
+	return key + "/", nil
+}
+
+// s3Error - error document of a response, CopyObject may send
+// it with a 200 status when the copy fails halfway
+type s3Error struct {
+	XMLName xml.Name
+	Code    string `xml:"Code"`
+	Message string `xml:"Message"`
+}
+
+// copy - server-side copy of an object
+func (s *S3) copy(srcKey, dstKey string) error {
+	header := http.Header{}
+	header.Set("X-Amz-Copy-Source", "/"+s.conf.Bucket+"/"+uriEncode(srcKey, false))
+
+	resp, err := s.do(http.MethodPut, dstKey, nil, header, 0, bytes.NewReader(nil))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	result := s3Error{}
+	if err := xml.NewDecoder(io.LimitReader(resp.Body, 64<<10)).Decode(&result); err != nil {
+		return fmt.Errorf("storage: S3 copy %s: %w", dstKey, err)
+	}
+	if result.XMLName.Local == "Error" {
+		return fmt.Errorf("storage: S3 copy %s: %s: %s", dstKey, result.Code, result.Message)
+	}
+
+	return nil
+}
+
+// delete - remove an object, a missing one is not an error
+func (s *S3) delete(key string) error {
+	resp, err := s.do(http.MethodDelete, key, nil, nil, -1, nil)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		return err
+	}
+	resp.Body.Close()
+
+	return nil
+}
+
+// s3Object - one entry of a ListObjectsV2 response
+type s3Object struct {
+	Key          string    `xml:"Key"`
+	Size         int64     `xml:"Size"`
+	LastModified time.Time `xml:"LastModified"`
+}
+
+// s3ListResult - body of a ListObjectsV2 response
+type s3ListResult struct {
+	Contents       []s3Object `xml:"Contents"`
+	CommonPrefixes []struct {
+		Prefix string `xml:"Prefix"`
+	} `xml:"CommonPrefixes"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// list - one page of ListObjectsV2
+func (s *S3) list(prefix, delimiter, token string, maxKeys int) (objects []s3Object, prefixes []string, next string, err error) {
+	query := url.Values{}
+	query.Set("list-type", "2")
+	query.Set("prefix", prefix)
+	query.Set("max-keys", strconv.Itoa(maxKeys))
+	if delimiter != "" {
+		query.Set("delimiter", delimiter)
+	}
+	if token != "" {
+		query.Set("continuation-token", token)
+	}
+
+	resp, err := s.do(http.MethodGet, "", query, nil, -1, nil)
+	if err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	result := s3ListResult{}
+	if err = xml.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return
+	}
+
+	objects = result.Contents
+	for _, p := range result.CommonPrefixes {
+		prefixes = append(prefixes, p.Prefix)
+	}
+	if result.IsTruncated {
+		next = result.NextContinuationToken
+	}
+
+	return
+}
+
+// listAll - every key under a prefix, folder markers included
+func (s *S3) listAll(prefix string) ([]string, error) {
+	keys := []string{}
+	token := ""
+	for {
+		objects, _, next, err := s.list(prefix, "", token, 1000)
+		if err != nil {
+			return nil, err
+		}
+		for _, o := range objects {
+			keys = append(keys, o.Key)
+		}
+		if next == "" {
+			return keys, nil
+		}
+		token = next
+	}
+}
+
+// objectURL - URL of an object, or of the bucket when key is empty
+func (s *S3) objectURL(key string, query url.Values) string {
+	host := s.endpoint.Host
+	p := strings.TrimRight(s.endpoint.Path, "/")
+
+	if s.conf.PathStyle {
+		p += "/" + s.conf.Bucket + "/" + key
+	} else {
+		host = s.conf.Bucket + "." + host
+		p += "/" + key
+	}
+
+	u := s.endpoint.Scheme + "://" + host + uriEncode(p, false)
+	if len(query) > 0 {
+		u += "?" + canonicalQuery(query)
+	}
+
+	return u
+}
+
+// do - send a signed request, a 404 is reported as fs.ErrNotExist
+// and any other non-2xx status as an error
+//
+// The request is cancelled when the endpoint stalls for longer
+// than the timeout, closing the body of the response releases it
+func (s *S3) do(method, key string, query url.Values, header http.Header, size int64, body io.Reader) (*http.Response, error) {
+	ctx, cancel := context.WithCancel(context.Background())
+	guard := &stallGuard{timer: time.AfterFunc(s.conf.Timeout, cancel), timeout: s.conf.Timeout}
+	release := func() {
+		guard.timer.Stop()
+		cancel()
+	}
+
+	if body != nil {
+		body = &stallReader{r: body, guard: guard}
+	}
+	req, err := http.NewRequestWithContext(ctx, method, s.objectURL(key, query), body)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	if size >= 0 && body != nil {
+		req.ContentLength = size
+		if size == 0 {
+			req.Body = http.NoBody
+		}
+	}
+	s.sign(req, time.Now().UTC())
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		release()
+		return nil, err
+	}
+	guard.kick()
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		release()
+		return nil, &fs.PathError{Op: strings.ToLower(method), Path: key, Err: fs.ErrNotExist}
+	}
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		release()
+		return nil, fmt.Errorf("storage: S3 %s %s: %s: %s", method, key, resp.Status, strings.TrimSpace(string(msg)))
+	}
+	resp.Body = &stallBody{ReadCloser: resp.Body, reader: stallReader{r: resp.Body, guard: guard}, release: release}
+
+	return resp, nil
+}
+
+// stallGuard - cancels a request which has not made progress
+// for the timeout
+type stallGuard struct {
+	timer   *time.Timer
+	timeout time.Duration
+}
+
+func (g *stallGuard) kick() {
+	g.timer.Reset(g.timeout)
+}
+
+// stallReader - body of a request or response, every read which
+// moves data pushes the deadline back
+type stallReader struct {
+	r     io.Reader
+	guard *stallGuard
+}
+
+func (r *stallReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if n > 0 {
+		r.guard.kick()
+	}
+	return n, err
+}
+
+// stallBody - response body guarded against a stalled endpoint
+type stallBody struct {
+	io.ReadCloser
+	reader  stallReader
+	release func()
+}
+
+func (b *stallBody) Read(p []byte) (int, error) {
+	return b.reader.Read(p)
+}
+
+func (b *stallBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.release()
+	return err
+}
+
+// sign - AWS Signature Version 4
+//
+// https://docs.aws.amazon.com/AmazonS3/latest/API/sig-v4-header-based-auth.html
+func (s *S3) sign(req *http.Request, now time.Time) {
+	amzDate := now.Format("20060102T150405Z")
+	date := now.Format("20060102")
+
+	req.Header.Set("X-Amz-Date", amzDate)
+	req.Header.Set("X-Amz-Content-Sha256", unsignedPayload)
+
+	// sign the host, content type and every x-amz-* header
+	headers := map[string]string{"host": req.URL.Host}
+	for k, v := range req.Header {
+		lk := strings.ToLower(k)
+		if lk == "content-type" || strings.HasPrefix(lk, "x-amz-") {
+			headers[lk] = strings.TrimSpace(strings.Join(v, ","))
+		}
+	}
+	names := make([]string, 0, len(headers))
+	for k := range headers {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	canonicalHeaders := ""
+	for _, k := range names {
+		canonicalHeaders += k + ":" + headers[k] + "\n"
+	}
+	signedHeaders := strings.Join(names, ";")
+
+	canonicalRequest := strings.Join([]string{
+		req.Method,
+		req.URL.EscapedPath(),
+		req.URL.RawQuery,
+		canonicalHeaders,
+		signedHeaders,
+		unsignedPayload,
+	}, "\n")
+
+	scope := date + "/" + s.conf.Region + "/s3/aws4_request"
+	hash := sha256.Sum256([]byte(canonicalRequest))
+	stringToSign := "AWS4-HMAC-SHA256\n" + amzDate + "\n" + scope + "\n" + hex.EncodeToString(hash[:])
+
+	key := hmacSHA256([]byte("AWS4"+s.conf.SecretKey), date)
+	key = hmacSHA256(key, s.conf.Region)
+	key = hmacSHA256(key, "s3")
+	key = hmacSHA256(key, "aws4_request")
+	signature := hex.EncodeToString(hmacSHA256(key, stringToSign))
+
+	req.Header.Set("Authorization", "AWS4-HMAC-SHA256 Credential="+s.conf.AccessKey+"/"+scope+
+		", SignedHeaders="+signedHeaders+", Signature="+signature)
+}
+
+func hmacSHA256(key []byte, data string) []byte {
+	h := hmac.New(sha256.New, key)
+	h.Write([]byte(data))
+	return h.Sum(nil)
+}
+
+// uriEncode - encoding required by SigV4, only unreserved
+// characters are kept as they are
+func uriEncode(s string, encodeSlash bool) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '_' || c == '.' || c == '~' || (c == '/' && !encodeSlash) {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+// canonicalQuery - sorted and encoded query string
+func canonicalQuery(query url.Values) string {
+	keys := make([]string, 0, len(query))
+	for k := range query {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	parts := []string{}
+	for _, k := range keys {
+		for _, v := range query[k] {
+			parts = append(parts, uriEncode(k, true)+"="+uriEncode(v, true))
+		}
+	}
+	return strings.Join(parts, "&")
+}
+
+func lastModified(h http.Header) time.Time {
+	t, err := http.ParseTime(h.Get("Last-Modified"))
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// s3File - object opened for reading
+type s3File struct {
+	s      *S3
+	key    string
+	size   int64
+	offset int64
+	body   io.ReadCloser
+}
+
+func (f *s3File) Read(p []byte) (int, error) {
+	if f.offset >= f.size {
+		return 0, io.EOF
+	}
+
+	if f.body == nil {
+		header := http.Header{}
+		header.Set("Range", "bytes="+strconv.FormatInt(f.offset, 10)+"-")
+		resp, err := f.s.do(http.MethodGet, f.key, nil, header, -1, nil)
+		if err != nil {
+			return 0, err
+		}
+		f.body = resp.Body
+	}
+
+	n, err := f.body.Read(p)
+	f.offset += int64(n)
+
+	return n, err
+}
+
+func (f *s3File) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekCurrent:
+		offset += f.offset
+	case io.SeekEnd:
+		offset += f.size
+	}
+	if offset < 0 {
+		return 0, errors.New("storage: negative position")
+	}
+
+	if offset != f.offset && f.body != nil {
+		f.body.Close()
+		f.body = nil
+	}
+	f.offset = offset
+
+	return offset, nil
+}
+
+func (f *s3File) Close() error {
+	if f.body == nil {
+		return nil
+	}
+	err := f.body.Close()
+	f.body = nil
+	return err
+}
@@ -0,0 +1,278 @@
+package storage_test
+
+import (
+	"encoding/xml"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/tinkerbaj/gintemp/lib/storage"
+)
+
+// fakeS3 - minimal in-process S3 server, enough for the
+// calls made by the driver (path-style addressing only)
+type fakeS3 struct {
+	mu      sync.Mutex
+	bucket  string
+	objects map[string][]byte
+}
+
+func newFakeS3(bucket string) *httptest.Server {
+	f := &fakeS3{bucket: bucket, objects: map[string][]byte{}}
+	return httptest.NewServer(f)
+}
+
+func (f *fakeS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// read the body before locking, it may be streamed from
+	// another request to this server
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	auth := r.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "AWS4-HMAC-SHA256 Credential=access/") ||
+		!strings.Contains(auth, "SignedHeaders=") || !strings.Contains(auth, "Signature=") ||
+		r.Header.Get("X-Amz-Date") == "" {
+		http.Error(w, "AccessDenied", http.StatusForbidden)
+		return
+	}
+
+	p := strings.TrimPrefix(r.URL.Path, "/")
+	if p != f.bucket && !strings.HasPrefix(p, f.bucket+"/") {
+		http.Error(w, "NoSuchBucket", http.StatusNotFound)
+		return
+	}
+	key := strings.TrimPrefix(strings.TrimPrefix(p, f.bucket), "/")
+
+	if key == "" && r.Method == http.MethodGet {
+		f.list(w, r)
+		return
+	}
+
+	switch r.Method {
+	case http.MethodHead, http.MethodGet:
+		data, ok := f.objects[key]
+		if !ok {
+			http.Error(w, "NoSuchKey", http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Last-Modified", time.Now().UTC().Format(http.TimeFormat))
+		if rng := r.Header.Get("Range"); rng != "" && r.Method == http.MethodGet {
+			start, _ := strconv.Atoi(strings.TrimSuffix(strings.TrimPrefix(rng, "bytes="), "-"))
+			data = data[start:]
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+		} else {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		}
+		if r.Method == http.MethodGet {
+			_, _ = w.Write(data)
+		}
+
+	case http.MethodPut:
+		if src := r.Header.Get("X-Amz-Copy-Source"); src != "" {
+			srcKey := strings.TrimPrefix(src, "/"+f.bucket+"/")
+			data, ok := f.objects[srcKey]
+			if !ok {
+				http.Error(w, "NoSuchKey", http.StatusNotFound)
+				return
+			}
+			// the copy fails after the status has been sent
+			if strings.Contains(srcKey, "copy-fails") {
+				_, _ = w.Write([]byte("<Error><Code>InternalError</Code><Message>We encountered an internal error.</Message></Error>"))
+				return
+			}
+			f.objects[key] = append([]byte(nil), data...)
+			_, _ = w.Write([]byte("<CopyObjectResult></CopyObjectResult>"))
+			return
+		}
+		f.objects[key] = body
+
+	case http.MethodDelete:
+		delete(f.objects, key)
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("list-type") != "2" {
+		http.Error(w, "only ListObjectsV2", http.StatusBadRequest)
+		return
+	}
+	prefix, delimiter, token := q.Get("prefix"), q.Get("delimiter"), q.Get("continuation-token")
+	maxKeys, _ := strconv.Atoi(q.Get("max-keys"))
+
+	keys := []string{}
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > token {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+
+	type object struct {
+		Key          string `xml:"Key"`
+		Size         int    `xml:"Size"`
+		LastModified string `xml:"LastModified"`
+	}
+	type commonPrefix struct {
+		Prefix string `xml:"Prefix"`
+	}
+	result := struct {
+		XMLName               xml.Name       `xml:"ListBucketResult"`
+		Contents              []object       `xml:"Contents"`
+		CommonPrefixes        []commonPrefix `xml:"CommonPrefixes"`
+		IsTruncated           bool           `xml:"IsTruncated"`
+		NextContinuationToken string         `xml:"NextContinuationToken,omitempty"`
+	}{}
+
+	seen := map[string]bool{}
+	count := 0
+	for _, k := range keys {
+		if count == maxKeys {
+			result.IsTruncated = true
+			break
+		}
+		rest := strings.TrimPrefix(k, prefix)
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			cp := prefix + rest[:i+1]
+			if !seen[cp] {
+				seen[cp] = true
+				result.CommonPrefixes = append(result.CommonPrefixes, commonPrefix{cp})
+				count++
+			}
+		} else {
+			result.Contents = append(result.Contents, object{k, len(f.objects[k]), "2024-01-02T15:04:05.000Z"})
+			count++
+		}
+		result.NextContinuationToken = k
+		if i := strings.Index(rest, delimiter); delimiter != "" && i >= 0 {
+			// skip the rest of the common prefix
+			result.NextContinuationToken = prefix + rest[:i+1] + "\xff"
+		}
+	}
+	if !result.IsTruncated {
+		result.NextContinuationToken = ""
+	}
+
+	w.Header().Set("Content-Type", "application/xml")
+	_ = xml.NewEncoder(w).Encode(result)
+}
+
+func TestS3(t *testing.T) {
+	srv := newFakeS3("media")
+	defer srv.Close()
+
+	s, err := storage.NewS3(storage.S3Config{
+		Endpoint:  srv.URL,
+		Region:    "eu-central-1",
+		Bucket:    "media",
+		AccessKey: "access",
+		SecretKey: "secret",
+		Prefix:    "european_honey",
+		PathStyle: true,
+	})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+
+	testDriver(t, s)
+
+	// a failed copy keeps the source
+	if _, err := s.Put("copy-fails.png", strings.NewReader("comb"), -1, "image/png"); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Rename("copy-fails.png", "moved.png"); err == nil {
+		t.Error("expected error from a failed copy")
+	}
+	if _, err := s.Stat("copy-fails.png"); err != nil {
+		t.Errorf("expected the source to be kept, got %v", err)
+	}
+}
+
+func TestS3Stall(t *testing.T) {
+	stop := make(chan struct{})
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// the object is found, but its content stops halfway
+		if r.Method == http.MethodGet && r.URL.Path == "/media/partial.png" {
+			w.Header().Set("Content-Length", "10")
+			_, _ = w.Write([]byte("12345"))
+			w.(http.Flusher).Flush()
+		}
+		if r.Method == http.MethodHead && r.URL.Path == "/media/partial.png" {
+			w.Header().Set("Content-Length", "10")
+			return
+		}
+		<-stop
+	}))
+	defer srv.Close()
+	defer close(stop)
+
+	s, err := storage.NewS3(storage.S3Config{
+		Endpoint:  srv.URL,
+		Bucket:    "media",
+		PathStyle: true,
+		Timeout:   50 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatalf("NewS3 failed: %v", err)
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := s.Stat("cover.png")
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error from a stalled endpoint")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("request to a stalled endpoint was not cancelled")
+	}
+
+	f, err := s.Open("partial.png")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	defer f.Close()
+	go func() {
+		_, err := io.ReadAll(f)
+		done <- err
+	}()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected error from a stalled download")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("stalled download was not cancelled")
+	}
+}
+
+func TestNewS3(t *testing.T) {
+	if _, err := storage.NewS3(storage.S3Config{Bucket: "media"}); err == nil {
+		t.Error("expected error without endpoint")
+	}
+	if _, err := storage.NewS3(storage.S3Config{Endpoint: "localhost:9000", Bucket: "media"}); err == nil {
+		t.Error("expected error for a relative endpoint")
+	}
+}
@@ -0,0 +1,133 @@
+// Package storage abstracts the place where media files
+// are kept, i.e. the local disk or an S3-compatible
+// object store
+package storage
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"path"
+	"strings"
+	"time"
+)
+
+// Errors shared by all drivers, they wrap the fs errors so
+// that errors.Is(err, fs.ErrNotExist) works as usual
+var (
+	ErrNotExist = fs.ErrNotExist
+	ErrExist    = fs.ErrExist
+	ErrInvalid  = errors.New("storage: invalid path")
+)
+
+// FileInfo - information about a stored file or folder
+type FileInfo struct {
+	Name    string
+	Size    int64
+	ModTime time.Time
+	IsDir   bool
+}
+
+// File - stored file opened for reading
+type File interface {
+	io.ReadSeekCloser
+}
+
+// Storage - operations every driver must support
+//
+// All names are slash-separated and relative to the root
+// of the storage, "" or "." being the root itself
+type Storage interface {
+	// Stat returns information about a file or folder
+	Stat(name string) (FileInfo, error)
+	// List returns the direct children of a folder
+	List(dir string) ([]FileInfo, error)
+	// Open opens a file for reading
+	Open(name string) (File, error)
+	// Put writes a file, replacing an existing one, size
+	// is -1 when unknown
+	Put(name string, r io.Reader, size int64, contentType string) (int64, error)
+	// Mkdir creates a folder, parent folders must exist
+	Mkdir(name string) error
+	// Rename moves a file or folder to a new name
+	Rename(oldName, newName string) error
+	// RemoveAll removes a file or folder with everything in it
+	RemoveAll(name string) error
+}
+
+// Clean - normalize a name and make sure it stays
+// inside the root of the storage
+func Clean(name string) (string, error) {
+	if strings.Contains(name, "\\") || strings.ContainsRune(name, 0) {
+		return "", ErrInvalid
+	}
+
+	name = strings.TrimPrefix(path.Clean("/"+name), "/")
+	if name == "" {
+		name = "."
+	}
+
+	return name, nil
+}
+
+// Copy - copy a file or folder with everything in it through
+// any driver, an existing target is never replaced
+func Copy(s Storage, src, dst string) error {
+	info, err := s.Stat(src)
+	if err != nil {
+		return err
+	}
+	if _, err := s.Stat(dst); err == nil {
+		return &fs.PathError{Op: "copy", Path: dst, Err: ErrExist}
+	}
+
+	if !info.IsDir {
+		if l, ok := s.(Linker); ok {
+			return l.Link(src, dst)
+		}
+
+		f, err := s.Open(src)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+
+		_, err = s.Put(dst, f, info.Size, "")
+		return err
+	}
+
+	if err := s.Mkdir(dst); err != nil {
+		return err
+	}
+	children, err := s.List(src)
+	if err != nil {
+		return err
+	}
+	for _, child := range children {
+		if err := Copy(s, path.Join(src, child.Name), path.Join(dst, child.Name)); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// MkdirAll - create a folder along with any missing parents
+func MkdirAll(s Storage, name string) error {
+	name, err := Clean(name)
+	if err != nil {
+		return err
+	}
+	if name == "." {
+		return nil
+	}
+
+	if err := MkdirAll(s, path.Dir(name)); err != nil {
+		return err
+	}
+	if err := s.Mkdir(name); err != nil && !errors.Is(err, fs.ErrExist) {
+		return err
+	}
+
+	return nil
+}
@@ -0,0 +1,173 @@
+package storage_test
+
+import (
+	"errors"
+	"io"
+	"io/fs"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib/storage"
+)
+
+func TestClean(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"", ".", false},
+		{"/", ".", false},
+		{"honey/jar.png", "honey/jar.png", false},
+		{"/honey//jar.png", "honey/jar.png", false},
+		{"../../etc/passwd", "etc/passwd", false},
+		{"honey\\..\\jar.png", "", true},
+	}
+
+	for _, tc := range testCases {
+		got, err := storage.Clean(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("storage.Clean(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("storage.Clean(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
+
+// testDriver runs the same scenario against any driver
+func testDriver(t *testing.T, s storage.Storage) {
+	t.Helper()
+
+	// folders
+	if err := s.Mkdir("honey"); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	if err := s.Mkdir("honey"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist for an existing folder, got %v", err)
+	}
+	if err := s.Mkdir("missing/child"); err == nil {
+		t.Error("expected error when the parent folder does not exist")
+	}
+
+	// files
+	content := "acacia honey, 500g"
+	n, err := s.Put("honey/jar.txt", strings.NewReader(content), -1, "text/plain")
+	if err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if n != int64(len(content)) {
+		t.Errorf("Put wrote %d bytes, want %d", n, len(content))
+	}
+
+	info, err := s.Stat("honey/jar.txt")
+	if err != nil {
+		t.Fatalf("Stat failed: %v", err)
+	}
+	if info.IsDir || info.Size != int64(len(content)) || info.Name != "jar.txt" {
+		t.Errorf("unexpected file info: %+v", info)
+	}
+	info, err = s.Stat("honey")
+	if err != nil || !info.IsDir {
+		t.Errorf("expected honey to be a folder, got %+v (err: %v)", info, err)
+	}
+	if _, err = s.Stat("honey/none.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected fs.ErrNotExist, got %v", err)
+	}
+
+	// reading and seeking
+	f, err := s.Open("honey/jar.txt")
+	if err != nil {
+		t.Fatalf("Open failed: %v", err)
+	}
+	if _, err = f.Seek(7, io.SeekStart); err != nil {
+		t.Fatalf("Seek failed: %v", err)
+	}
+	got, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if string(got) != content[7:] {
+		t.Errorf("read %q, want %q", got, content[7:])
+	}
+
+	// listing
+	if err := s.Mkdir("honey/wax"); err != nil {
+		t.Fatalf("Mkdir failed: %v", err)
+	}
+	list, err := s.List("honey")
+	if err != nil {
+		t.Fatalf("List failed: %v", err)
+	}
+	if len(list) != 2 || list[0].Name != "jar.txt" || list[1].Name != "wax" || !list[1].IsDir {
+		t.Errorf("unexpected listing: %+v", list)
+	}
+	root, err := s.List("")
+	if err != nil || len(root) != 1 || root[0].Name != "honey" {
+		t.Errorf("unexpected root listing: %+v (err: %v)", root, err)
+	}
+
+	// renaming
+	if err := s.Rename("honey", "bee"); err != nil {
+		t.Fatalf("Rename failed: %v", err)
+	}
+	if _, err := s.Stat("honey/jar.txt"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected old name to be gone, got %v", err)
+	}
+	if _, err := s.Stat("bee/jar.txt"); err != nil {
+		t.Errorf("expected file under the new name: %v", err)
+	}
+	if _, err := s.Stat("bee/wax"); err != nil {
+		t.Errorf("expected sub-folder under the new name: %v", err)
+	}
+	if _, err := s.Put("other.txt", strings.NewReader("x"), 1, ""); err != nil {
+		t.Fatalf("Put failed: %v", err)
+	}
+	if err := s.Rename("other.txt", "bee"); err == nil {
+		t.Error("expected error when renaming onto an existing name")
+	}
+
+	// copying
+	if err := storage.Copy(s, "bee", "hive"); err != nil {
+		t.Fatalf("Copy failed: %v", err)
+	}
+	if info, err := s.Stat("hive/jar.txt"); err != nil || info.Size != int64(len(content)) {
+		t.Errorf("expected copied file, got %+v (err: %v)", info, err)
+	}
+	if _, err := s.Stat("bee/jar.txt"); err != nil {
+		t.Errorf("expected source to be kept: %v", err)
+	}
+	if err := storage.Copy(s, "other.txt", "hive/jar.txt"); !errors.Is(err, fs.ErrExist) {
+		t.Errorf("expected fs.ErrExist when copying onto an existing name, got %v", err)
+	}
+	if err := s.RemoveAll("hive"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+
+	// creating parents
+	if err := storage.MkdirAll(s, "comb/cell/wax"); err != nil {
+		t.Fatalf("MkdirAll failed: %v", err)
+	}
+	if err := storage.MkdirAll(s, "comb/cell"); err != nil {
+		t.Errorf("MkdirAll failed on an existing folder: %v", err)
+	}
+	if info, err := s.Stat("comb/cell/wax"); err != nil || !info.IsDir {
+		t.Errorf("expected nested folder, got %+v (err: %v)", info, err)
+	}
+	if err := s.RemoveAll("comb"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+
+	// removing
+	if err := s.RemoveAll("bee"); err != nil {
+		t.Fatalf("RemoveAll failed: %v", err)
+	}
+	if _, err := s.Stat("bee/wax"); !errors.Is(err, fs.ErrNotExist) {
+		t.Errorf("expected folder to be removed, got %v", err)
+	}
+	if err := s.RemoveAll(""); err == nil {
+		t.Error("expected error when removing the root")
+	}
+}
@@ -0,0 +1,27 @@
+package lib
+
+import "strings"
+
+// MaxTagLength - longest tag accepted, longer ones are cut
+const MaxTagLength int = 64
+
+// NormalizeTags - trim, lowercase and de-duplicate a list of
+// tags keeping their order, empty tags are dropped
+func NormalizeTags(tags []string) []string {
+	seen := map[string]bool{}
+	normalized := []string{}
+
+	for _, tag := range tags {
+		tag = strings.ToLower(strings.Join(strings.Fields(tag), " "))
+		if len(tag) > MaxTagLength {
+			tag = strings.TrimSpace(strings.ToValidUTF8(tag[:MaxTagLength], ""))
+		}
+		if tag == "" || seen[tag] {
+			continue
+		}
+		seen[tag] = true
+		normalized = append(normalized, tag)
+	}
+
+	return normalized
+}
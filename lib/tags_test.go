@@ -0,0 +1,28 @@
+package lib_test
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestNormalizeTags(t *testing.T) {
+	testCases := []struct {
+		input []string
+		want  []string
+	}{
+		{nil, []string{}},
+		{[]string{"Honey", " honey ", "JAR"}, []string{"honey", "jar"}},
+		{[]string{"", "  ", "acacia   honey"}, []string{"acacia honey"}},
+		{[]string{strings.Repeat("a", 70)}, []string{strings.Repeat("a", lib.MaxTagLength)}},
+	}
+
+	for _, tc := range testCases {
+		got := lib.NormalizeTags(tc.input)
+		if !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("lib.NormalizeTags(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
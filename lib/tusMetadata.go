@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"encoding/base64"
+	"errors"
+	"strings"
+)
+
+// ErrInvalidTusMetadata - malformed Upload-Metadata header
+var ErrInvalidTusMetadata = errors.New("invalid Upload-Metadata")
+
+// ParseTusMetadata - decode the Upload-Metadata header of the
+// tus protocol: comma-separated pairs of a key and a base64
+// encoded value, the value may be left out
+func ParseTusMetadata(header string) (map[string]string, error) {
+	metadata := map[string]string{}
+
+	for _, pair := range strings.Split(header, ",") {
+		pair = strings.TrimSpace(pair)
+		if pair == "" {
+			continue
+		}
+
+		fields := strings.Fields(pair)
+		if len(fields) > 2 {
+			return nil, ErrInvalidTusMetadata
+		}
+		if _, ok := metadata[fields[0]]; ok {
+			return nil, ErrInvalidTusMetadata
+		}
+
+		value := []byte{}
+		if len(fields) == 2 {
+			var err error
+			value, err = base64.StdEncoding.DecodeString(fields[1])
+			if err != nil {
+				return nil, ErrInvalidTusMetadata
+			}
+		}
+		metadata[fields[0]] = string(value)
+	}
+
+	return metadata, nil
+}
@@ -0,0 +1,34 @@
+package lib_test
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestParseTusMetadata(t *testing.T) {
+	testCases := []struct {
+		input   string
+		want    map[string]string
+		wantErr bool
+	}{
+		{"", map[string]string{}, false},
+		{"filename aG9uZXkuanBn", map[string]string{"filename": "honey.jpg"}, false},
+		{"filename aG9uZXkuanBn, folder c2hvcC9qYXJz,private", map[string]string{"filename": "honey.jpg", "folder": "shop/jars", "private": ""}, false},
+		{"filename not-base64!", nil, true},
+		{"filename aG9uZXkuanBn extra", nil, true},
+		{"private,private", nil, true},
+	}
+
+	for _, tc := range testCases {
+		got, err := lib.ParseTusMetadata(tc.input)
+		if (err != nil) != tc.wantErr {
+			t.Errorf("lib.ParseTusMetadata(%q) error = %v, wantErr %v", tc.input, err, tc.wantErr)
+			continue
+		}
+		if !tc.wantErr && !reflect.DeepEqual(got, tc.want) {
+			t.Errorf("lib.ParseTusMetadata(%q) = %v, want %v", tc.input, got, tc.want)
+		}
+	}
+}
@@ -0,0 +1,44 @@
+package lib
+
+import (
+	"errors"
+	"strings"
+)
+
+// ErrZipSlip - a ZIP entry pointing outside of the folder it
+// is extracted into
+var ErrZipSlip = errors.New("zip entry outside of the target folder")
+
+// ZipEntryPath - clean slash-separated path of a ZIP entry
+// relative to the folder it is extracted into
+//
+// Unlike SafeJoin, an escaping entry is never fixed up: an
+// archive built to escape is rejected as a whole
+func ZipEntryPath(name string) (string, error) {
+	// archives made on Windows may use backslashes
+	name = strings.ReplaceAll(name, "\\", "/")
+
+	if strings.ContainsRune(name, 0) || strings.HasPrefix(name, "/") {
+		return "", ErrZipSlip
+	}
+	// drive letters, i.e. C:/Windows
+	if len(name) > 1 && name[1] == ':' {
+		return "", ErrZipSlip
+	}
+
+	parts := []string{}
+	for _, part := range strings.Split(name, "/") {
+		switch part {
+		case "", ".":
+			continue
+		case "..":
+			return "", ErrZipSlip
+		}
+		parts = append(parts, part)
+	}
+	if len(parts) == 0 {
+		return "", ErrZipSlip
+	}
+
+	return strings.Join(parts, "/"), nil
+}
@@ -0,0 +1,41 @@
+package lib_test
+
+import (
+	"testing"
+
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+func TestZipEntryPath(t *testing.T) {
+	testCases := []struct {
+		input string
+		want  string
+		err   error
+	}{
+		{"jar.png", "jar.png", nil},
+		{"honey/jar.png", "honey/jar.png", nil},
+		{"honey/", "honey", nil},
+		{"./honey//jar.png", "honey/jar.png", nil},
+		{"honey\\jar.png", "honey/jar.png", nil},
+		{"", "", lib.ErrZipSlip},
+		{"./", "", lib.ErrZipSlip},
+		{"/etc/passwd", "", lib.ErrZipSlip},
+		{"../jar.png", "", lib.ErrZipSlip},
+		{"honey/../../jar.png", "", lib.ErrZipSlip},
+		{"honey/../jar.png", "", lib.ErrZipSlip},
+		{"..\\..\\jar.png", "", lib.ErrZipSlip},
+		{"C:/Windows/jar.png", "", lib.ErrZipSlip},
+		{"jar\x00.png", "", lib.ErrZipSlip},
+	}
+
+	for _, tc := range testCases {
+		got, err := lib.ZipEntryPath(tc.input)
+		if err != tc.err {
+			t.Errorf("lib.ZipEntryPath(%q) returned error %v, want %v", tc.input, err, tc.err)
+			continue
+		}
+		if got != tc.want {
+			t.Errorf("lib.ZipEntryPath(%q) = %q, want %q", tc.input, got, tc.want)
+		}
+	}
+}
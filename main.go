@@ -13,6 +13,7 @@ import (
 
 	gconfig "github.com/tinkerbaj/gintemp/config"
 	gdatabase "github.com/tinkerbaj/gintemp/database"
+	gservice "github.com/tinkerbaj/gintemp/service"
 
 	"github.com/tinkerbaj/gintemp/database/migrate"
 	"github.com/tinkerbaj/gintemp/router"
@@ -65,6 +66,29 @@ func main() {
 		}
 	}
 
+	if gconfig.IsRDBMS() {
+		// Initialize media storage
+		if _, err := gdatabase.InitStorage(); err != nil {
+			fmt.Println(err)
+			return
+		}
+
+		// Generate image variants in the background
+		gservice.StartVariantWorkers(configure.Media.VariantWorkers)
+
+		// Empty the media trash after the retention period
+		gservice.StartTrashPurge(configure.Media.TrashRetention, configure.Media.TrashPurgeInterval)
+
+		// Drop resumable uploads which were never completed
+		gservice.StartUploadCleanup(configure.Media.UploadCleanupInterval)
+
+		// Publish scheduled posts when they are due
+		gservice.StartPostScheduler(time.Minute)
+
+		// Write the buffered views of the posts in batches
+		gservice.StartPostViewFlush(configure.Post.ViewFlushInterval)
+	}
+
 	r, err := router.SetupRouter(configure)
 	if err != nil {
 		fmt.Println(err)
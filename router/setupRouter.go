@@ -132,11 +132,22 @@ func SetupRouter(configure *gconfig.Configuration) (*gin.Engine, error) {
 		r.Use(gmiddleware.Pongo2(configure.ViewConfig.Directory))
 	}
 
-	r.Static("/assets", "./public")
+	// private media and the hidden folders of the media
+	// library are only served through the media API
+	rAssets := r.Group("/assets")
+	rAssets.Use(gservice.AssetsGuard())
+	rAssets.Static("", "./public")
 
 	// API Status
 	r.GET("", controller.APIStatus)
 
+	// crawlers
+	r.GET("/robots.txt", controller.GetRobots)
+	if gconfig.IsRDBMS() && gconfig.IsSiteURL() {
+		r.GET("/sitemap.xml", controller.GetSitemapIndex)
+		r.GET("/sitemaps/:name", controller.GetSitemap)
+	}
+
 	// API:v1.0
 	v1 := r.Group("/api/v1/")
 	{
@@ -244,16 +255,59 @@ func SetupRouter(configure *gconfig.Configuration) (*gin.Engine, error) {
 			rUsers.PUT("", controller.UpdateUser)       // Protected
 			rUsers.PUT("/hobbies", controller.AddHobby) // Protected
 
+			// identifies the caller on the public read routes,
+			// tokens still waiting for the OTP stay anonymous
+			optionalJWT := gmiddleware.OptionalJWT("", "")
+			if gconfig.Is2FA() {
+				optionalJWT = gmiddleware.OptionalJWT(
+					configure.Security.TwoFA.Status.Off,
+					configure.Security.TwoFA.Status.Verified,
+				)
+			}
 
 			// Media
 			rMedia := v1.Group("media")
-			rMedia.GET("", controller.GetMedia)    // Non-protected
-			rMedia.GET("/create", controller.CreateFolder)   // Non-protected
-			rMedia.GET("/rename", controller.RenameFolder)   // Non-protected
+			// owners also see their own private files
+			rMediaRead := rMedia.Group("", optionalJWT, gservice.JWTBlacklistChecker())
+			rMediaRead.GET("", controller.GetMedia)                            // Non-protected
+			rMedia.GET("/variants/:variant/*path", controller.GetMediaVariant) // Non-protected
+			rMedia.GET("/download/*path", controller.DownloadMedia)            // Non-protected
+			rMedia.OPTIONS("/tus", controller.TusOptions)                      // Non-protected
+			rMedia.Use(gmiddleware.JWT()).Use(gservice.JWTBlacklistChecker())
+			if gconfig.Is2FA() {
+				rMedia.Use(gmiddleware.TwoFA(
+					configure.Security.TwoFA.Status.On,
+					configure.Security.TwoFA.Status.Off,
+					configure.Security.TwoFA.Status.Verified,
+				))
+			}
+			rMedia.POST("/create", controller.CreateFolder)            // Protected
+			rMedia.POST("/rename", controller.RenameFolder)            // Protected
+			rMedia.POST("/upload", controller.UploadMedia)             // Protected
+			rMedia.POST("/import", controller.ImportMedia)             // Protected
+			rMedia.GET("/export", controller.ExportMedia)              // Protected
+			rMedia.PUT("/meta", controller.UpdateMediaMeta)            // Protected
+			rMedia.POST("/sign", controller.SignMediaURL)              // Protected
+			rMedia.POST("/move", controller.MoveMedia)                 // Protected
+			rMedia.POST("/copy", controller.CopyMedia)                 // Protected
+			rMedia.DELETE("", controller.DeleteMedia)                  // Protected
+			rMedia.GET("/trash", controller.GetMediaTrash)             // Protected
+			rMedia.POST("/trash/:id/restore", controller.RestoreMedia) // Protected
+			rMedia.GET("/usage", controller.GetMediaUsage)             // Protected
+			rMedia.PUT("/quota/:userID", controller.SetMediaQuota)     // Protected
+			rMedia.POST("/tus", controller.CreateMediaUpload)          // Protected
+			rMedia.HEAD("/tus/:id", controller.GetMediaUpload)         // Protected
+			rMedia.PATCH("/tus/:id", controller.PatchMediaUpload)      // Protected
+			rMedia.DELETE("/tus/:id", controller.DeleteMediaUpload)    // Protected
+
 			// Post
 			rPosts := v1.Group("posts")
-			rPosts.GET("", controller.GetPosts)    // Non-protected
-			rPosts.GET("/:id", controller.GetPost) // Non-protected
+			// authors also see their own drafts
+			rPostsRead := rPosts.Group("", optionalJWT, gservice.JWTBlacklistChecker())
+			rPostsRead.GET("", controller.GetPosts)                 // Non-protected
+			rPostsRead.GET("/search", controller.SearchPosts)       // Non-protected
+			rPostsRead.GET("/:id", controller.GetPost)              // Non-protected
+			rPostsRead.GET("/:id/comments", controller.GetComments) // Non-protected
 			rPosts.Use(gmiddleware.JWT()).Use(gservice.JWTBlacklistChecker())
 			if gconfig.Is2FA() {
 				rPosts.Use(gmiddleware.TwoFA(
@@ -262,9 +316,67 @@ func SetupRouter(configure *gconfig.Configuration) (*gin.Engine, error) {
 					configure.Security.TwoFA.Status.Verified,
 				))
 			}
-			rPosts.POST("", controller.CreatePost)       // Protected
-			rPosts.PUT("/:id", controller.UpdatePost)    // Protected
-			rPosts.DELETE("/:id", controller.DeletePost) // Protected
+			rPosts.POST("", controller.CreatePost)                                        // Protected
+			rPosts.PUT("/:id", controller.UpdatePost)                                     // Protected
+			rPosts.DELETE("/:id", controller.DeletePost)                                  // Protected
+			rPosts.GET("/:id/revisions", controller.GetPostRevisions)                     // Protected
+			rPosts.GET("/:id/revisions/diff", controller.DiffPostRevisions)               // Protected
+			rPosts.POST("/:id/revisions/:number/restore", controller.RestorePostRevision) // Protected
+			rPosts.POST("/:id/comments", controller.CreateComment)                        // Protected
+			rPosts.PUT("/:id/reaction", controller.SetPostReaction)                       // Protected
+			rPosts.DELETE("/:id/reaction", controller.DeletePostReaction)                 // Protected
+
+			// Feed
+			if gconfig.IsSiteURL() {
+				rFeeds := v1.Group("feeds")
+				rFeeds.GET("/:format", controller.GetFeed) // Non-protected
+			}
+
+			// Comment
+			rComments := v1.Group("comments")
+			rComments.Use(gmiddleware.JWT()).Use(gservice.JWTBlacklistChecker())
+			if gconfig.Is2FA() {
+				rComments.Use(gmiddleware.TwoFA(
+					configure.Security.TwoFA.Status.On,
+					configure.Security.TwoFA.Status.Off,
+					configure.Security.TwoFA.Status.Verified,
+				))
+			}
+			rComments.GET("/moderation", controller.GetCommentQueue)  // Protected
+			rComments.PUT("/:id", controller.UpdateComment)           // Protected
+			rComments.DELETE("/:id", controller.DeleteComment)        // Protected
+			rComments.POST("/:id/approve", controller.ApproveComment) // Protected
+			rComments.POST("/:id/reject", controller.RejectComment)   // Protected
+
+			// Category
+			rCategories := v1.Group("categories")
+			rCategories.GET("", controller.GetCategories) // Non-protected
+			rCategories.Use(gmiddleware.JWT()).Use(gservice.JWTBlacklistChecker())
+			if gconfig.Is2FA() {
+				rCategories.Use(gmiddleware.TwoFA(
+					configure.Security.TwoFA.Status.On,
+					configure.Security.TwoFA.Status.Off,
+					configure.Security.TwoFA.Status.Verified,
+				))
+			}
+			rCategories.POST("", controller.CreateCategory)       // Protected
+			rCategories.PUT("/:id", controller.UpdateCategory)    // Protected
+			rCategories.DELETE("/:id", controller.DeleteCategory) // Protected
+
+			// Tag
+			rTags := v1.Group("tags")
+			rTags.GET("", controller.GetTags) // Non-protected
+			rTags.Use(gmiddleware.JWT()).Use(gservice.JWTBlacklistChecker())
+			if gconfig.Is2FA() {
+				rTags.Use(gmiddleware.TwoFA(
+					configure.Security.TwoFA.Status.On,
+					configure.Security.TwoFA.Status.Off,
+					configure.Security.TwoFA.Status.Verified,
+				))
+			}
+			rTags.POST("", controller.CreateTag)       // Protected
+			rTags.PUT("/:id", controller.UpdateTag)    // Protected
+			rTags.DELETE("/:id", controller.DeleteTag) // Protected
 
 			// Hobby
 			rHobbies := v1.Group("hobbies")
@@ -0,0 +1,29 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// IsAdmin returns true for admins, unknown users are no admins
+func IsAdmin(userID uint) (bool, error) {
+	return isAdmin(database.GetDB(), userID)
+}
+
+// isAdmin - IsAdmin reading through db, which may be a transaction
+func isAdmin(db *gorm.DB, userID uint) (bool, error) {
+	user := model.User{}
+	err := db.Select("id", "is_admin").Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return false, nil
+		}
+		return false, err
+	}
+
+	return user.IsAdmin, nil
+}
@@ -0,0 +1,27 @@
+package service
+
+import (
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// IsCommentAdmin returns true when the user moderates the
+// comments of every post
+func IsCommentAdmin(userID uint) (bool, error) {
+	return IsAdmin(userID)
+}
+
+// CanModerateComments returns true when the user may approve,
+// reject and delete the comments of the post: admins and the
+// author of the post
+func CanModerateComments(userID, postID uint) (bool, error) {
+	admin, err := IsCommentAdmin(userID)
+	if err != nil || admin {
+		return admin, err
+	}
+
+	var count int64
+	err = database.GetDB().Model(&model.Post{}).Where("id = ? AND user_id = ?", postID, userID).Count(&count).Error
+
+	return count > 0, err
+}
@@ -0,0 +1,190 @@
+package service
+
+import (
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// mediaChain - path of an entry followed by the paths of all
+// folders containing it
+func mediaChain(p string) []string {
+	paths := []string{}
+	for p != "." && p != "/" && p != "" {
+		paths = append(paths, p)
+		p = path.Dir(p)
+	}
+	return paths
+}
+
+// IsPrivateMedia returns true when the entry or one of the
+// folders containing it is marked private
+//
+// db may be a transaction, the entries are read in its snapshot
+func IsPrivateMedia(db *gorm.DB, p string) (bool, error) {
+	paths := mediaChain(p)
+	if len(paths) == 0 {
+		return false, nil
+	}
+
+	var count int64
+	err := db.Model(&model.Media{}).Where("path IN ? AND private = ?", paths, true).Count(&count).Error
+
+	return count > 0, err
+}
+
+// PrivateMedia - those of the entries which are private
+// themselves or in a private folder, db as in IsPrivateMedia
+func PrivateMedia(db *gorm.DB, paths []string) (map[string]bool, error) {
+	private := map[string]bool{}
+
+	chains := map[string][]string{}
+	all := []string{}
+	for _, p := range paths {
+		chains[p] = mediaChain(p)
+		all = append(all, chains[p]...)
+	}
+	if len(all) == 0 {
+		return private, nil
+	}
+
+	marked := []string{}
+	err := db.Model(&model.Media{}).Where("path IN ? AND private = ?", all, true).Pluck("path", &marked).Error
+	if err != nil {
+		return nil, err
+	}
+	isMarked := map[string]bool{}
+	for _, p := range marked {
+		isMarked[p] = true
+	}
+
+	for p, chain := range chains {
+		for _, entry := range chain {
+			if isMarked[entry] {
+				private[p] = true
+				break
+			}
+		}
+	}
+
+	return private, nil
+}
+
+// CanManageMedia returns true when the user may change the
+// entry: admins may change everything, a shop everything in
+// its namespace and nothing outside of it, other users what
+// they own and everything inside the folders they own, but
+// nothing in the namespaces of the shops
+//
+// Users other than shops may add entries to the root folder ("").
+// db as in IsPrivateMedia
+func CanManageMedia(db *gorm.DB, userID uint, p string) (bool, error) {
+	if userID == 0 {
+		return false, nil
+	}
+
+	admin, err := isMediaAdmin(db, userID)
+	if err != nil || admin {
+		return admin, err
+	}
+
+	namespace, err := mediaNamespace(db, userID)
+	if err != nil {
+		return false, err
+	}
+	switch {
+	case namespace != "":
+		return InMediaNamespace(p, namespace), nil
+	case InMediaNamespace(p, config.GetConfig().Media.ShopDir):
+		return false, nil
+	}
+
+	paths := mediaChain(p)
+	if len(paths) == 0 {
+		return true, nil
+	}
+
+	var count int64
+	err = db.Model(&model.Media{}).Where("path IN ? AND owner_id = ?", paths, userID).Count(&count).Error
+
+	return count > 0, err
+}
+
+// IsMediaAdmin returns true when the user manages the whole
+// media library
+func IsMediaAdmin(userID uint) (bool, error) {
+	return isMediaAdmin(database.GetDB(), userID)
+}
+
+// isMediaAdmin - IsMediaAdmin reading through db
+func isMediaAdmin(db *gorm.DB, userID uint) (bool, error) {
+	return isAdmin(db, userID)
+}
+
+// AssetsGuard keeps the static file server from handing out
+// hidden entries and private media, both are only reachable
+// through the media API, the other files of the media
+// library are served from the media storage
+func AssetsGuard() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		p := path.Clean("/" + c.Request.URL.Path)
+
+		for _, part := range strings.Split(p, "/") {
+			if strings.HasPrefix(part, ".") {
+				c.AbortWithStatus(http.StatusNotFound)
+				return
+			}
+		}
+
+		// S3 keeps nothing under /assets
+		if !config.IsRDBMS() || config.GetConfig().Media.Storage == "s3" {
+			c.Next()
+			return
+		}
+
+		prefix := database.MediaAssetsPrefix()
+		if prefix == "" || !strings.HasPrefix(p+"/", prefix) {
+			c.Next()
+			return
+		}
+
+		name := strings.TrimPrefix(p, prefix)
+		private, err := IsPrivateMedia(database.GetDB(), name)
+		if err != nil {
+			log.WithError(err).Error("error code: 1494")
+			c.AbortWithStatus(http.StatusInternalServerError)
+			return
+		}
+		if private {
+			c.AbortWithStatus(http.StatusNotFound)
+			return
+		}
+
+		// files of the media library are read through the
+		// storage, a deduplicated file only points at its content
+		s := database.GetStorage()
+		info, err := s.Stat(name)
+		if err != nil || info.IsDir {
+			c.Next()
+			return
+		}
+		file, err := s.Open(name)
+		if err != nil {
+			c.Next()
+			return
+		}
+		defer file.Close()
+
+		c.Header("X-Content-Type-Options", "nosniff")
+		http.ServeContent(c.Writer, c.Request, info.Name, info.ModTime, file)
+		c.Abort()
+	}
+}
@@ -0,0 +1,94 @@
+package service
+
+import (
+	"errors"
+	"path"
+	"strconv"
+	"strings"
+
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib/storage"
+)
+
+// MediaNamespace - folder a shop keeps its media in, "" for
+// users which are no shop
+func MediaNamespace(userID uint) (string, error) {
+	return mediaNamespace(database.GetDB(), userID)
+}
+
+// mediaNamespace - MediaNamespace reading through db, which
+// may be a transaction
+func mediaNamespace(db *gorm.DB, userID uint) (string, error) {
+	user := model.User{}
+	err := db.Select("id", "is_shop").Where("id = ?", userID).First(&user).Error
+	if err != nil {
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			return "", nil
+		}
+		return "", err
+	}
+	if !user.IsShop {
+		return "", nil
+	}
+
+	return shopNamespace(userID), nil
+}
+
+// shopNamespace - folder of the media of a shop
+func shopNamespace(userID uint) string {
+	return config.GetConfig().Media.ShopDir + "/" + strconv.FormatUint(uint64(userID), 10)
+}
+
+// InMediaNamespace returns true when p is the namespace or
+// lies inside it
+func InMediaNamespace(p, namespace string) bool {
+	return p == namespace || strings.HasPrefix(p, namespace+"/")
+}
+
+// IsMediaNamespace returns true for the namespace of a shop
+// and the folder keeping all of them
+func IsMediaNamespace(p string) bool {
+	shopDir := config.GetConfig().Media.ShopDir
+	if p == shopDir {
+		return true
+	}
+	if path.Dir(p) != shopDir {
+		return false
+	}
+
+	_, err := strconv.ParseUint(path.Base(p), 10, 64)
+	return err == nil
+}
+
+// EnsureMediaNamespace - create the namespace of a shop on
+// first use, the shop owns it like any folder it created
+func EnsureMediaNamespace(userID uint) (string, error) {
+	namespace, err := MediaNamespace(userID)
+	if err != nil || namespace == "" {
+		return namespace, err
+	}
+
+	s := database.GetStorage()
+	if info, err := s.Stat(namespace); err == nil && info.IsDir {
+		return namespace, nil
+	}
+
+	if err := storage.MkdirAll(s, namespace); err != nil {
+		return "", err
+	}
+
+	entry := model.Media{
+		OwnerID:  userID,
+		Name:     path.Base(namespace),
+		Folder:   path.Dir(namespace),
+		Path:     namespace,
+		IsFolder: true,
+	}
+	err = database.GetDB().Where("path = ?", namespace).Assign(model.Media{OwnerID: userID}).FirstOrCreate(&entry).Error
+
+	return namespace, err
+}
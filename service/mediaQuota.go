@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// GetMediaQuota - storage quota of a user in bytes, 0 for no
+// limit, the media_quota table takes precedence over the
+// configured defaults for users and shops
+func GetMediaQuota(userID uint) (int64, error) {
+	db := database.GetDB()
+
+	quota := model.MediaQuota{}
+	err := db.Where("user_id = ?", userID).First(&quota).Error
+	if err == nil {
+		return quota.Quota, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+
+	configMedia := config.GetConfig().Media
+
+	user := model.User{}
+	err = db.Select("id", "is_shop").Where("id = ?", userID).First(&user).Error
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return 0, err
+	}
+	if user.IsShop {
+		return configMedia.ShopQuota, nil
+	}
+
+	return configMedia.UserQuota, nil
+}
+
+// GetMediaUsage - storage used by the files a user owns
+func GetMediaUsage(userID uint) (model.MediaUsage, error) {
+	db := database.GetDB()
+	usage := model.MediaUsage{UserID: userID}
+
+	sum := struct {
+		Files int64
+		Size  int64
+	}{}
+	owned := db.Model(&model.Media{}).
+		Select("COUNT(*) AS files, COALESCE(SUM(size), 0) AS size").
+		Where("owner_id = ? AND is_folder = ?", userID, false)
+
+	if err := owned.Session(&gorm.Session{}).Where("path NOT LIKE ?", TrashDir+"/%").Scan(&sum).Error; err != nil {
+		return usage, err
+	}
+	usage.Files, usage.Used = sum.Files, sum.Size
+
+	if err := owned.Session(&gorm.Session{}).Where("path LIKE ?", TrashDir+"/%").Scan(&sum).Error; err != nil {
+		return usage, err
+	}
+	usage.Trashed = sum.Size
+
+	var err error
+	usage.Quota, err = GetMediaQuota(userID)
+
+	return usage, err
+}
+
+// MediaQuotaExceeded returns true when storing size more
+// bytes would take the user over the quota
+func MediaQuotaExceeded(userID uint, size int64) (bool, error) {
+	usage, err := GetMediaUsage(userID)
+	if err != nil || usage.Quota == 0 {
+		return false, err
+	}
+
+	return usage.Used+usage.Trashed+size > usage.Quota, nil
+}
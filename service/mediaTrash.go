@@ -0,0 +1,91 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"path"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// TrashDir - hidden folder at the root of the storage keeping
+// deleted entries until they are restored or purged
+const TrashDir string = ".trash"
+
+// StartTrashPurge - periodically remove the entries which
+// have been in the trash longer than the retention period
+func StartTrashPurge(retention, interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := PurgeTrash(time.Now().Add(-retention)); err != nil {
+				log.WithError(err).Error("error code: 1461")
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// PurgeTrash - permanently remove everything deleted before
+// the given time
+//
+// An entry which cannot be removed is logged and left for the
+// next run, the others are purged all the same
+func PurgeTrash(before time.Time) error {
+	db := database.GetDB()
+
+	trashed := []model.MediaTrash{}
+	if err := db.Where("created_at < ?", before).Find(&trashed).Error; err != nil {
+		return err
+	}
+
+	failed := []error{}
+	for _, t := range trashed {
+		// every entry has its own folder in the trash
+		err := database.GetStorage().RemoveAll(path.Dir(t.TrashPath))
+		if err != nil && !errors.Is(err, fs.ErrNotExist) {
+			log.WithError(err).WithField("path", t.TrashPath).Error("error code: 1461.1")
+			failed = append(failed, err)
+			continue
+		}
+
+		err = db.Transaction(func(tx *gorm.DB) error {
+			if err := RemoveMediaEntries(tx, t.TrashPath); err != nil {
+				return err
+			}
+			return tx.Delete(&t).Error
+		})
+		if err != nil {
+			log.WithError(err).WithField("path", t.TrashPath).Error("error code: 1461.2")
+			failed = append(failed, err)
+		}
+	}
+
+	return errors.Join(failed...)
+}
+
+// RemoveMediaEntries - delete the stored metadata of a file or
+// folder and everything in it
+func RemoveMediaEntries(tx *gorm.DB, p string) error {
+	ids := tx.Model(&model.Media{}).Select("id").Where("path = ? OR path LIKE ?", p, p+"/%")
+	if err := tx.Where("media_id IN (?)", ids).Delete(&model.MediaTag{}).Error; err != nil {
+		return err
+	}
+	// posts no longer show them
+	if err := tx.Where("media_id IN (?)", ids).Delete(&model.PostMedia{}).Error; err != nil {
+		return err
+	}
+
+	return tx.Where("path = ? OR path LIKE ?", p, p+"/%").Delete(&model.Media{}).Error
+}
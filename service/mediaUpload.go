@@ -0,0 +1,104 @@
+package service
+
+import (
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// uploadLocks - uploads which a request is busy with, an
+// entry lives only as long as the request holds it
+var uploadLocks = struct {
+	sync.Mutex
+	busy map[string]bool
+}{busy: map[string]bool{}}
+
+// LockUpload returns false when another request is busy with
+// the upload, the caller must call UnlockUpload otherwise
+func LockUpload(id string) bool {
+	uploadLocks.Lock()
+	defer uploadLocks.Unlock()
+
+	if uploadLocks.busy[id] {
+		return false
+	}
+	uploadLocks.busy[id] = true
+	return true
+}
+
+// UnlockUpload - release the lock taken by LockUpload
+func UnlockUpload(id string) {
+	uploadLocks.Lock()
+	delete(uploadLocks.busy, id)
+	uploadLocks.Unlock()
+}
+
+// UploadFile - local file keeping the data of a resumable upload
+func UploadFile(id string) string {
+	return filepath.Join(config.GetConfig().Media.UploadDir, id)
+}
+
+// StartUploadCleanup - periodically drop the resumable
+// uploads which expired without being completed
+func StartUploadCleanup(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			if err := CleanupUploads(time.Now()); err != nil {
+				log.WithError(err).Error("error code: 1462")
+			}
+			<-ticker.C
+		}
+	}()
+}
+
+// CleanupUploads - drop the uploads which expired before the
+// given time along with their data
+//
+// Uploads a request is busy with are left for the next round
+func CleanupUploads(now time.Time) error {
+	db := database.GetDB()
+
+	expired := []model.MediaUpload{}
+	if err := db.Where("expires_at < ?", now).Find(&expired).Error; err != nil {
+		return err
+	}
+
+	for _, upload := range expired {
+		if !LockUpload(upload.ID) {
+			continue
+		}
+		err := RemoveUpload(upload)
+		UnlockUpload(upload.ID)
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// RemoveUpload - drop a resumable upload along with its data
+func RemoveUpload(upload model.MediaUpload) error {
+	err := os.Remove(UploadFile(upload.ID))
+	if err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+
+	return database.GetDB().Delete(&upload).Error
+}
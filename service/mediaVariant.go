@@ -0,0 +1,267 @@
+package service
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"image"
+	"io"
+	"io/fs"
+	"net/url"
+	"path"
+	"strings"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+	"github.com/tinkerbaj/gintemp/lib"
+)
+
+// VariantURLPrefix - public route serving the image variants
+const VariantURLPrefix string = "/api/v1/media/variants/"
+
+// DownloadURLPrefix - public route serving the originals
+const DownloadURLPrefix string = "/api/v1/media/download/"
+
+// variantManifest - written last, lists the generated variants
+const variantManifest string = "manifest.json"
+
+// variantQueue - originals waiting for their variants
+var variantQueue chan string
+
+// StartVariantWorkers - start the background workers which
+// generate the resized copies of uploaded images
+func StartVariantWorkers(workers int) {
+	if workers <= 0 || variantQueue != nil {
+		return
+	}
+
+	variantQueue = make(chan string, 256)
+	for i := 0; i < workers; i++ {
+		go func() {
+			for p := range variantQueue {
+				if err := GenerateVariants(p); err != nil {
+					log.WithError(err).Error("error code: 1451")
+				}
+			}
+		}()
+	}
+}
+
+// QueueVariants - schedule the variants of an image without
+// blocking, returns false when they could not be scheduled
+func QueueVariants(p string, mimeType string) bool {
+	if variantQueue == nil || !IsVariantSource(mimeType) {
+		return false
+	}
+
+	select {
+	case variantQueue <- p:
+		return true
+	default:
+		log.WithField("path", p).Warn("variant queue is full")
+		return false
+	}
+}
+
+// IsVariantSource returns true for image types the
+// pipeline can decode
+func IsVariantSource(mimeType string) bool {
+	switch mimeType {
+	case "image/jpeg", "image/png", "image/gif", "image/webp":
+		return true
+	}
+	return false
+}
+
+// VariantDir - hidden folder next to the original keeping
+// its variants, i.e. honey/.variants/jar.jpg
+func VariantDir(p string) string {
+	return path.Join(path.Dir(p), ".variants", path.Base(p))
+}
+
+// VariantURL - stable URL of one variant of an original
+func VariantURL(p, name, ext string) string {
+	id := name
+	if ext == ".webp" {
+		id += ext
+	}
+
+	return VariantURLPrefix + id + "/" + EscapeMediaPath(p)
+}
+
+// DownloadURL - stable URL of an original
+func DownloadURL(p string) string {
+	return DownloadURLPrefix + EscapeMediaPath(p)
+}
+
+// EscapeMediaPath - escape every segment of a path for the
+// use in a URL, keeping the slashes
+func EscapeMediaPath(p string) string {
+	segments := strings.Split(p, "/")
+	for i := range segments {
+		segments[i] = url.PathEscape(segments[i])
+	}
+
+	return strings.Join(segments, "/")
+}
+
+// VariantFile - stored file and MIME type of a variant,
+// id is the variant name optionally followed by .webp
+func VariantFile(p, id string) (string, string, error) {
+	variants, err := ReadVariants(p)
+	if err != nil {
+		return "", "", err
+	}
+
+	name := strings.TrimSuffix(id, ".webp")
+	webp := name != id
+	for _, v := range variants {
+		if v.Name == name && (v.MimeType == "image/webp") == webp {
+			return path.Join(VariantDir(p), v.Name+variantExt(v.MimeType)), v.MimeType, nil
+		}
+	}
+
+	return "", "", fs.ErrNotExist
+}
+
+// ReadVariants - variants recorded for an original
+func ReadVariants(p string) ([]model.MediaVariant, error) {
+	f, err := database.GetStorage().Open(path.Join(VariantDir(p), variantManifest))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	variants := []model.MediaVariant{}
+	if err := json.NewDecoder(f).Decode(&variants); err != nil {
+		return nil, err
+	}
+	SetVariantURLs(p, variants)
+
+	return variants, nil
+}
+
+// SetVariantURLs - point the URLs of the variants at the
+// current path of the original, which may have been renamed
+// since the variants were generated
+func SetVariantURLs(p string, variants []model.MediaVariant) {
+	for i := range variants {
+		variants[i].URL = VariantURL(p, variants[i].Name, variantExt(variants[i].MimeType))
+	}
+}
+
+// GenerateVariants - create every configured variant of an
+// image, the original is never modified
+//
+// Images with more than MEDIA_MAX_PIXELS pixels are skipped
+// without decoding them
+func GenerateVariants(p string) error {
+	configMedia := config.GetConfig().Media
+	s := database.GetStorage()
+
+	f, err := s.Open(p)
+	if err != nil {
+		return err
+	}
+	cfg, err := lib.CheckImagePixels(f, configMedia.MaxPixels)
+	if errors.Is(err, lib.ErrTooManyPixels) {
+		f.Close()
+		log.WithFields(log.Fields{"path": p, "width": cfg.Width, "height": cfg.Height}).
+			Warn("image has too many pixels, no variants generated")
+		return nil
+	}
+	if err == nil {
+		_, err = f.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		f.Close()
+		return err
+	}
+	data, err := io.ReadAll(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	img, format, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+
+	// originals keeping their metadata are turned only here
+	img = lib.OrientImage(img, lib.ReadImageMeta(data, "image/"+format).Orientation)
+
+	// photos stay JPEG, everything else becomes PNG
+	outFormat := "png"
+	if format == "jpeg" {
+		outFormat = "jpeg"
+	}
+
+	dir := VariantDir(p)
+	for _, d := range []string{path.Dir(dir), dir} {
+		if err := s.Mkdir(d); err != nil && !errors.Is(err, fs.ErrExist) {
+			return err
+		}
+	}
+
+	formats := []string{outFormat}
+	if configMedia.VariantWebP {
+		formats = append(formats, "webp")
+	}
+
+	variants := []model.MediaVariant{}
+	for _, v := range configMedia.Variants {
+		resized := lib.ResizeToFit(img, v.MaxSize)
+
+		for _, format := range formats {
+			buf := bytes.Buffer{}
+			if err := lib.EncodeImage(&buf, resized, format); err != nil {
+				return err
+			}
+
+			mimeType := "image/" + format
+			ext := variantExt(mimeType)
+			size, err := s.Put(path.Join(dir, v.Name+ext), &buf, int64(buf.Len()), mimeType)
+			if err != nil {
+				return err
+			}
+
+			variants = append(variants, model.MediaVariant{
+				Name:     v.Name,
+				MimeType: mimeType,
+				Width:    resized.Bounds().Dx(),
+				Height:   resized.Bounds().Dy(),
+				Size:     size,
+				URL:      VariantURL(p, v.Name, ext),
+			})
+		}
+	}
+
+	manifest, err := json.Marshal(variants)
+	if err != nil {
+		return err
+	}
+	_, err = s.Put(path.Join(dir, variantManifest), bytes.NewReader(manifest), int64(len(manifest)), "application/json")
+	if err != nil {
+		return err
+	}
+
+	// keep them next to the metadata of the original, the
+	// URLs depend on the path and are set when read
+	for i := range variants {
+		variants[i].URL = ""
+	}
+	return database.GetDB().Model(&model.Media{}).Where("path = ?", p).Updates(model.Media{Variants: variants}).Error
+}
+
+func variantExt(mimeType string) string {
+	switch mimeType {
+	case "image/jpeg":
+		return ".jpg"
+	case "image/webp":
+		return ".webp"
+	}
+	return ".png"
+}
@@ -0,0 +1,79 @@
+package service
+
+import (
+	"time"
+
+	log "github.com/sirupsen/logrus"
+
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// postSchedulerWake - tells the scheduler that a post has been
+// scheduled, buffered so that nobody waits for it
+var postSchedulerWake = make(chan struct{}, 1)
+
+// WakePostScheduler - let the scheduler look at the next
+// scheduled post again
+func WakePostScheduler() {
+	select {
+	case postSchedulerWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartPostScheduler - publish scheduled posts when their time
+// has come, the schedule is read again at least every maxWait
+// as posts may be scheduled by other instances
+func StartPostScheduler(maxWait time.Duration) {
+	if maxWait <= 0 {
+		return
+	}
+
+	go func() {
+		for {
+			next, err := PublishScheduledPosts(time.Now())
+			if err != nil {
+				log.WithError(err).Error("error code: 1241")
+			}
+
+			wait := maxWait
+			if next != nil && time.Until(*next) < wait {
+				wait = time.Until(*next)
+			}
+
+			timer := time.NewTimer(wait)
+			select {
+			case <-timer.C:
+			case <-postSchedulerWake:
+				timer.Stop()
+			}
+		}
+	}()
+}
+
+// PublishScheduledPosts - publish every scheduled post which is
+// due at the given time and return when the next one is due
+//
+// The posts count as changed at the time they are published,
+// sitemaps and feeds go by it
+func PublishScheduledPosts(now time.Time) (*time.Time, error) {
+	db := database.GetDB()
+
+	err := db.Model(&model.Post{}).
+		Where("status = ? AND publish_at <= ?", model.PostScheduled, now).
+		Updates(map[string]interface{}{"status": model.PostPublished, "updated_at": now}).Error
+	if err != nil {
+		return nil, err
+	}
+
+	next := model.Post{}
+	err = db.Select("id", "publish_at").
+		Where("status = ?", model.PostScheduled).
+		Order("publish_at").Limit(1).Find(&next).Error
+	if err != nil || next.ID == 0 {
+		return nil, err
+	}
+
+	return next.PublishAt, nil
+}
@@ -0,0 +1,211 @@
+package service
+
+import (
+	"context"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+	"github.com/mediocregopher/radix/v4"
+	log "github.com/sirupsen/logrus"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/tinkerbaj/gintemp/config"
+	"github.com/tinkerbaj/gintemp/database"
+	"github.com/tinkerbaj/gintemp/database/model"
+)
+
+// PostViewsKey - Redis hash buffering the views of the posts,
+// field: post ID, value: views since the last flush
+const PostViewsKey string = "gintemp-post-views"
+
+// postViewsFlushPrefix - a flush renames the hash to a key of
+// its own, views counted meanwhile go to a new hash
+const postViewsFlushPrefix string = PostViewsKey + "-flush:"
+
+// postViews - buffer of the views without Redis, lost when the
+// process stops
+var postViews = struct {
+	sync.Mutex
+	counts map[uint]int64
+}{counts: map[uint]int64{}}
+
+// postViewsFlush - one flush at a time, pending is the Redis key
+// of a batch which could not be written yet
+var postViewsFlush = struct {
+	sync.Mutex
+	pending string
+}{}
+
+// redisContext - context with the configured Redis timeout
+func redisContext() (context.Context, context.CancelFunc) {
+	rConnTTL := config.GetConfig().Database.REDIS.Conn.ConnTTL
+	return context.WithTimeout(context.Background(), time.Duration(rConnTTL)*time.Second)
+}
+
+// CountPostView - buffer one view of the post
+func CountPostView(postID uint) error {
+	if !config.IsRedis() {
+		postViews.Lock()
+		postViews.counts[postID]++
+		postViews.Unlock()
+		return nil
+	}
+
+	client := *database.GetRedis()
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	return client.Do(ctx, radix.FlatCmd(nil, "HINCRBY", PostViewsKey, postID, 1))
+}
+
+// BufferedPostViews - views of the post which are not written
+// to the database yet
+func BufferedPostViews(postID uint) (int64, error) {
+	if !config.IsRedis() {
+		postViews.Lock()
+		defer postViews.Unlock()
+		return postViews.counts[postID], nil
+	}
+
+	client := *database.GetRedis()
+	ctx, cancel := redisContext()
+	defer cancel()
+
+	// nil when the post has not been read since the last flush
+	var views int64
+	err := client.Do(ctx, radix.FlatCmd(&radix.Maybe{Rcv: &views}, "HGET", PostViewsKey, postID))
+
+	return views, err
+}
+
+// StartPostViewFlush - write the buffered views to the database
+// in batches
+func StartPostViewFlush(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for range ticker.C {
+			if err := FlushPostViews(); err != nil {
+				log.WithError(err).Error("error code: 1801")
+			}
+		}
+	}()
+}
+
+// FlushPostViews - add the buffered views to the counts in the
+// database in one transaction
+//
+// A batch which cannot be written stays buffered and is tried
+// again with the next flush
+func FlushPostViews() error {
+	postViewsFlush.Lock()
+	defer postViewsFlush.Unlock()
+
+	if !config.IsRedis() {
+		postViews.Lock()
+		counts := postViews.counts
+		postViews.counts = map[uint]int64{}
+		postViews.Unlock()
+
+		if err := writePostViews(counts); err != nil {
+			postViews.Lock()
+			for postID, views := range counts {
+				postViews.counts[postID] += views
+			}
+			postViews.Unlock()
+			return err
+		}
+		return nil
+	}
+
+	client := *database.GetRedis()
+
+	if postViewsFlush.pending == "" {
+		key := postViewsFlushPrefix + uuid.NewString()
+
+		ctx, cancel := redisContext()
+		err := client.Do(ctx, radix.FlatCmd(nil, "RENAME", PostViewsKey, key))
+		cancel()
+		if err != nil {
+			// nobody has read a post since the last flush
+			if strings.Contains(err.Error(), "no such key") {
+				return nil
+			}
+			return err
+		}
+		postViewsFlush.pending = key
+	}
+
+	fields := map[string]string{}
+	ctx, cancel := redisContext()
+	err := client.Do(ctx, radix.FlatCmd(&fields, "HGETALL", postViewsFlush.pending))
+	cancel()
+	if err != nil {
+		return err
+	}
+
+	counts := map[uint]int64{}
+	for field, value := range fields {
+		postID, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			continue
+		}
+		views, err := strconv.ParseInt(value, 10, 64)
+		if err != nil {
+			continue
+		}
+		counts[uint(postID)] += views
+	}
+
+	if err := writePostViews(counts); err != nil {
+		return err
+	}
+
+	ctx, cancel = redisContext()
+	err = client.Do(ctx, radix.FlatCmd(nil, "DEL", postViewsFlush.pending))
+	cancel()
+	// the views are written, they must not be added twice
+	postViewsFlush.pending = ""
+
+	return err
+}
+
+// writePostViews - add the views to the counts of the posts
+func writePostViews(counts map[uint]int64) error {
+	if len(counts) == 0 {
+		return nil
+	}
+
+	// the same order in every instance keeps the row locks
+	// from deadlocking
+	postIDs := make([]uint, 0, len(counts))
+	for postID := range counts {
+		postIDs = append(postIDs, postID)
+	}
+	sort.Slice(postIDs, func(i, j int) bool {
+		return postIDs[i] < postIDs[j]
+	})
+
+	return database.GetDB().Transaction(func(tx *gorm.DB) error {
+		for _, postID := range postIDs {
+			err := tx.Clauses(clause.OnConflict{
+				Columns:   []clause.Column{{Name: "post_id"}},
+				DoUpdates: clause.Assignments(map[string]interface{}{"views": gorm.Expr("post_views.views + ?", counts[postID])}),
+			}).Create(&model.PostView{PostID: postID, Views: counts[postID]}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}